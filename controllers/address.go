@@ -2,19 +2,19 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
 	"github.com/patil-prathamesh/e-commerce-golang/models"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func AddAddress(c *gin.Context) {
-	userId := c.Query("user_id")
-	if userId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+func (app *Application) AddAddress(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
 		return
 	}
 
@@ -36,12 +36,9 @@ func AddAddress(c *gin.Context) {
 		return
 	}
 
-	filter := bson.M{"_id": userObjectId}
-	update := bson.M{"$push": bson.M{"address_details": address}}
+	err = app.Users.AddAddress(ctx, userObjectId, address)
 
-	result, err := UserCollection.UpdateOne(ctx, filter, update)
-
-	if result.MatchedCount == 0 {
+	if errors.Is(err, database.ErrUserNotFound) {
         c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
         return
     }
@@ -57,10 +54,9 @@ func AddAddress(c *gin.Context) {
     })
 }
 
-func EditHomeAddress(c *gin.Context) {
-	userId := c.Query("user_id")
-	if userId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+func (app *Application) EditHomeAddress(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
 		return
 	}
 
@@ -80,33 +76,24 @@ func EditHomeAddress(c *gin.Context) {
     ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Second)
     defer cancel()
 
-	filter := bson.M{"_id" : userObjectId}
-	update := bson.M{"$set" : bson.M{
-		"address_details.0.house": editAddress.House,
-		"address_details.0.street": editAddress.Street,
-		"address_details.0.city": editAddress.City,
-		"address_details.0.pincode": editAddress.Pincode,
-	}}
-
-	result, err := UserCollection.UpdateOne(ctx, filter, update)
+	err = app.Users.UpdateAddress(ctx, userObjectId, 0, editAddress)
 
-	if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update home address"})
+	if errors.Is(err, database.ErrUserNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
         return
     }
 
-	if result.MatchedCount == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update home address"})
         return
     }
 
     c.JSON(http.StatusOK, gin.H{"message": "home address updated successfully"})
 }
 
-func EditWorkAddress(c *gin.Context) {
-	userId := c.Query("user_id")
-	if userId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+func (app *Application) EditWorkAddress(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
 		return
 	}
 
@@ -126,37 +113,27 @@ func EditWorkAddress(c *gin.Context) {
     ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Second)
     defer cancel()
 
-	filter := bson.M{"_id" : userObjectId}
-	update := bson.M{"$set" : bson.M{
-		"address_details.1.house": editAddress.House,
-		"address_details.1.street": editAddress.Street,
-		"address_details.1.city": editAddress.City,
-		"address_details.1.pincode": editAddress.Pincode,
-	}}
-
-	result, err := UserCollection.UpdateOne(ctx, filter, update)
+	err = app.Users.UpdateAddress(ctx, userObjectId, 1, editAddress)
 
-	if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update work address"})
+	if errors.Is(err, database.ErrUserNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
         return
     }
 
-	if result.MatchedCount == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update work address"})
         return
     }
 
     c.JSON(http.StatusOK, gin.H{"message": "work address updated successfully"})
 }
 
-func DeleteAddress(c *gin.Context) {
-	userId := c.Query("user_id")
-	if userId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+func (app *Application) DeleteAddress(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
 		return
 	}
 
-	addresses := []models.Address{}
 	userObjectId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id format"})
@@ -165,17 +142,15 @@ func DeleteAddress(c *gin.Context) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Second)
 	defer cancel()
-	filter := bson.M{"_id": userObjectId}
-	update := bson.M{"$set": bson.M{"address_details": addresses}}
 
-	result, err := UserCollection.UpdateOne(ctx,filter,update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete address"})
+	err = app.Users.ClearAddresses(ctx, userObjectId)
+	if errors.Is(err, database.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete address"})
 		return
 	}
 
@@ -5,25 +5,73 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/patil-prathamesh/e-commerce-golang/database"
 	"github.com/patil-prathamesh/e-commerce-golang/models"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Application struct {
-	ProdCollection *mongo.Collection
-	UserCollection *mongo.Collection
+	Products      database.ProductStore
+	Users         database.UserStore
+	Orders        database.OrderStore
+	Inventory     database.InventoryStore
+	Audit         database.AuditStore
+	Synonyms      database.SynonymStore
+	Categories    database.CategoryStore
+	Coupons       database.CouponStore
+	Promotions    database.PromotionStore
+	Rates         database.ExchangeRateStore
+	Taxes         database.TaxStore
+	PaymentEvents database.PaymentEventStore
+	Ledger        database.LedgerStore
+	Returns       database.ReturnStore
+	Search        *database.Searcher
+	// Payments takes card payments. Without one, only cash on delivery is
+	// offered.
+	Payments payments.Provider
 }
 
-func NewApplication(ProdCollection, UserCollection *mongo.Collection) *Application {
-	return &Application{ProdCollection, UserCollection}
+func NewApplication(store database.Store) *Application {
+	return &Application{
+		Products:      store,
+		Users:         store,
+		Orders:        store,
+		Inventory:     store,
+		Audit:         store,
+		Synonyms:      store,
+		Categories:    store,
+		Coupons:       store,
+		Promotions:    store,
+		Rates:         store,
+		Taxes:         store,
+		PaymentEvents: store,
+		Ledger:        store,
+		Returns:       store,
+		Search:        database.NewSearcher(store, store),
+	}
 }
 
+// variantError writes the response for a missing or unknown variant and
+// reports whether err was one.
+func variantError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrVariantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+// AddToCart adds a product to the cart. Products with variants need the
+// variant_sku of the one being bought.
 func (app *Application) AddToCart(c *gin.Context) {
 	productQueryId := c.Query("product_id")
 	if productQueryId == "" {
@@ -32,10 +80,8 @@ func (app *Application) AddToCart(c *gin.Context) {
 		return
 	}
 
-	userQueryId := c.Query("user_id")
-	if userQueryId == "" {
-		log.Println("user id is empty")
-		c.AbortWithError(http.StatusBadRequest, errors.New("user id is empty"))
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
 		return
 	}
 
@@ -46,11 +92,30 @@ func (app *Application) AddToCart(c *gin.Context) {
 		return
 	}
 
+	quantity := uint64(1)
+	if quantityQuery := c.Query("quantity"); quantityQuery != "" {
+		quantity, err = strconv.ParseUint(quantityQuery, 10, 64)
+		if err != nil || quantity == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a positive number"})
+			return
+		}
+		if quantity > database.MaxLineQuantity {
+			c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrQuantityTooLarge.Error()})
+			return
+		}
+	}
+
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	err = database.AddProductToCart(ctx, app.ProdCollection, app.UserCollection, productId, userQueryId)
-
+	err = database.AddProductToCart(ctx, app.Products, app.Users, productId, c.Query("variant_sku"), userQueryId, quantity)
+	if variantError(c, err) {
+		return
+	}
+	if errors.Is(err, database.ErrQuantityTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -67,10 +132,8 @@ func (app *Application) RemoveItem(c *gin.Context) {
 		return
 	}
 
-	userQueryId := c.Query("user_id")
-	if userQueryId == "" {
-		log.Println("user id is empty")
-		c.AbortWithError(http.StatusBadRequest, errors.New("user id is empty"))
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
 		return
 	}
 
@@ -83,7 +146,7 @@ func (app *Application) RemoveItem(c *gin.Context) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
-	err = database.RemoveCartItem(ctx, app.ProdCollection, app.UserCollection, productId, userQueryId)
+	err = database.RemoveCartItem(ctx, app.Products, app.Users, productId, c.Query("variant_sku"), userQueryId)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
@@ -93,63 +156,150 @@ func (app *Application) RemoveItem(c *gin.Context) {
 	c.IndentedJSON(200, "successfully removed item from cart")
 }
 
-func GetItemFromCart(c *gin.Context) {
-	userId := c.Query("user_id")
+func (app *Application) UpdateCartQuantity(c *gin.Context) {
+	productQueryId := c.Query("product_id")
+	if productQueryId == "" {
+		log.Println("product id is empty")
+		c.AbortWithError(http.StatusBadRequest, errors.New("product id is empty"))
+		return
+	}
 
-	if userId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+
+	productId, err := primitive.ObjectIDFromHex(productQueryId)
+	if err != nil {
+		log.Println(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	action := c.DefaultQuery("action", database.CartActionSet)
+	quantity, err := strconv.ParseUint(c.DefaultQuery("quantity", "1"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a number"})
+		return
+	}
+	if quantity > database.MaxLineQuantity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrQuantityTooLarge.Error()})
 		return
 	}
 
-	userObjectId, _ := primitive.ObjectIDFromHex(userId)
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	var filledCart models.User
+	err = database.UpdateCartQuantity(ctx, app.Users, productId, c.Query("variant_sku"), userQueryId, action, quantity)
+	switch {
+	case errors.Is(err, database.ErrInvalidCartAction), errors.Is(err, database.ErrInvalidQuantity), errors.Is(err, database.ErrQuantityTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrCartItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	err := UserCollection.FindOne(ctx, bson.M{"_id": userObjectId}).Decode(&filledCart)
+	c.IndentedJSON(200, gin.H{"message": "successfully updated the cart"})
+}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+func (app *Application) GetItemFromCart(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
 		return
 	}
 
-	filterMatch := bson.D{{Key: "$match", Value: bson.D{{Key: "_id", Value: userObjectId}}}}
-	unwind := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$user_cart"}}}}
-	grouping := bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$_id"}, {Key: "total", Value: bson.D{{Key: "$sum", Value: "$user_cart.price"}}}}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	rate, ok := app.displayRate(ctx, c)
+	if !ok {
+		return
+	}
 
-	cursor, err := UserCollection.Aggregate(ctx, mongo.Pipeline{filterMatch, unwind, grouping})
+	summary, err := database.GetCart(ctx, app.Users, app.Products, app.Coupons, app.Categories, app.Promotions, app.Taxes, userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	var listing []bson.M
-	if err := cursor.All(ctx, &listing); err != nil {
+	app.respondWithCart(c, summary, rate)
+}
+
+// respondWithCart writes summary with its prices in rate's currency.
+func (app *Application) respondWithCart(c *gin.Context, summary database.CartSummary, rate models.ExchangeRate) {
+	summary, err := database.ConvertCart(summary, rate)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, cartResponse(summary))
+}
 
-	c.JSON(http.StatusOK, gin.H{"total": listing[0]["total"]})
+func cartResponse(summary database.CartSummary) gin.H {
+	response := gin.H{
+		"cart":        summary.Items,
+		"unavailable": summary.Unavailable,
+		"subtotal":    summary.Subtotal,
+		"discount":    summary.Discount,
+		"tax":         summary.Tax,
+		"total":       summary.Total,
+	}
+	if summary.TaxInclusive {
+		response["tax_inclusive"] = true
+	}
+	if len(summary.Promotions) > 0 {
+		response["promotions"] = summary.Promotions
+	}
+	if summary.Coupon != "" {
+		response["coupon"] = summary.Coupon
+		response["coupon_discount"] = summary.CouponDiscount
+	}
+	if summary.CouponError != "" {
+		response["coupon_error"] = summary.CouponError
+	}
+	return response
 }
 
 func (app *Application) BuyFromCart(c *gin.Context) {
-	userQueryId := c.Query("user_id")
-	if userQueryId == "" {
-		log.Println("user id is empty")
-		_ = c.AbortWithError(http.StatusBadRequest, errors.New("User id is empty"))
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	payment, ok := app.bindPayment(c)
+	if !ok {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	err := database.BuyItemFromCart(ctx, app.UserCollection, userQueryId)
+	rate, ok := app.displayRate(ctx, c)
+	if !ok {
+		return
+	}
+
+	order, err := database.BuyItemFromCart(ctx, app.Users, app.Products, app.Inventory, app.Orders, app.Coupons, app.Categories, app.Promotions, app.Taxes, rate, payment.Method, userQueryId, c.Query("address_id"))
+	if stockError(c, err) || couponError(c, err) {
+		return
+	}
+	if errors.Is(err, database.ErrAddressNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, database.ErrProductUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, err)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.IndentedJSON(200, "successfully placed the order")
+	app.respondWithPlacedOrder(ctx, c, order, payment)
 }
 
 func (app *Application) InstantBuy(c *gin.Context) {
@@ -160,10 +310,12 @@ func (app *Application) InstantBuy(c *gin.Context) {
 		return
 	}
 
-	userQueryId := c.Query("user_id")
-	if userQueryId == "" {
-		log.Println("user id is empty")
-		c.AbortWithError(http.StatusBadRequest, errors.New("user id is empty"))
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	payment, ok := app.bindPayment(c)
+	if !ok {
 		return
 	}
 
@@ -177,12 +329,24 @@ func (app *Application) InstantBuy(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	err = database.InstantBuyer(ctx, app.ProdCollection, app.UserCollection, productId, userQueryId)
+	rate, ok := app.displayRate(ctx, c)
+	if !ok {
+		return
+	}
+
+	order, err := database.InstantBuyer(ctx, app.Users, app.Products, app.Orders, app.Promotions, app.Taxes, rate, payment.Method, productId, c.Query("variant_sku"), userQueryId, c.Query("address_id"))
+	if stockError(c, err) || variantError(c, err) {
+		return
+	}
+	if errors.Is(err, database.ErrAddressNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.IndentedJSON(200, "successfully placed the order")
+	app.respondWithPlacedOrder(ctx, c, order, payment)
 }
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+func (app *Application) CategoryTree(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	tree, err := database.CategoryTree(ctx, app.Categories)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": tree})
+}
+
+// BrowseCategory lists the products in a category and all of its
+// subcategories, with the same paging, sorting and filters as productView.
+func (app *Application) BrowseCategory(c *gin.Context) {
+	slug := normalizeCategory(c.Query("slug"))
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category slug is empty"})
+		return
+	}
+
+	query, page, err := productQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	details, err := database.GetCategory(ctx, app.Categories, slug)
+	if errors.Is(err, database.ErrCategoryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+	query.Categories = details.Descendants
+
+	app.respondWithProducts(ctx, c, query, page, gin.H{
+		"category": details.Category,
+		"path":     details.Path,
+		"children": details.Children,
+	})
+}
+
+func (app *Application) CreateCategory(c *gin.Context) {
+	var category models.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	category, err := database.CreateCategory(ctx, app.Categories, category)
+	if categoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "category created", "category": category})
+}
+
+func (app *Application) UpdateCategory(c *gin.Context) {
+	var update models.CategoryUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	category, err := database.UpdateCategory(ctx, app.Categories, normalizeCategory(c.Query("slug")), update)
+	if categoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category updated", "category": category})
+}
+
+// DeleteCategory only removes categories that are empty: no subcategories
+// and no products, so nothing is left pointing at a missing slug.
+func (app *Application) DeleteCategory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err := database.DeleteCategory(ctx, app.Categories, app.Products, normalizeCategory(c.Query("slug")))
+	if categoryError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted"})
+}
+
+// categoryError writes the response for err and reports whether there was
+// one.
+func categoryError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, database.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrInvalidSlug), errors.Is(err, database.ErrParentNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrCategoryExists), errors.Is(err, database.ErrCategoryCycle), errors.Is(err, database.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+	}
+	return true
+}
@@ -2,8 +2,12 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,14 +15,10 @@ import (
 	"github.com/patil-prathamesh/e-commerce-golang/database"
 	"github.com/patil-prathamesh/e-commerce-golang/models"
 	"github.com/patil-prathamesh/e-commerce-golang/tokens"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var UserCollection *mongo.Collection = database.UserData(database.Client, "users")
-var ProductCollection *mongo.Collection = database.ProductData(database.Client, "products")
 var Validate = validator.New()
 
 func HashPassword(password string) string {
@@ -40,7 +40,19 @@ func VerifyPassword(userPassword, givenPassword string) (bool, string) {
 	return valid, msg
 }
 
-func SignUp(c *gin.Context) {
+// isBootstrapAdmin reports whether email is listed in ADMIN_EMAILS. Those
+// accounts are made admins at signup so a fresh install has someone who can
+// reach the /admin routes and hand out further roles.
+func isBootstrapAdmin(email string) bool {
+	for _, admin := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if admin = strings.TrimSpace(admin); admin != "" && strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *Application) SignUp(c *gin.Context) {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
@@ -56,7 +68,7 @@ func SignUp(c *gin.Context) {
 		return
 	}
 
-	count, err := UserCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+	count, err := app.Users.CountUsersByEmail(ctx, user.Email)
 	if err != nil {
 		log.Panic(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -67,7 +79,7 @@ func SignUp(c *gin.Context) {
 		return
 	}
 
-	count, err = UserCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
+	count, err = app.Users.CountUsersByPhone(ctx, user.Phone)
 	if err != nil {
 		log.Panic(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -84,13 +96,18 @@ func SignUp(c *gin.Context) {
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 	user.ID = primitive.NewObjectID()
-	token, refreshToken, _ := tokens.TokenGenerator(user.ID.Hex(), user.Email, user.FirstName, user.LastName)
+	user.Role = models.RoleCustomer
+	if isBootstrapAdmin(user.Email) {
+		user.Role = models.RoleAdmin
+	}
+	family := tokens.NewFamily()
+	token, refreshToken, _ := tokens.TokenGenerator(user.ID.Hex(), user.Email, user.FirstName, user.LastName, user.Role, family)
 	user.RefreshToken = refreshToken
+	user.TokenFamily = family
 	user.UserCart = []models.ProductUser{}
 	user.AddressDetails = []models.Address{}
-	user.Order = []models.Order{}
 
-	_, insertErr := UserCollection.InsertOne(ctx, user)
+	insertErr := app.Users.InsertUser(ctx, user)
 
 	if insertErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
@@ -98,13 +115,14 @@ func SignUp(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message":      "User creted successfully",
-		"user_id":      user.ID.Hex(),
-		"access_token": token,
+		"message":       "User creted successfully",
+		"user_id":       user.ID.Hex(),
+		"access_token":  token,
+		"refresh_token": refreshToken,
 	})
 }
 
-func Login(c *gin.Context) {
+func (app *Application) Login(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
@@ -114,9 +132,7 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var foundUser models.User
-
-	err := UserCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
+	foundUser, err := app.Users.FindUserByEmail(ctx, user.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "login or password is incorrect"})
 		return
@@ -128,17 +144,49 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, refreshToken, _ := tokens.TokenGenerator(foundUser.ID.Hex(), foundUser.Email, foundUser.FirstName, foundUser.LastName)
+	family := tokens.NewFamily()
+	token, refreshToken, _ := tokens.TokenGenerator(foundUser.ID.Hex(), foundUser.Email, foundUser.FirstName, foundUser.LastName, foundUser.UserRole(), family)
 
-	tokens.UpdateAllTokens(token, refreshToken, foundUser.ID.Hex())
+	tokens.UpdateAllTokens(app.Users, token, refreshToken, foundUser.ID.Hex(), family)
 
 	c.JSON(http.StatusFound, gin.H{
 		"message": "user logged in",
 		"access_token": token,
+		"refresh_token": refreshToken,
+	})
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func (app *Application) RefreshToken(c *gin.Context) {
+	var request refreshRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	token, refreshToken, err := tokens.RefreshTokens(ctx, app.Users, request.RefreshToken)
+	if errors.Is(err, tokens.ErrInvalidRefreshToken) || errors.Is(err, tokens.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  token,
+		"refresh_token": refreshToken,
 	})
 }
 
-func ProductViewerAdmin(c *gin.Context) {
+func (app *Application) ProductViewerAdmin(c *gin.Context) {
 	var product models.Product
 
 	if err := c.ShouldBindJSON(&product); err !=  nil {
@@ -146,79 +194,188 @@ func ProductViewerAdmin(c *gin.Context) {
 		return
 	}
 
+	if err := Validate.Struct(product); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product.Tags = database.NormalizeTags(product.Tags)
+	product.Brand = strings.TrimSpace(product.Brand)
+	if err := normalizePrices(&product.Price, product.Variants); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if sku := duplicateSKU(product.Variants); sku != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "variant sku " + sku + " is used more than once"})
+		return
+	}
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = product.CreatedAt
+	product.Deleted = false
+	product.DeletedAt = nil
+
 	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Second)
 	defer cancel()
 
-	count , _ := ProductCollection.CountDocuments(ctx, bson.M{"_id": product.ProductID})
+	categories, err := database.CheckCategories(ctx, app.Categories, product.Categories)
+	if errors.Is(err, database.ErrCategoryNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	product.Categories = categories
 
-	if count > 0 {
+	insertedID, err := app.Products.InsertProduct(ctx, product)
+	if errors.Is(err, database.ErrProductExists) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error" : "product already exists"})
 		return
 	}
-
-	inserted, err := ProductCollection.InsertOne(ctx, product)
+	if errors.Is(err, database.ErrSKUExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "product inserted successfullly", "product_id": inserted.InsertedID})
+	c.JSON(http.StatusCreated, gin.H{"message": "product inserted successfullly", "product_id": insertedID})
+}
+
+func (app *Application) SearchProduct(c *gin.Context) {
+	query, page, err := productQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	query.Categories, err = database.WithSubcategories(ctx, app.Categories, query.Categories)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	app.respondWithProducts(ctx, c, query, page, gin.H{})
 }
 
-func SearchProduct(c *gin.Context) {
-	var productList []models.Product
+func (app *Application) SearchProductByQuery(c *gin.Context) {
+	name := c.Query("name")
+
+	if name == "" {
+		log.Println("query is empty")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid search index"})
+		return
+	}
+	if len(name) > database.MaxSearchLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "search query is too long"})
+		return
+	}
+
+	query, page, err := productQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Text = name
+
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
-	cursor, err := ProductCollection.Find(ctx, bson.M{})
+
+	query.Categories, err = database.WithSubcategories(ctx, app.Categories, query.Categories)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
 		return
 	}
 
-	err = cursor.All(ctx, &productList)
+	rate, ok := app.displayRate(ctx, c)
+	if !ok {
+		return
+	}
+
+	result, err := app.Search.Search(ctx, query)
 	if err != nil {
 		log.Println(err)
-		c.AbortWithError(500, err) //
+		c.JSON(400, gin.H{"error": "invalid"})
+		return
+	}
+	if result.Products, err = database.ConvertProducts(result.Products, rate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"products": productList,
-		"count":    len(productList),
-	})
+	response := gin.H{
+		"products": result.Products,
+		"count":    len(result.Products),
+		"total":    result.Total,
+		"page":     page,
+		"limit":    query.Limit,
+		"facets":   result.Facets,
+	}
+	if result.DidYouMean != "" {
+		response["did_you_mean"] = result.DidYouMean
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-func SearchProductByQuery(c *gin.Context) {
-	var searchedProducts []models.Product
-	query := c.Query("name")
+// AutocompleteProducts suggests product names for what the user has typed so
+// far. Each word of a name is matched from its start.
+func (app *Application) AutocompleteProducts(c *gin.Context) {
+	prefix := strings.TrimSpace(c.Query("prefix"))
+	if prefix == "" || len(prefix) > database.MaxSearchLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prefix must be between 1 and 100 characters"})
+		return
+	}
 
-	if query == "" {
-		log.Println("query is empty")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid search index"})
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "10"), 10, 64)
+	if err != nil || limit < 1 || limit > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number from 1 to 20"})
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	cursor, err := ProductCollection.Find(ctx, bson.M{"product_name": bson.M{"$regex": query, "$options": "i"}})
+	suggestions, err := app.Products.SuggestProductNames(ctx, prefix, limit)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+func (app *Application) UpdateUserRole(c *gin.Context) {
+	userObjectId, err := primitive.ObjectIDFromHex(c.Query("user_id"))
 	if err != nil {
-		c.JSON(404, "something went wrong")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id format"})
 		return
 	}
 
-	defer cursor.Close(ctx)
+	role := c.Query("role")
+	if !models.ValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of customer, admin, support or seller"})
+		return
+	}
 
-	err = cursor.All(ctx, &searchedProducts)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err = app.Users.UpdateUserRole(ctx, userObjectId, role)
+	if errors.Is(err, database.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		log.Println(err)
-		c.JSON(400, gin.H{"error": "invalid"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"products": searchedProducts,
-		"count":    len(searchedProducts),
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "role updated, it applies from the user's next login or token refresh"})
 }
\ No newline at end of file
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+// couponError writes the response for a coupon that cannot be used and
+// reports whether err was one.
+func couponError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrCouponNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrCouponInactive), errors.Is(err, database.ErrCouponMinimum),
+		errors.Is(err, database.ErrCouponNotApplicable), errors.Is(err, database.ErrCouponExhausted),
+		errors.Is(err, database.ErrCouponUsedUp):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+// ApplyCoupon applies the coupon given by code to the cart and returns the
+// cart with its new total.
+func (app *Application) ApplyCoupon(c *gin.Context) {
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "coupon code is empty"})
+		return
+	}
+
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	rate, ok := app.displayRate(ctx, c)
+	if !ok {
+		return
+	}
+
+	summary, err := database.ApplyCartCoupon(ctx, app.Users, app.Products, app.Coupons, app.Categories, app.Promotions, app.Taxes, userQueryId, code)
+	if couponError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	app.respondWithCart(c, summary, rate)
+}
+
+func (app *Application) RemoveCoupon(c *gin.Context) {
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	rate, ok := app.displayRate(ctx, c)
+	if !ok {
+		return
+	}
+
+	summary, err := database.RemoveCartCoupon(ctx, app.Users, app.Products, app.Coupons, app.Categories, app.Promotions, app.Taxes, userQueryId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	app.respondWithCart(c, summary, rate)
+}
+
+func (app *Application) CreateCoupon(c *gin.Context) {
+	var coupon models.Coupon
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(coupon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	coupon, err := database.CreateCoupon(ctx, app.Coupons, coupon)
+	if errors.Is(err, database.ErrInvalidCoupon) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, database.ErrCouponExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "coupon created", "coupon": coupon})
+}
+
+func (app *Application) ListCoupons(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	coupons, err := app.Coupons.ListCoupons(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"coupons": coupons})
+}
+
+// DeleteCoupon withdraws a coupon. Carts that still hold it simply stop
+// getting the discount.
+func (app *Application) DeleteCoupon(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err := app.Coupons.DeleteCoupon(ctx, database.NormalizeCouponCode(c.Query("code")))
+	if errors.Is(err, database.ErrCouponNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "coupon deleted"})
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+// CurrencyHeader lets clients pick the currency prices are shown in for every
+// request; the currency query parameter takes precedence over it.
+const CurrencyHeader = "X-Currency"
+
+// displayRate returns the exchange rate for the currency the request asks
+// prices in, writing the error response itself when there is none.
+func (app *Application) displayRate(ctx context.Context, c *gin.Context) (models.ExchangeRate, bool) {
+	currency := c.Query("currency")
+	if currency == "" {
+		currency = c.GetHeader(CurrencyHeader)
+	}
+
+	rate, err := database.RateFor(ctx, app.Rates, currency)
+	if errors.Is(err, database.ErrUnsupportedCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.ExchangeRate{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return models.ExchangeRate{}, false
+	}
+	return rate, true
+}
+
+// ListCurrencies lists the currencies customers can see prices in.
+func (app *Application) ListCurrencies(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	rates, err := app.Rates.ListExchangeRates(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	currencies := []string{models.BaseCurrency}
+	for _, rate := range rates {
+		currencies = append(currencies, rate.Currency)
+	}
+	c.JSON(http.StatusOK, gin.H{"base": models.BaseCurrency, "currencies": currencies})
+}
+
+func (app *Application) ListExchangeRates(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	rates, err := app.Rates.ListExchangeRates(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"base": models.BaseCurrency, "rates": rates})
+}
+
+// SaveExchangeRate sets the rate for one currency. Prices shown from then on
+// use it; orders keep the rate they were placed at.
+func (app *Application) SaveExchangeRate(c *gin.Context) {
+	var rate models.ExchangeRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate.Currency = strings.ToUpper(strings.TrimSpace(rate.Currency))
+	if err := Validate.Struct(rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	rate, err := database.SaveExchangeRate(ctx, app.Rates, rate)
+	if errors.Is(err, database.ErrInvalidRate) || errors.Is(err, database.ErrBaseCurrencyRate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "exchange rate saved", "rate": rate})
+}
+
+func (app *Application) DeleteExchangeRate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err := app.Rates.DeleteExchangeRate(ctx, strings.ToUpper(c.Query("currency")))
+	if errors.Is(err, database.ErrRateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "exchange rate deleted"})
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// actingUserID returns the ID of the user a request operates on. That is the
+// authenticated caller, unless an admin names someone else with ?user_id=, in
+// which case the override is written to the audit trail first. On failure
+// the response has already been written and ok is false.
+func (app *Application) actingUserID(c *gin.Context) (userID string, ok bool) {
+	callerID := c.GetString("uid")
+	actorObjectId, err := primitive.ObjectIDFromHex(callerID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token does not identify a user"})
+		return "", false
+	}
+
+	target := c.Query("user_id")
+	if target == "" || target == callerID {
+		return callerID, true
+	}
+
+	if c.GetString("role") != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only admins may act on behalf of another user"})
+		return "", false
+	}
+
+	subjectObjectId, err := primitive.ObjectIDFromHex(target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id format"})
+		return "", false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err = app.Audit.InsertAuditEntry(ctx, models.AuditEntry{
+		ID:        primitive.NewObjectID(),
+		ActorID:   actorObjectId,
+		SubjectID: subjectObjectId,
+		Action:    c.Request.Method + " " + c.FullPath(),
+		Details:   c.Request.URL.RawQuery,
+		At:        time.Now(),
+	})
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record the audit trail, nothing was changed"})
+		return "", false
+	}
+
+	return target, true
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type stockAdjustmentRequest struct {
+	ProductID  string `json:"product_id" binding:"required"`
+	VariantSKU string `json:"variant_sku"`
+	Delta      int64  `json:"delta" binding:"required"`
+	Reason     string `json:"reason" binding:"required"`
+	Note       string `json:"note"`
+}
+
+// stockError writes a 409 listing the short lines when err is a
+// *database.StockError and reports whether it did so.
+func stockError(c *gin.Context, err error) bool {
+	var shortage *database.StockError
+	if !errors.As(err, &shortage) {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": shortage.Error(), "shortages": shortage.Shortages})
+	return true
+}
+
+func (app *Application) ReserveCart(c *gin.Context) {
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	reservation, err := database.ReserveCart(ctx, app.Users, app.Products, app.Inventory, userQueryId)
+	if stockError(c, err) {
+		return
+	}
+	if errors.Is(err, database.ErrProductUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "stock reserved for checkout",
+		"reservation_id": reservation.ID.Hex(),
+		"expires_at":     reservation.ExpiresAt,
+	})
+}
+
+func (app *Application) ReleaseCart(c *gin.Context) {
+	userQueryId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+
+	userObjectId, err := primitive.ObjectIDFromHex(userQueryId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrUserIdIsNotValid.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if err := database.ReleaseUserReservation(ctx, app.Products, app.Inventory, userObjectId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "reserved stock released"})
+}
+
+func (app *Application) AdjustStock(c *gin.Context) {
+	var request stockAdjustmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productId, err := primitive.ObjectIDFromHex(request.ProductID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	adjustment, err := database.AdjustStock(ctx, app.Products, app.Inventory, productId, request.VariantSKU, request.Delta, request.Reason, request.Note)
+	switch {
+	case errors.Is(err, database.ErrInvalidStockReason), errors.Is(err, database.ErrInvalidStockDelta), errors.Is(err, database.ErrVariantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrCantFindProduct), errors.Is(err, database.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": "adjustment would make stock negative"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "stock adjusted", "adjustment": adjustment})
+}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageParams reads ?page= (1-based) and ?limit= and turns them into a skip
+// and limit pair.
+func pageParams(c *gin.Context) (page, limit int64, err error) {
+	page, err = strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		return 0, 0, errors.New("page must be a positive number")
+	}
+	limit, err = strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)), 10, 64)
+	if err != nil || limit < 1 {
+		return 0, 0, errors.New("limit must be a positive number")
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return page, limit, nil
+}
+
+// dateParam accepts either RFC 3339 or a plain YYYY-MM-DD date. With endOfDay
+// a plain date is moved to the start of the next day, so "to=2024-05-31"
+// still includes orders placed on the 31st.
+func dateParam(c *gin.Context, name string, endOfDay bool) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 timestamp", name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func (app *Application) ListOrders(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	userObjectId, _ := primitive.ObjectIDFromHex(userId)
+
+	page, limit, err := pageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := database.OrderFilter{Skip: (page - 1) * limit, Limit: limit}
+	if status := c.Query("status"); status != "" {
+		filter.Status = models.OrderStatus(status)
+		if !filter.Status.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrInvalidOrderStatus.Error()})
+			return
+		}
+	}
+	if filter.From, err = dateParam(c, "from", false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.To, err = dateParam(c, "to", true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	orders, total, err := app.Orders.ListOrdersByUser(ctx, userObjectId, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": orders,
+		"count":  len(orders),
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+func (app *Application) GetOrder(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	userObjectId, _ := primitive.ObjectIDFromHex(userId)
+
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	order, err := database.GetUserOrder(ctx, app.Orders, userObjectId, orderId)
+	if errors.Is(err, database.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	total, err := order.Total()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order":          order,
+		"items":          order.OrderCart,
+		"subtotal":       order.Price,
+		"discount":       order.Discount,
+		"tax":            order.Tax,
+		"total":          total,
+		"payment_method": order.PaymentMethod,
+		"address":        order.ShippingAddress,
+	})
+}
+
+type cancelOrderRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// CancelOrder lets customers cancel an order that has not shipped yet.
+func (app *Application) CancelOrder(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	userObjectId, _ := primitive.ObjectIDFromHex(userId)
+	issuedBy, _ := primitive.ObjectIDFromHex(c.GetString("uid"))
+
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+
+	var request cancelOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	order, refund, err := database.CancelOrder(ctx, app.Orders, app.Products, app.Coupons, app.Ledger, app.Payments, userObjectId, orderId, request.Reason, issuedBy)
+	respondWithCancelledOrder(c, order, refund, err)
+}
+
+// respondWithCancelledOrder writes the response for an order cancellation.
+func respondWithCancelledOrder(c *gin.Context, order models.Order, refund *models.Refund, err error) {
+	switch {
+	case errors.Is(err, database.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrNotCancellable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": order.Status})
+		return
+	case errors.Is(err, database.ErrPaymentPending), errors.Is(err, database.ErrOrderStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrRefundFailed), errors.Is(err, database.ErrNoProvider):
+		// The order is cancelled all the same; support issues the refund.
+		c.JSON(http.StatusOK, gin.H{"message": "order cancelled, the refund could not be issued yet and will be followed up", "order": order, "refund": refund})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "order cancelled", "order": order, "refund": refund})
+}
+
+// cancelOrderAsAdmin cancels an order on its customer's behalf the same way
+// they would, with the note as the reason.
+func (app *Application) cancelOrderAsAdmin(ctx context.Context, c *gin.Context, orderId primitive.ObjectID, note string) {
+	if strings.TrimSpace(note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note giving the reason is required to cancel an order"})
+		return
+	}
+	issuedBy, err := primitive.ObjectIDFromHex(c.GetString("uid"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token does not identify a user"})
+		return
+	}
+
+	order, err := app.Orders.FindOrderByID(ctx, orderId)
+	if errors.Is(err, database.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cancelled, refund, err := database.CancelOrder(ctx, app.Orders, app.Products, app.Coupons, app.Ledger, app.Payments, order.UserID, orderId, note, issuedBy)
+	respondWithCancelledOrder(c, cancelled, refund, err)
+}
+
+type orderStatusRequest struct {
+	Note string `json:"note"`
+}
+
+func (app *Application) UpdateOrderStatus(c *gin.Context) {
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+
+	status := models.OrderStatus(c.Query("status"))
+	if status == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status is required"})
+		return
+	}
+
+	var request orderStatusRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if status == models.OrderCancelled {
+		app.cancelOrderAsAdmin(ctx, c, orderId, request.Note)
+		return
+	}
+
+	order, err := database.UpdateOrderStatus(ctx, app.Orders, orderId, status, request.Note)
+	switch {
+	case errors.Is(err, database.ErrInvalidOrderStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrIllegalTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": order.Status})
+		return
+	case errors.Is(err, database.ErrOrderStatusChanged), errors.Is(err, database.ErrOrderNotPaid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "order status updated", "order": order})
+}
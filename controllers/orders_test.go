@@ -0,0 +1,47 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAdminCancelsOrder(t *testing.T) {
+	s := newTestServer(t)
+	_, adminToken := s.signUp(adminEmail)
+	_, token := s.signUp("asha@example.com")
+	productID := s.addProduct(adminToken, "kettle", 49900, 5)
+
+	var placed struct {
+		OrderID string `json:"order_id"`
+	}
+	s.expect(s.checkout(token, productID, 2, payments.CardSuccess), http.StatusOK, &placed)
+	cancel := "/admin/orderstatus?status=cancelled&order_id=" + placed.OrderID
+
+	s.expect(s.do(http.MethodPut, cancel, adminToken, nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, cancel, token, gin.H{"note": "customer asked"}), http.StatusForbidden, nil)
+
+	var reply struct {
+		Order  models.Order   `json:"order"`
+		Refund *models.Refund `json:"refund"`
+	}
+	s.expect(s.do(http.MethodPut, cancel, adminToken, gin.H{"note": "customer asked by phone"}), http.StatusOK, &reply)
+	if reply.Order.Status != models.OrderCancelled || reply.Refund == nil || reply.Refund.Amount.Amount != 99800 {
+		t.Errorf("cancel answered order %s with refund %+v, want it cancelled and 99800 refunded", reply.Order.Status, reply.Refund)
+	}
+
+	id, err := primitive.ObjectIDFromHex(productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product, err := s.store.FindProductByID(context.Background(), id); err != nil || product.Stock != 5 {
+		t.Errorf("stock = %d, %v after the cancellation, want 5", product.Stock, err)
+	}
+
+	s.expect(s.do(http.MethodPut, cancel, adminToken, gin.H{"note": "again"}), http.StatusConflict, nil)
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// paymentRequest is the optional body of a checkout. Without one the order
+// is paid cash on delivery. Card details travel in the body rather than the
+// query string so they never end up in access logs.
+type paymentRequest struct {
+	Method string `json:"payment_method" validate:"omitempty,oneof=cod card"`
+	Card   string `json:"card" validate:"required_if=Method card,max=23"`
+}
+
+// bindPayment reads the payment request, writing the error response itself
+// when it is invalid.
+func (app *Application) bindPayment(c *gin.Context) (paymentRequest, bool) {
+	var request paymentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return paymentRequest{}, false
+		}
+	}
+	if err := Validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return paymentRequest{}, false
+	}
+	if request.Method == models.PaymentMethodCard && app.Payments == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "card payments are not available"})
+		return paymentRequest{}, false
+	}
+	return request, true
+}
+
+// respondWithPlacedOrder takes the card payment for an order just placed, if
+// it is paid by card, and writes the response for the order either way.
+func (app *Application) respondWithPlacedOrder(ctx context.Context, c *gin.Context, order models.Order, request paymentRequest) {
+	if request.Method == models.PaymentMethodCard {
+		var err error
+		order, err = database.PayOrder(ctx, app.Orders, app.Payments, order.OrderID, request.Card)
+		if paymentError(c, order, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "order_id": order.OrderID.Hex()})
+			return
+		}
+	}
+
+	c.IndentedJSON(200, gin.H{"message": "successfully placed the order", "order_id": order.OrderID.Hex()})
+}
+
+// paymentError writes the response for a card payment that did not go
+// through and reports whether err was one. The order itself exists either
+// way, so its ID is always included.
+func paymentError(c *gin.Context, order models.Order, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrPaymentPending):
+		c.JSON(http.StatusAccepted, gin.H{"message": err.Error(), "order_id": order.OrderID.Hex()})
+	case errors.Is(err, database.ErrPaymentFailed):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "order_id": order.OrderID.Hex()})
+	case errors.Is(err, database.ErrNotPayable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "payment": order.PaymentMethod})
+	default:
+		return false
+	}
+	return true
+}
+
+// PayOrder retries the card payment of an order whose earlier attempt was
+// declined.
+func (app *Application) PayOrder(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	userObjectId, _ := primitive.ObjectIDFromHex(userId)
+
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+
+	request, ok := app.bindPayment(c)
+	if !ok {
+		return
+	}
+	if request.Method != models.PaymentMethodCard {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only card payments can be retried"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	order, err := database.GetUserOrder(ctx, app.Orders, userObjectId, orderId)
+	if err == nil {
+		order, err = database.PayOrder(ctx, app.Orders, app.Payments, order.OrderID, request.Card)
+	}
+	if paymentError(c, order, err) {
+		return
+	}
+	if errors.Is(err, database.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "order paid", "order": order})
+}
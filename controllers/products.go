@@ -0,0 +1,348 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// productQuery reads the paging, sorting and filter parameters shared by the
+// product listings: page, limit, sort, min_price, max_price, min_rating,
+// category, brand and attr[name]=value for each attribute. A category filter
+// is meant to be widened to its subcategories by the caller.
+func productQuery(c *gin.Context) (query database.ProductQuery, page int64, err error) {
+	page, limit, err := pageParams(c)
+	if err != nil {
+		return query, 0, err
+	}
+	query.Skip = (page - 1) * limit
+	query.Limit = limit
+
+	query.Sort = c.Query("sort")
+	if !database.ValidProductSort(query.Sort) {
+		return query, 0, errors.New("sort must be one of price_asc, price_desc, rating, newest or relevance")
+	}
+
+	if value := c.Query("min_price"); value != "" {
+		if query.MinPrice, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return query, 0, errors.New("min_price must be a number")
+		}
+	}
+	if value := c.Query("max_price"); value != "" {
+		if query.MaxPrice, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return query, 0, errors.New("max_price must be a number")
+		}
+	}
+	if query.MaxPrice > 0 && query.MinPrice > query.MaxPrice {
+		return query, 0, errors.New("min_price must not be greater than max_price")
+	}
+	if value := c.Query("min_rating"); value != "" {
+		rating, err := strconv.ParseUint(value, 10, 8)
+		if err != nil || rating > 5 {
+			return query, 0, errors.New("min_rating must be a number from 0 to 5")
+		}
+		query.MinRating = uint8(rating)
+	}
+
+	if category := normalizeCategory(c.Query("category")); category != "" {
+		query.Categories = []string{category}
+	}
+	query.Brand = strings.TrimSpace(c.Query("brand"))
+	query.Attributes = c.QueryMap("attr")
+	for name := range query.Attributes {
+		if name == "" || strings.ContainsAny(name, ".$") {
+			return query, 0, errors.New("invalid attribute name")
+		}
+	}
+
+	return query, page, nil
+}
+
+// normalizeCategory turns a category into the lowercase form products are
+// stored and filtered by.
+func normalizeCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+// respondWithProducts writes one page of the products matching query and
+// their facets, merged into response. Prices are shown in the currency the
+// request asks for; price filters and facets stay in the catalog currency.
+func (app *Application) respondWithProducts(ctx context.Context, c *gin.Context, query database.ProductQuery, page int64, response gin.H) {
+	rate, ok := app.displayRate(ctx, c)
+	if !ok {
+		return
+	}
+
+	productList, total, err := app.Products.FindProducts(ctx, query)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+	if productList, err = database.ConvertProducts(productList, rate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	facets, err := app.Products.ProductFacets(ctx, query)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	response["products"] = productList
+	response["count"] = len(productList)
+	response["total"] = total
+	response["page"] = page
+	response["limit"] = query.Limit
+	response["facets"] = facets
+	c.JSON(http.StatusOK, response)
+}
+
+// ListProductsAdmin lists the whole catalog, deleted products included. It
+// takes the same parameters as the storefront listing.
+func (app *Application) ListProductsAdmin(c *gin.Context) {
+	query, page, err := productQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.IncludeDeleted = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	products, total, err := app.Products.FindProducts(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+		"count":    len(products),
+		"total":    total,
+		"page":     page,
+		"limit":    query.Limit,
+	})
+}
+
+func (app *Application) GetProductAdmin(c *gin.Context) {
+	productId, err := primitive.ObjectIDFromHex(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	product, err := app.Products.FindProductByID(ctx, productId)
+	if errors.Is(err, database.ErrCantFindProduct) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product": product})
+}
+
+func (app *Application) UpdateProduct(c *gin.Context) {
+	productId, err := primitive.ObjectIDFromHex(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id format"})
+		return
+	}
+
+	var update models.ProductUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := Validate.Struct(update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if update.Brand != nil {
+		*update.Brand = strings.TrimSpace(*update.Brand)
+	}
+	if err := normalizePrices(update.Price, nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if update.Categories != nil {
+		categories, err := database.CheckCategories(ctx, app.Categories, *update.Categories)
+		if errors.Is(err, database.ErrCategoryNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		update.Categories = &categories
+	}
+
+	product, err := app.Products.UpdateProduct(ctx, productId, update)
+	if errors.Is(err, database.ErrCantFindProduct) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "product updated", "product": product})
+}
+
+// DeleteProduct soft-deletes a product: it disappears from the storefront and
+// can no longer be bought, but orders that contain it keep their history.
+func (app *Application) DeleteProduct(c *gin.Context) {
+	productId, err := primitive.ObjectIDFromHex(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err = app.Products.SoftDeleteProduct(ctx, productId)
+	if errors.Is(err, database.ErrCantFindProduct) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "product deleted"})
+}
+
+// normalizePrices checks price, when given, and the prices of any variants
+// that override their product's, filling in the catalog currency.
+func normalizePrices(price *models.Money, variants []models.Variant) error {
+	if price != nil {
+		normalized, err := database.NormalizePrice(*price)
+		if err != nil {
+			return err
+		}
+		*price = normalized
+	}
+	for i := range variants {
+		if variants[i].Price.IsZero() {
+			continue
+		}
+		normalized, err := database.NormalizePrice(variants[i].Price)
+		if err != nil {
+			return err
+		}
+		variants[i].Price = normalized
+	}
+	return nil
+}
+
+// duplicateSKU returns a SKU that appears more than once in variants, after
+// trimming each SKU in place, or "" when they are all distinct.
+func duplicateSKU(variants []models.Variant) string {
+	seen := map[string]bool{}
+	for i := range variants {
+		variants[i].SKU = strings.TrimSpace(variants[i].SKU)
+		if seen[variants[i].SKU] {
+			return variants[i].SKU
+		}
+		seen[variants[i].SKU] = true
+	}
+	return ""
+}
+
+// SaveVariant adds a variant to a product or updates the one with the same
+// SKU. The stock given is only used for a new variant; existing stock changes
+// go through adjuststock so that they are recorded.
+func (app *Application) SaveVariant(c *gin.Context) {
+	productId, err := primitive.ObjectIDFromHex(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id format"})
+		return
+	}
+
+	var variant models.Variant
+	if err := c.ShouldBindJSON(&variant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	variant.SKU = strings.TrimSpace(variant.SKU)
+	if err := Validate.Struct(variant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	variants := []models.Variant{variant}
+	if err := normalizePrices(nil, variants); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	variant = variants[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	product, err := app.Products.SaveVariant(ctx, productId, variant)
+	if errors.Is(err, database.ErrCantFindProduct) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, database.ErrSKUExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "variant saved", "product": product})
+}
+
+// RemoveVariant deletes a variant. Carts still holding it show the line as
+// unavailable; past orders keep their copy.
+func (app *Application) RemoveVariant(c *gin.Context) {
+	productId, err := primitive.ObjectIDFromHex(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err = app.Products.RemoveVariant(ctx, productId, c.Query("sku"))
+	if errors.Is(err, database.ErrVariantNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "variant removed"})
+}
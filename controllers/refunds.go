@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// refundRequest names the lines to refund; without any the whole order is
+// refunded, less what was refunded before.
+type refundRequest struct {
+	Lines  []models.RefundLine `json:"lines" validate:"max=100,dive"`
+	Reason string              `json:"reason" validate:"required,max=500"`
+}
+
+// RefundOrder refunds an order in full or some of its lines, for support
+// staff and admins.
+func (app *Application) RefundOrder(c *gin.Context) {
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+	issuedBy, err := primitive.ObjectIDFromHex(c.GetString("uid"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token does not identify a user"})
+		return
+	}
+
+	var request refundRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	refund, err := database.RefundOrder(ctx, app.Orders, app.Ledger, app.Payments, orderId, request.Lines, request.Reason, issuedBy)
+	if refundError(c, refund, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "refund issued", "refund": refund})
+}
+
+// refundError writes the response for a refund that could not be issued and
+// reports whether err was one.
+func refundError(c *gin.Context, refund models.Refund, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrInvalidRefund):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrNotRefundable), errors.Is(err, database.ErrOverRefund),
+		errors.Is(err, database.ErrNothingToRefund), errors.Is(err, database.ErrRefundConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrRefundFailed), errors.Is(err, database.ErrNoProvider):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "refund": refund})
+	default:
+		return false
+	}
+	return true
+}
+
+// ListRefunds shows what has been refunded on an order and what is left.
+func (app *Application) ListRefunds(c *gin.Context) {
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	order, err := app.Orders.FindOrderByID(ctx, orderId)
+	if errors.Is(err, database.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	total, err := order.Total()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"refunds": order.Refunds, "total": total, "refunded": order.Refunded()})
+}
+
+// ListLedger lists the refunds owed on cash on delivery orders.
+func (app *Application) ListLedger(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)), 10, 64)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	entries, err := app.Ledger.ListLedgerEntries(ctx, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type returnRequest struct {
+	Lines   []models.ReturnLine `json:"lines" validate:"required,min=1,max=100,dive"`
+	Comment string              `json:"comment" validate:"max=1000"`
+	Photos  []string            `json:"photos" validate:"max=5,dive,url"`
+}
+
+// RequestReturn lets customers send back lines of a delivered order.
+func (app *Application) RequestReturn(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	userObjectId, _ := primitive.ObjectIDFromHex(userId)
+
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+
+	var request returnRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ret, err := database.RequestReturn(ctx, app.Orders, app.Returns, userObjectId, orderId, request.Lines, request.Comment, request.Photos)
+	if returnError(c, ret, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "return requested", "return": ret})
+}
+
+// ListOrderReturns shows customers the returns of one of their orders.
+func (app *Application) ListOrderReturns(c *gin.Context) {
+	userId, ok := app.actingUserID(c)
+	if !ok {
+		return
+	}
+	userObjectId, _ := primitive.ObjectIDFromHex(userId)
+
+	orderId, err := primitive.ObjectIDFromHex(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	returns, err := database.GetUserReturns(ctx, app.Orders, app.Returns, userObjectId, orderId)
+	if errors.Is(err, database.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns})
+}
+
+// ListReturns lists returns for support staff, optionally only those in
+// ?status=, such as the requested ones waiting for review.
+func (app *Application) ListReturns(c *gin.Context) {
+	status := models.ReturnStatus(c.Query("status"))
+	if status != "" && !status.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrInvalidReturnStatus.Error()})
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)), 10, 64)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	returns, err := app.Returns.ListReturns(ctx, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns})
+}
+
+type reviewReturnRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=approve reject"`
+	Note     string `json:"note" validate:"max=500"`
+}
+
+// ReviewReturn approves or rejects a requested return.
+func (app *Application) ReviewReturn(c *gin.Context) {
+	returnId, by, ok := returnAction(c)
+	if !ok {
+		return
+	}
+
+	var request reviewReturnRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ret, err := database.ReviewReturn(ctx, app.Returns, returnId, request.Decision == "approve", request.Note, by)
+	respondWithReturn(c, ret, err)
+}
+
+type receiveReturnRequest struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+// ReceiveReturn records that an approved return arrived at the warehouse,
+// which restocks and refunds it.
+func (app *Application) ReceiveReturn(c *gin.Context) {
+	returnId, by, ok := returnAction(c)
+	if !ok {
+		return
+	}
+
+	var request receiveReturnRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := Validate.Struct(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ret, err := database.ReceiveReturn(ctx, app.Returns, app.Orders, app.Products, app.Inventory, app.Ledger, app.Payments, returnId, request.Note, by)
+	respondWithReturn(c, ret, err)
+}
+
+// CompleteReturn retries the refund of a received return.
+func (app *Application) CompleteReturn(c *gin.Context) {
+	returnId, by, ok := returnAction(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ret, err := database.CompleteReturn(ctx, app.Returns, app.Orders, app.Ledger, app.Payments, returnId, by)
+	respondWithReturn(c, ret, err)
+}
+
+// returnAction reads the return a support action is for and the agent
+// taking it. On failure the response has already been written and ok is
+// false.
+func returnAction(c *gin.Context) (returnID primitive.ObjectID, by primitive.ObjectID, ok bool) {
+	returnID, err := primitive.ObjectIDFromHex(c.Query("return_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid return id format"})
+		return returnID, by, false
+	}
+	by, err = primitive.ObjectIDFromHex(c.GetString("uid"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token does not identify a user"})
+		return returnID, by, false
+	}
+	return returnID, by, true
+}
+
+func respondWithReturn(c *gin.Context, ret models.Return, err error) {
+	if returnError(c, ret, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "return " + string(ret.Status), "return": ret})
+}
+
+// returnError writes the response for a return that could not be requested
+// or moved on and reports whether err was one. A return whose refund failed
+// has still been received, so it is included.
+func returnError(c *gin.Context, ret models.Return, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrOrderNotFound), errors.Is(err, database.ErrReturnNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrInvalidReturn), errors.Is(err, database.ErrOverReturn):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrNotReturnable), errors.Is(err, database.ErrReturnWindowClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrIllegalReturnTransition), errors.Is(err, database.ErrReturnChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": ret.Status})
+	case ret.Status == models.ReturnReceived && err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": "the return was received but could not be refunded: " + err.Error(), "return": ret})
+	default:
+		return false
+	}
+	return true
+}
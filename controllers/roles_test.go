@@ -0,0 +1,79 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+func TestRoleGating(t *testing.T) {
+	s := newTestServer(t)
+	_, adminToken := s.signUp(adminEmail)
+	_, customerToken := s.signUp("asha@example.com")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"admin route without a token", http.MethodGet, "/admin/products", "", http.StatusUnauthorized},
+		{"admin route as a customer", http.MethodGet, "/admin/products", customerToken, http.StatusForbidden},
+		{"admin route as an admin", http.MethodGet, "/admin/products", adminToken, http.StatusOK},
+		{"support route as a customer", http.MethodGet, "/support/returns", customerToken, http.StatusForbidden},
+		{"support route as an admin", http.MethodGet, "/support/returns", adminToken, http.StatusOK},
+		{"customer route without a token", http.MethodGet, "/listcart", "", http.StatusUnauthorized},
+		{"customer route as a customer", http.MethodGet, "/listcart", customerToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.expect(s.do(tt.method, tt.path, tt.token, nil), tt.status, nil)
+		})
+	}
+}
+
+func TestRoleChangeAppliesOnRefresh(t *testing.T) {
+	s := newTestServer(t)
+	_, adminToken := s.signUp(adminEmail)
+
+	var login struct {
+		UserID       string `json:"user_id"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	s.expect(s.do(http.MethodPost, "/users/signup", "", gin.H{
+		"first_name": "Ravi",
+		"last_name":  "Support",
+		"email":      "ravi@example.com",
+		"password":   "secret123",
+		"phone":      "9111111111",
+	}), http.StatusCreated, &login)
+
+	s.expect(s.do(http.MethodPut, "/admin/userrole?user_id="+login.UserID+"&role="+models.RoleSupport, adminToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, "/admin/userrole?user_id="+login.UserID+"&role=owner", adminToken, nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/support/returns", login.AccessToken, nil), http.StatusForbidden, nil)
+
+	var refreshed struct {
+		AccessToken string `json:"access_token"`
+	}
+	s.expect(s.do(http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": login.RefreshToken}), http.StatusOK, &refreshed)
+	s.expect(s.do(http.MethodGet, "/support/returns", refreshed.AccessToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/admin/products", refreshed.AccessToken, nil), http.StatusForbidden, nil)
+
+	s.expect(s.do(http.MethodGet, "/admin/products", login.RefreshToken, nil), http.StatusUnauthorized, nil)
+}
+
+func TestActingOnBehalfOfAnotherUser(t *testing.T) {
+	s := newTestServer(t)
+	_, adminToken := s.signUp(adminEmail)
+	ashaID, ashaToken := s.signUp("asha@example.com")
+	_, raviToken := s.signUp("ravi@example.com")
+
+	s.expect(s.do(http.MethodGet, "/listcart?user_id="+ashaID, ashaToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/listcart?user_id="+ashaID, raviToken, nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/listcart?user_id="+ashaID, adminToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/listcart?user_id=nobody", adminToken, nil), http.StatusBadRequest, nil)
+}
@@ -0,0 +1,166 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/controllers"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"github.com/patil-prathamesh/e-commerce-golang/routes"
+	"github.com/patil-prathamesh/e-commerce-golang/tokens"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	webhookSecret = "whsec_test"
+	adminEmail    = "admin@example.com"
+)
+
+// testServer is the whole API, routed as main routes it, on the memory store
+// and with card payments through the fake provider.
+type testServer struct {
+	t        *testing.T
+	store    *database.MemoryStore
+	provider *payments.FakeProvider
+	router   *gin.Engine
+	users    int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	tokens.SECRET_KEY = "test-secret"
+	t.Setenv("ADMIN_EMAILS", adminEmail)
+
+	store := database.NewMemoryStore()
+	provider := payments.NewFakeProvider(webhookSecret, "")
+	app := controllers.NewApplication(store)
+	app.Payments = provider
+
+	router := gin.New()
+	routes.UserRoutes(router, app)
+	routes.PaymentRoutes(router, app)
+	routes.AdminRoutes(router, app)
+	routes.SupportRoutes(router, app)
+	routes.CustomerRoutes(router, app)
+
+	return &testServer{t: t, store: store, provider: provider, router: router}
+}
+
+// do sends body, encoded as JSON unless it is already bytes, to path as the
+// holder of token, if any, and returns the response.
+func (s *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var payload []byte
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		payload = body
+	default:
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	request := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response := httptest.NewRecorder()
+	s.router.ServeHTTP(response, request)
+	return response
+}
+
+// expect fails the test unless response has status, and decodes its body into
+// into when that is not nil.
+func (s *testServer) expect(response *httptest.ResponseRecorder, status int, into any) {
+	s.t.Helper()
+	if response.Code != status {
+		s.t.Fatalf("status = %d, want %d: %s", response.Code, status, response.Body)
+	}
+	if into != nil {
+		if err := json.Unmarshal(response.Body.Bytes(), into); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+}
+
+// signUp registers a user under email and returns their ID and access
+// token. Signing up as adminEmail makes an admin.
+func (s *testServer) signUp(email string) (userID string, token string) {
+	s.t.Helper()
+	s.users++
+	var reply struct {
+		UserID      string `json:"user_id"`
+		AccessToken string `json:"access_token"`
+	}
+	s.expect(s.do(http.MethodPost, "/users/signup", "", gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      email,
+		"password":   "secret123",
+		"phone":      fmt.Sprintf("90000%05d", s.users),
+	}), http.StatusCreated, &reply)
+	return reply.UserID, reply.AccessToken
+}
+
+// addProduct adds a product at price paise with stock units in stock and
+// returns its ID.
+func (s *testServer) addProduct(adminToken string, name string, price int64, stock int64) string {
+	s.t.Helper()
+	var reply struct {
+		ProductID string `json:"product_id"`
+	}
+	s.expect(s.do(http.MethodPost, "/admin/addproduct", adminToken, gin.H{
+		"product_name": name,
+		"price":        models.NewMoney(price, models.BaseCurrency),
+		"image":        "https://example.com/" + name + ".png",
+	}), http.StatusCreated, &reply)
+	if stock > 0 {
+		s.expect(s.do(http.MethodPost, "/admin/adjuststock", adminToken, gin.H{
+			"product_id": reply.ProductID,
+			"delta":      stock,
+			"reason":     "restock",
+		}), http.StatusOK, nil)
+	}
+	return reply.ProductID
+}
+
+// checkout buys quantity of product through the cart with token, paying
+// with card, or cash on delivery when card is empty, and returns the
+// response to the checkout.
+func (s *testServer) checkout(token string, productID string, quantity uint64, card string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/addtocart?product_id=%s&quantity=%d", productID, quantity), token, nil), http.StatusOK, nil)
+	payment := gin.H{"payment_method": models.PaymentMethodCOD}
+	if card != "" {
+		payment = gin.H{"payment_method": models.PaymentMethodCard, "card": card}
+	}
+	return s.do(http.MethodPost, "/cartcheckout", token, payment)
+}
+
+// order reads the order orderID straight from the store.
+func (s *testServer) order(orderID string) models.Order {
+	s.t.Helper()
+	id, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	order, err := s.store.FindOrderByID(context.Background(), id)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return order
+}
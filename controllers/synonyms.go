@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (app *Application) ListSynonyms(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	groups, err := app.Synonyms.ListSynonymGroups(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"synonyms": groups})
+}
+
+// SaveSynonyms creates a synonym group, or replaces the terms of the group
+// given by synonym_id. Searches pick the change up straight away.
+func (app *Application) SaveSynonyms(c *gin.Context) {
+	var group models.SynonymGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate.Struct(group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	terms, err := database.NormalizeSynonymTerms(group.Terms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group.Terms = terms
+	group.UpdatedAt = time.Now()
+	group.ID = primitive.NewObjectID()
+	if groupId := c.Query("synonym_id"); groupId != "" {
+		if group.ID, err = primitive.ObjectIDFromHex(groupId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid synonym id format"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if err := app.Synonyms.SaveSynonymGroup(ctx, group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+	app.Search.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "synonyms saved", "synonyms": group})
+}
+
+func (app *Application) DeleteSynonyms(c *gin.Context) {
+	groupId, err := primitive.ObjectIDFromHex(c.Query("synonym_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid synonym id format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err = app.Synonyms.DeleteSynonymGroup(ctx, groupId)
+	if errors.Is(err, database.ErrSynonymNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+	app.Search.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "synonyms deleted"})
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+)
+
+// maxWebhookBody bounds what is read of a webhook request.
+const maxWebhookBody = 1 << 20
+
+// PaymentWebhook receives the payment provider's notifications. It answers
+// with an error only when handling the event failed, which makes the
+// provider deliver it again later.
+func (app *Application) PaymentWebhook(c *gin.Context) {
+	if app.Payments == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "card payments are not available"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	event, err := app.Payments.ParseEvent(body, c.Request.Header)
+	if errors.Is(err, payments.ErrInvalidEvent) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	record, err := database.ReceivePaymentEvent(ctx, app.PaymentEvents, app.Orders, app.Payments, event, body)
+	if errors.Is(err, database.ErrEventExists) {
+		c.JSON(http.StatusOK, gin.H{"message": "event already received", "outcome": record.Outcome})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if record.Outcome == models.EventFailed {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": record.Error})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "event received", "outcome": record.Outcome})
+}
+
+func (app *Application) ListPaymentEvents(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)), 10, 64)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	events, err := app.PaymentEvents.ListPaymentEvents(ctx, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong, please try after some time"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// ReplayPaymentEvent handles a received payment event again.
+func (app *Application) ReplayPaymentEvent(c *gin.Context) {
+	if app.Payments == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "card payments are not available"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	record, err := database.ReplayPaymentEvent(ctx, app.PaymentEvents, app.Orders, app.Payments, c.Query("event_id"))
+	if errors.Is(err, database.ErrEventNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, database.ErrEventMismatch) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "event replayed", "event": record})
+}
+
+// SettleFakePayment stands in for the fake provider deciding a payment whose
+// authorization timed out: it makes the provider send the outcome to the
+// webhook it was configured with. The body and signature sent are returned so that the
+// delivery can be repeated by hand.
+func (app *Application) SettleFakePayment(c *gin.Context) {
+	fake, ok := app.Payments.(*payments.FakeProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the fake payment provider is not in use"})
+		return
+	}
+
+	event, err := fake.Settle(c.Query("payment_id"), c.Query("approve") == "true")
+	if errors.Is(err, payments.ErrPaymentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	body, signature, err := fake.Deliver(ctx, event)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "payload": string(body), "signature": signature})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "event delivered", "payload": string(body), "signature": signature})
+}
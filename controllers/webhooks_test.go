@@ -0,0 +1,195 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+)
+
+// webhookTest is a server with a card order whose payment timed out and
+// waits for the provider's notification.
+type webhookTest struct {
+	*testServer
+	adminToken string
+	orderID    string
+}
+
+func newWebhookTest(t *testing.T) *webhookTest {
+	t.Helper()
+	s := newTestServer(t)
+	_, adminToken := s.signUp(adminEmail)
+	_, token := s.signUp("asha@example.com")
+	productID := s.addProduct(adminToken, "kettle", 49900, 10)
+
+	var reply struct {
+		OrderID string `json:"order_id"`
+	}
+	s.expect(s.checkout(token, productID, 1, payments.CardTimeout), http.StatusAccepted, &reply)
+	return &webhookTest{testServer: s, adminToken: adminToken, orderID: reply.OrderID}
+}
+
+// settle has the provider decide the order's payment and returns the
+// notification it sends about it.
+func (w *webhookTest) settle(approve bool) (payments.Event, []byte) {
+	w.t.Helper()
+	event, err := w.provider.Settle(w.order(w.orderID).PaymentMethod.TransactionID, approve)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	return event, body
+}
+
+// deliver posts body to the webhook with signature, if any, the way the
+// provider does.
+func (w *webhookTest) deliver(body []byte, signature string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		request.Header.Set(payments.FakeSignatureHeader, signature)
+	}
+	response := httptest.NewRecorder()
+	w.router.ServeHTTP(response, request)
+	return response
+}
+
+// recordUnhandled records event as received but never handled, as when the
+// server stopped between the two.
+func (w *webhookTest) recordUnhandled(event payments.Event, body []byte) models.PaymentEvent {
+	w.t.Helper()
+	record := models.PaymentEvent{
+		ID:         "fake:" + event.ID,
+		Provider:   "fake",
+		EventID:    event.ID,
+		Type:       event.Type,
+		PaymentID:  event.PaymentID,
+		Reference:  event.Reference,
+		Amount:     event.Amount,
+		Payload:    string(body),
+		ReceivedAt: time.Now(),
+	}
+	if err := w.store.InsertPaymentEvent(context.Background(), record); err != nil {
+		w.t.Fatal(err)
+	}
+	return record
+}
+
+// timesPaid counts the order's moves to paid.
+func timesPaid(order models.Order) int {
+	paid := 0
+	for _, change := range order.StatusHistory {
+		if change.To == models.OrderPaid {
+			paid++
+		}
+	}
+	return paid
+}
+
+func TestPaymentWebhookRejectsBadSignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature func(body []byte) string
+	}{
+		{"missing", func([]byte) string { return "" }},
+		{"wrong secret", func(body []byte) string { return payments.Sign("not the secret", body) }},
+		{"other body", func(body []byte) string { return payments.Sign(webhookSecret, append(body, ' ')) }},
+		{"garbage", func([]byte) string { return "t=1,v1=deadbeef" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWebhookTest(t)
+			_, body := w.settle(true)
+
+			w.expect(w.deliver(body, tt.signature(body)), http.StatusUnauthorized, nil)
+			if order := w.order(w.orderID); order.Status != models.OrderPending || order.PaymentMethod.Status != models.PaymentPending {
+				t.Errorf("order is %s with payment %s after an unsigned event, want it still pending", order.Status, order.PaymentMethod.Status)
+			}
+			if events, err := w.store.ListPaymentEvents(context.Background(), 10); err != nil || len(events) != 0 {
+				t.Errorf("%d event(s), %v recorded from an unsigned request, want none", len(events), err)
+			}
+		})
+	}
+}
+
+func TestPaymentWebhookAuthorizedPaysOrder(t *testing.T) {
+	w := newWebhookTest(t)
+	_, body := w.settle(true)
+
+	w.expect(w.deliver(body, payments.Sign(webhookSecret, body)), http.StatusOK, nil)
+	if order := w.order(w.orderID); order.Status != models.OrderPaid || order.PaymentMethod.Status != models.PaymentCaptured {
+		t.Errorf("order is %s with payment %s, want %s with payment %s", order.Status, order.PaymentMethod.Status, models.OrderPaid, models.PaymentCaptured)
+	}
+}
+
+func TestPaymentWebhookDedupesEvents(t *testing.T) {
+	w := newWebhookTest(t)
+	event, body := w.settle(true)
+	signature := payments.Sign(webhookSecret, body)
+
+	w.expect(w.deliver(body, signature), http.StatusOK, nil)
+	first, err := w.store.FindPaymentEvent(context.Background(), "fake:"+event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reply struct {
+		Message string `json:"message"`
+	}
+	w.expect(w.deliver(body, signature), http.StatusOK, &reply)
+	if reply.Message != "event already received" {
+		t.Errorf("redelivery answered %q, want it recognised as already received", reply.Message)
+	}
+
+	second, err := w.store.FindPaymentEvent(context.Background(), "fake:"+event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.ProcessedAt.Equal(first.ProcessedAt) {
+		t.Errorf("redelivered event was processed again at %v", second.ProcessedAt)
+	}
+	if paid := timesPaid(w.order(w.orderID)); paid != 1 {
+		t.Errorf("order moved to paid %d times, want once", paid)
+	}
+}
+
+func TestPaymentWebhookHandlesUnfinishedEvent(t *testing.T) {
+	w := newWebhookTest(t)
+	event, body := w.settle(true)
+	w.recordUnhandled(event, body)
+
+	w.expect(w.deliver(body, payments.Sign(webhookSecret, body)), http.StatusOK, nil)
+	if order := w.order(w.orderID); order.Status != models.OrderPaid {
+		t.Errorf("order is %s after redelivering an unhandled event, want %s", order.Status, models.OrderPaid)
+	}
+}
+
+func TestReplayPaymentEvent(t *testing.T) {
+	w := newWebhookTest(t)
+	event, body := w.settle(true)
+	record := w.recordUnhandled(event, body)
+
+	w.expect(w.do(http.MethodPost, "/admin/paymentevent/replay?event_id="+record.ID, w.adminToken, nil), http.StatusOK, nil)
+
+	replayed, err := w.store.FindPaymentEvent(context.Background(), record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Outcome != models.EventApplied || replayed.Replays != 1 {
+		t.Errorf("replayed event outcome = %q after %d replay(s), want %q after 1", replayed.Outcome, replayed.Replays, models.EventApplied)
+	}
+	if order := w.order(w.orderID); order.Status != models.OrderPaid || order.PaymentMethod.Status != models.PaymentCaptured {
+		t.Errorf("order is %s with payment %s after the replay, want %s with payment %s", order.Status, order.PaymentMethod.Status, models.OrderPaid, models.PaymentCaptured)
+	}
+
+	w.expect(w.do(http.MethodPost, "/admin/paymentevent/replay?event_id=fake:unknown", w.adminToken, nil), http.StatusNotFound, nil)
+}
@@ -3,13 +3,11 @@ package database
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
-	"time"
 
 	"github.com/patil-prathamesh/e-commerce-golang/models"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
@@ -20,35 +18,131 @@ var (
 	ErrCantRemoveItemCart = errors.New("cannot remove this item from the cart")
 	ErrCantGetItem        = errors.New("was unable to get the item from the cart")
 	ErrCantBuyCartItem    = errors.New("cannot update the purchase")
+	ErrInvalidQuantity    = errors.New("quantity must be greater than zero")
+	ErrInvalidCartAction  = errors.New("action must be one of set, increment or decrement")
+	ErrVariantRequired    = errors.New("this product comes in variants, choose one by its sku")
+	ErrInvalidPrice       = errors.New("price must be a positive amount in minor units")
+	ErrQuantityTooLarge   = fmt.Errorf("a cart line can hold at most %d units", MaxLineQuantity)
 )
 
-func AddProductToCart(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, userID string) error {
-	searchFromDb, err := prodCollection.Find(ctx, bson.M{"_id": productID})
+// MaxLineQuantity is the most units of one product or variant a cart line,
+// and so an order line, can hold.
+const MaxLineQuantity = 100
 
+const (
+	CartActionSet       = "set"
+	CartActionIncrement = "increment"
+	CartActionDecrement = "decrement"
+)
+
+func AddProductToCart(ctx context.Context, products ProductStore, users UserStore, productID primitive.ObjectID, sku string, userID string, quantity uint64) error {
+	if quantity == 0 {
+		return ErrInvalidQuantity
+	}
+	if quantity > MaxLineQuantity {
+		return ErrQuantityTooLarge
+	}
+
+	product, err := products.FindProductByID(ctx, productID)
 	if err != nil {
 		log.Println(err)
 		return ErrCantFindProduct
 	}
+	if product.Deleted {
+		return ErrCantFindProduct
+	}
+	variant, err := resolveVariant(product, sku)
+	if err != nil {
+		return err
+	}
 
-	var productCart []models.ProductUser
-	err = searchFromDb.All(ctx, &productCart)
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
 
 	if err != nil {
 		log.Println(err)
-		return ErrCantDecodeProducts
+		return ErrUserIdIsNotValid
 	}
 
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+	for _, item := range normalizeCart(user.UserCart) {
+		if item.ProductID == productID && item.VariantSKU == variant.SKU && quantity > MaxLineQuantity-min(item.Quantity, MaxLineQuantity) {
+			return ErrQuantityTooLarge
+		}
+	}
+
+	err = users.AddCartItem(ctx, userObjectID, cartItemFromProduct(product, variant, quantity))
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+
+	return nil
+}
 
+// UpdateCartQuantity changes the quantity of a single cart line. A line whose
+// quantity drops to zero is removed from the cart.
+func UpdateCartQuantity(ctx context.Context, users UserStore, productID primitive.ObjectID, sku string, userID string, action string, quantity uint64) error {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
 		return ErrUserIdIsNotValid
 	}
 
-	filter := bson.M{"_id": userObjectID}
-	update := bson.M{"$push": bson.M{"user_cart": bson.M{"$each": productCart}}}
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		log.Println(err)
+		return ErrCantGetItem
+	}
 
-	_, err = userCollection.UpdateOne(ctx, filter, update)
+	var current uint64
+	for _, item := range normalizeCart(user.UserCart) {
+		if item.ProductID == productID && item.VariantSKU == sku {
+			current = item.Quantity
+			break
+		}
+	}
+	if current == 0 {
+		return ErrCartItemNotFound
+	}
+
+	var next uint64
+	switch action {
+	case CartActionSet:
+		next = quantity
+	case CartActionIncrement:
+		if quantity == 0 {
+			return ErrInvalidQuantity
+		}
+		if quantity > MaxLineQuantity-min(current, MaxLineQuantity) {
+			return ErrQuantityTooLarge
+		}
+		next = current + quantity
+	case CartActionDecrement:
+		if quantity == 0 {
+			return ErrInvalidQuantity
+		}
+		if quantity < current {
+			next = current - quantity
+		}
+	default:
+		return ErrInvalidCartAction
+	}
+	if next > MaxLineQuantity {
+		return ErrQuantityTooLarge
+	}
+
+	if next == 0 {
+		err = users.PullCartItem(ctx, userObjectID, productID, sku)
+	} else {
+		err = users.SetCartItemQuantity(ctx, userObjectID, productID, sku, next)
+	}
+	if errors.Is(err, ErrCartItemNotFound) {
+		return err
+	}
 	if err != nil {
 		log.Println(err)
 		return ErrCantUpdateUser
@@ -57,17 +151,14 @@ func AddProductToCart(ctx context.Context, prodCollection, userCollection *mongo
 	return nil
 }
 
-func RemoveCartItem(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, userID string) error {
+func RemoveCartItem(ctx context.Context, products ProductStore, users UserStore, productID primitive.ObjectID, sku string, userID string) error {
 	userObjectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
 		return ErrUserIdIsNotValid
 	}
 
-	filter := bson.M{"_id": userObjectID}
-	update := bson.M{"$pull": bson.M{"user_cart": bson.M{"_id": productID}}}
-
-	_, err = userCollection.UpdateOne(ctx, filter, update)
+	err = users.PullCartItem(ctx, userObjectID, productID, sku)
 	if err != nil {
 		return ErrCantRemoveItemCart
 	}
@@ -75,88 +166,444 @@ func RemoveCartItem(ctx context.Context, prodCollection, userCollection *mongo.C
 	return nil
 }
 
-func BuyItemFromCart(ctx context.Context, userCollection *mongo.Collection, userID string) error {
+// CartSummary is the priced view of a user's cart.
+type CartSummary struct {
+	Items []models.ProductUser `json:"items"`
+	// Unavailable lists lines whose product or variant has since been removed
+	// from the catalog; they are left out of Total and block checkout.
+	Unavailable []models.ProductUser `json:"unavailable,omitempty"`
+	Subtotal    models.Money         `json:"subtotal"`
+	// Promotions breaks down what each automatic promotion took off.
+	Promotions     []models.PromotionSaving `json:"promotions,omitempty"`
+	Coupon         string                   `json:"coupon,omitempty"`
+	CouponDiscount models.Money             `json:"coupon_discount"`
+	// CouponError says why the applied coupon currently gives no discount,
+	// for example because the cart fell below its minimum.
+	CouponError string `json:"coupon_error,omitempty"`
+	// Discount is the promotions and the coupon together.
+	Discount models.Money `json:"discount"`
+	// Tax is estimated for the user's first address. It is part of Total
+	// already when TaxInclusive, and added to it otherwise.
+	Tax          models.Money `json:"tax"`
+	TaxInclusive bool         `json:"tax_inclusive"`
+	Total        models.Money `json:"total"`
+}
+
+// GetCart prices the user's cart, applying the running promotions first and
+// the cart's coupon, if any, to what is left, and then tax.
+func GetCart(ctx context.Context, users UserStore, products ProductStore, coupons CouponStore, categories CategoryStore, promotions PromotionStore, taxes TaxStore, userID string) (CartSummary, error) {
 	userObjectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
-		return ErrUserIdIsNotValid
+		return CartSummary{}, ErrUserIdIsNotValid
 	}
-	var user models.User
 
-	err = userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user)
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		log.Println(err)
+		return CartSummary{}, ErrCantGetItem
+	}
 
+	cart, unavailable, err := priceCart(ctx, products, user.UserCart)
 	if err != nil {
 		log.Println(err)
-		return ErrCantGetItem
+		return CartSummary{}, ErrCantGetItem
 	}
-	if len(user.UserCart) == 0 {
-		return errors.New("cart is empty")
+
+	address, _ := shippingAddress(user, "")
+	pricing, err := discountCart(ctx, products, coupons, categories, promotions, taxes, userObjectID, user.CouponCode, address, cart)
+	if err != nil {
+		return CartSummary{}, err
 	}
 
-	var total uint64
+	summary := CartSummary{
+		Items:          cart,
+		Unavailable:    unavailable,
+		Subtotal:       pricing.subtotal,
+		Promotions:     pricing.promotions,
+		Coupon:         user.CouponCode,
+		CouponDiscount: pricing.couponDiscount,
+		Discount:       pricing.discount,
+		Tax:            pricing.tax,
+		TaxInclusive:   PricesIncludeTax,
+		Total:          pricing.total,
+	}
+	if pricing.couponErr != nil {
+		summary.CouponError = pricing.couponErr.Error()
+	}
+	return summary, nil
+}
 
-	for _, v := range user.UserCart {
-		total += v.Price
+// cartPricing is a priced cart with its promotions and coupon applied.
+type cartPricing struct {
+	subtotal       models.Money
+	promotions     []models.PromotionSaving
+	coupon         models.Coupon
+	couponDiscount models.Money
+	// couponErr says why the coupon gives no discount.
+	couponErr error
+	discount  models.Money
+	tax       models.Money
+	taxLines  []models.TaxLine
+	total     models.Money
+}
+
+// discountCart applies the running promotions to cart and then the coupon
+// named by couponCode, if any, to what is left. The coupon never takes the
+// total below zero. What remains is taxed for shipping to address.
+func discountCart(ctx context.Context, products ProductStore, coupons CouponStore, categories CategoryStore, promotions PromotionStore, taxes TaxStore, userID primitive.ObjectID, couponCode string, address models.Address, cart []models.ProductUser) (cartPricing, error) {
+	var pricing cartPricing
+	var err error
+	if pricing.subtotal, err = CartTotal(cart); err != nil {
+		return cartPricing{}, err
 	}
 
-	orderID := primitive.NewObjectID()
+	var promotionDiscount models.Money
+	pricing.promotions, promotionDiscount, err = ApplyPromotions(ctx, promotions, cart)
+	if err != nil {
+		return cartPricing{}, ErrCantGetItem
+	}
+	remaining, err := pricing.subtotal.Sub(promotionDiscount)
+	if err != nil {
+		return cartPricing{}, err
+	}
 
-	newOrder := models.Order{
-		OrderID:       orderID,
-		OrderCart:     user.UserCart,
-		OrderedAt:     time.Now(),
-		Price:         total,
-		Discount:      0,
-		PaymentMethod: models.Payment{Digital: false, COD: true},
+	if couponCode != "" {
+		pricing.coupon, pricing.couponDiscount, pricing.couponErr = findCartCoupon(ctx, coupons, products, categories, couponCode, userID, cart)
+		if pricing.couponDiscount, err = pricing.couponDiscount.Min(remaining); err != nil {
+			return cartPricing{}, err
+		}
 	}
 
-	filter := bson.M{"_id": userObjectID}
-	update := bson.M{
-		"$push": bson.M{"orders": newOrder},
-		"$set":  bson.M{"user_cart": []models.ProductUser{}}, // Clear cart after purchase
+	if pricing.discount, err = promotionDiscount.Add(pricing.couponDiscount); err != nil {
+		return cartPricing{}, err
+	}
+	if pricing.total, err = pricing.subtotal.Sub(pricing.discount); err != nil {
+		return cartPricing{}, err
+	}
+
+	if pricing.taxLines, pricing.tax, err = taxCart(ctx, taxes, address, cart, pricing.discount); err != nil {
+		return cartPricing{}, err
+	}
+	if !PricesIncludeTax {
+		if pricing.total, err = pricing.total.Add(pricing.tax); err != nil {
+			return cartPricing{}, err
+		}
 	}
+	return pricing, nil
+}
+
+// priceCart folds the cart into one line per product variant and refreshes
+// each line's name, price and image from the catalog, since the cart only
+// holds a copy taken when the product was added. Lines whose product is gone
+// or deleted, or whose variant no longer exists, are returned separately.
+func priceCart(ctx context.Context, products ProductStore, items []models.ProductUser) (cart []models.ProductUser, unavailable []models.ProductUser, err error) {
+	cart = []models.ProductUser{}
+	for _, item := range normalizeCart(items) {
+		product, err := products.FindProductByID(ctx, item.ProductID)
+		if errors.Is(err, ErrCantFindProduct) || (err == nil && product.Deleted) {
+			unavailable = append(unavailable, item)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		variant, err := resolveVariant(product, item.VariantSKU)
+		if err != nil {
+			unavailable = append(unavailable, item)
+			continue
+		}
+		cart = append(cart, cartItemFromProduct(product, variant, item.Quantity))
+	}
+	return cart, unavailable, nil
+}
 
-	_, err = userCollection.UpdateOne(ctx, filter, update)
+// checkoutCart is priceCart for checkout, where any unavailable line fails
+// the whole order.
+func checkoutCart(ctx context.Context, products ProductStore, items []models.ProductUser) ([]models.ProductUser, error) {
+	cart, unavailable, err := priceCart(ctx, products, items)
 	if err != nil {
 		log.Println(err)
-		return ErrCantBuyCartItem
+		return nil, ErrCantGetItem
 	}
+	if len(unavailable) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrProductUnavailable, unavailable[0].ProductName)
+	}
+	return cart, nil
+}
 
-	return nil
+// CartTotal sums price × quantity over the given lines, failing when they
+// are priced in different currencies or the total is too large.
+func CartTotal(items []models.ProductUser) (models.Money, error) {
+	total := models.NewMoney(0, models.BaseCurrency)
+	for _, v := range items {
+		value, err := v.Price.Times(lineQuantity(v))
+		if err != nil {
+			return models.Money{}, err
+		}
+		if total, err = total.Add(value); err != nil {
+			return models.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// NormalizePrice checks that price is a positive amount in the catalog's
+// currency, which it defaults to.
+func NormalizePrice(price models.Money) (models.Money, error) {
+	if price.Currency == "" {
+		price.Currency = models.BaseCurrency
+	}
+	price = models.NewMoney(price.Amount, price.Currency)
+	if price.Currency != models.BaseCurrency {
+		return models.Money{}, fmt.Errorf("%w: prices must be in %s", models.ErrCurrencyMismatch, models.BaseCurrency)
+	}
+	if price.Amount <= 0 {
+		return models.Money{}, ErrInvalidPrice
+	}
+	return price, nil
 }
 
-func InstantBuyer(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, userID string) error {
+// BuyItemFromCart places an order for the whole cart, discounted and taxed
+// the same way GetCart prices it. A coupon applied to the cart must still be
+// valid, and is redeemed together with the order. rate is the currency the
+// customer was shown prices in. An order paid by card stays pending until
+// PayOrder takes the payment, and is cancelled by CancelUnpaidOrders if that
+// does not happen within PaymentDeadline.
+func BuyItemFromCart(ctx context.Context, users UserStore, products ProductStore, inventory InventoryStore, orders OrderStore, coupons CouponStore, categories CategoryStore, promotions PromotionStore, taxes TaxStore, rate models.ExchangeRate, paymentMethod string, userID string, addressID string) (models.Order, error) {
+	payment, err := paymentFor(paymentMethod)
+	if err != nil {
+		return models.Order{}, err
+	}
 	userObjectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
-		return ErrUserIdIsNotValid
+		return models.Order{}, ErrUserIdIsNotValid
+	}
+
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		log.Println(err)
+		return models.Order{}, ErrCantGetItem
+	}
+	if len(user.UserCart) == 0 {
+		return models.Order{}, errors.New("cart is empty")
+	}
+
+	address, err := shippingAddress(user, addressID)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	cart, err := checkoutCart(ctx, products, user.UserCart)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	pricing, err := discountCart(ctx, products, coupons, categories, promotions, taxes, userObjectID, user.CouponCode, address, cart)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if pricing.couponErr != nil {
+		return models.Order{}, pricing.couponErr
 	}
+	coupon := pricing.coupon
 
-	var productDetails models.ProductUser
-	err = prodCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&productDetails)
+	lines := reservationLines(cart)
+	if err := takeStockForOrder(ctx, products, inventory, userObjectID, lines); err != nil {
+		return models.Order{}, err
+	}
+
+	if coupon.Code != "" {
+		if err := coupons.RedeemCoupon(ctx, coupon, userObjectID); err != nil {
+			releaseLines(ctx, products, lines)
+			return models.Order{}, err
+		}
+	}
 
+	order := newOrder(userObjectID, cart, pricing.subtotal, address, payment)
+	order.Discount = pricing.discount
+	order.Promotions = pricing.promotions
+	order.CouponCode = coupon.Code
+	order.Tax = pricing.tax
+	order.TaxLines = pricing.taxLines
+	if err = showOrderIn(&order, rate); err == nil {
+		err = orders.InsertOrder(ctx, order)
+	}
 	if err != nil {
 		log.Println(err)
+		releaseLines(ctx, products, lines)
+		if coupon.Code != "" {
+			if err := coupons.ReleaseCoupon(ctx, coupon.Code, userObjectID); err != nil {
+				log.Println(err)
+			}
+		}
+		return models.Order{}, ErrCantBuyCartItem
 	}
 
-	orderDetails := models.Order{
-		OrderID:       primitive.NewObjectID(),
-		OrderCart:     []models.ProductUser{productDetails},
-		OrderedAt:     time.Now(),
-		Price:         productDetails.Price,
-		Discount:      0,
-		PaymentMethod: models.Payment{Digital: false, COD: true},
+	// The order is placed; a cart left behind is only an annoyance, while
+	// reporting a failure would invite a second order.
+	if err := users.ClearCart(ctx, userObjectID); err != nil {
+		log.Println(err)
 	}
 
-	filter := bson.M{"_id": userObjectID}
-	update := bson.M{"$push": bson.M{"orders": orderDetails}}
+	return order, nil
+}
 
-	_, err = userCollection.UpdateOne(ctx, filter, update)
+// InstantBuyer places an order for one unit of a product straight away,
+// with the running promotions applied as they would be in the cart.
+func InstantBuyer(ctx context.Context, users UserStore, products ProductStore, orders OrderStore, promotions PromotionStore, taxes TaxStore, rate models.ExchangeRate, paymentMethod string, productID primitive.ObjectID, sku string, userID string, addressID string) (models.Order, error) {
+	payment, err := paymentFor(paymentMethod)
+	if err != nil {
+		return models.Order{}, err
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
+		return models.Order{}, ErrUserIdIsNotValid
 	}
 
-	filter = bson.M{"_id": userObjectID}
-	update = bson.M{"$push": bson.M{}}
-	return nil
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		log.Println(err)
+		return models.Order{}, ErrUserIdIsNotValid
+	}
+
+	address, err := shippingAddress(user, addressID)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	product, err := products.FindProductByID(ctx, productID)
+	if err != nil {
+		log.Println(err)
+		return models.Order{}, ErrCantFindProduct
+	}
+	if product.Deleted {
+		return models.Order{}, ErrCantFindProduct
+	}
+	variant, err := resolveVariant(product, sku)
+	if err != nil {
+		return models.Order{}, err
+	}
+	cart := []models.ProductUser{cartItemFromProduct(product, variant, 1)}
+	// Without a coupon discountCart needs neither coupons nor categories.
+	pricing, err := discountCart(ctx, products, nil, nil, promotions, taxes, userObjectID, "", address, cart)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	lines := reservationLines(cart)
+	if err := reserveLines(ctx, products, lines); err != nil {
+		return models.Order{}, err
+	}
+
+	order := newOrder(userObjectID, cart, pricing.subtotal, address, payment)
+	order.Discount = pricing.discount
+	order.Promotions = pricing.promotions
+	order.Tax = pricing.tax
+	order.TaxLines = pricing.taxLines
+	if err = showOrderIn(&order, rate); err == nil {
+		err = orders.InsertOrder(ctx, order)
+	}
+	if err != nil {
+		log.Println(err)
+		releaseLines(ctx, products, lines)
+		return models.Order{}, ErrCantBuyCartItem
+	}
+
+	return order, nil
+}
+
+// shippingAddress picks the address an order ships to: the one named by
+// addressID, or the user's first address when none is named.
+func shippingAddress(user models.User, addressID string) (models.Address, error) {
+	if addressID == "" {
+		if len(user.AddressDetails) == 0 {
+			return models.Address{}, nil
+		}
+		return user.AddressDetails[0], nil
+	}
+
+	for _, address := range user.AddressDetails {
+		if address.AddressID.Hex() == addressID {
+			return address, nil
+		}
+	}
+	return models.Address{}, ErrAddressNotFound
+}
+
+// resolveVariant finds the variant a line for product must use: none for a
+// product without variants, and the one named by sku otherwise.
+func resolveVariant(product models.Product, sku string) (models.Variant, error) {
+	if len(product.Variants) == 0 {
+		if sku != "" {
+			return models.Variant{}, ErrVariantNotFound
+		}
+		return models.Variant{}, nil
+	}
+	if sku == "" {
+		return models.Variant{}, ErrVariantRequired
+	}
+	variant, ok := product.Variant(sku)
+	if !ok {
+		return models.Variant{}, ErrVariantNotFound
+	}
+	return variant, nil
+}
+
+// cartItemFromProduct builds a line for product, or for one of its variants
+// when variant is not the zero value.
+func cartItemFromProduct(product models.Product, variant models.Variant, quantity uint64) models.ProductUser {
+	item := models.ProductUser{
+		ProductID:   product.ProductID,
+		ProductName: product.ProductName,
+		Price:       product.Price,
+		Rating:      product.Rating,
+		Image:       product.Image,
+		Quantity:    quantity,
+		VariantSKU:  variant.SKU,
+		Options:     variant.Options,
+		TaxClass:    product.TaxClass,
+	}
+	if !variant.Price.IsZero() {
+		item.Price = variant.Price
+	}
+	if variant.Image != "" {
+		item.Image = variant.Image
+	}
+	return item
+}
+
+// lineQuantity treats lines written before quantities existed as a single unit.
+func lineQuantity(item models.ProductUser) uint64 {
+	if item.Quantity == 0 {
+		return 1
+	}
+	return item.Quantity
+}
+
+// lineKey identifies a cart or reservation line: a product, plus the variant
+// for products that have them.
+type lineKey struct {
+	productID primitive.ObjectID
+	sku       string
+}
+
+// normalizeCart folds duplicate lines for the same product variant, left over
+// from when every add pushed a fresh copy, into one line with a real quantity.
+func normalizeCart(items []models.ProductUser) []models.ProductUser {
+	cart := []models.ProductUser{}
+	index := map[lineKey]int{}
+	for _, item := range items {
+		key := lineKey{item.ProductID, item.VariantSKU}
+		if i, ok := index[key]; ok {
+			cart[i].Quantity += lineQuantity(item)
+			continue
+		}
+		item.Quantity = lineQuantity(item)
+		index[key] = len(cart)
+		cart = append(cart, item)
+	}
+	return cart
 }
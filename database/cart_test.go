@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCheckoutCart(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+
+	s.addToCart(kettle, 2)
+	summary, err := GetCart(s.ctx, s.store, s.store, s.store, s.store, s.store, s.store, s.userID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Subtotal.Amount != 50000 || len(summary.Items) != 1 {
+		t.Fatalf("cart = %d line(s) for %d, want 1 line for 50000", len(summary.Items), summary.Subtotal.Amount)
+	}
+
+	order, err := s.checkout(models.PaymentMethodCOD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.OrderPending || !order.PaymentMethod.COD {
+		t.Errorf("order is %s with payment %+v, want a pending cash on delivery order", order.Status, order.PaymentMethod)
+	}
+	if total, err := order.Total(); err != nil || total.Amount != summary.Total.Amount {
+		t.Errorf("order total = %d, %v; cart total was %d", total.Amount, err, summary.Total.Amount)
+	}
+
+	if stored := s.reload(order); len(stored.OrderCart) != 1 || stored.OrderCart[0].Quantity != 2 {
+		t.Errorf("stored order lines = %+v, want 2 kettles", stored.OrderCart)
+	}
+	if stock := s.stock(kettle); stock != 3 {
+		t.Errorf("stock = %d after selling 2 of 5, want 3", stock)
+	}
+	user, err := s.store.FindUserByID(s.ctx, s.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.UserCart) != 0 {
+		t.Errorf("cart still holds %d line(s) after checkout", len(user.UserCart))
+	}
+}
+
+func TestCheckoutCartWithoutStock(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 1)
+
+	s.addToCart(kettle, 2)
+	_, err := s.checkout(models.PaymentMethodCOD)
+	var stockErr *StockError
+	if !errors.As(err, &stockErr) || len(stockErr.Shortages) != 1 {
+		t.Fatalf("checkout of 2 with 1 in stock: err = %v, want a *StockError with one shortage", err)
+	}
+
+	if stock := s.stock(kettle); stock != 1 {
+		t.Errorf("stock = %d after a failed checkout, want 1", stock)
+	}
+	if orders, _, err := s.store.ListOrdersByUser(s.ctx, s.userID, OrderFilter{Limit: 10}); err != nil || len(orders) != 0 {
+		t.Errorf("orders = %d, %v after a failed checkout, want none", len(orders), err)
+	}
+}
+
+func TestUpdateCartQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		quantity uint64
+		want     uint64
+		err      error
+	}{
+		{"set", CartActionSet, 7, 7, nil},
+		{"set to zero removes the line", CartActionSet, 0, 0, nil},
+		{"set above the cap", CartActionSet, MaxLineQuantity + 1, 3, ErrQuantityTooLarge},
+		{"increment", CartActionIncrement, 2, 5, nil},
+		{"increment up to the cap", CartActionIncrement, MaxLineQuantity - 3, MaxLineQuantity, nil},
+		{"increment past the cap", CartActionIncrement, MaxLineQuantity - 2, 3, ErrQuantityTooLarge},
+		{"increment that would wrap", CartActionIncrement, math.MaxUint64, 3, ErrQuantityTooLarge},
+		{"increment by zero", CartActionIncrement, 0, 3, ErrInvalidQuantity},
+		{"decrement", CartActionDecrement, 2, 1, nil},
+		{"decrement to zero removes the line", CartActionDecrement, 3, 0, nil},
+		{"decrement below zero removes the line", CartActionDecrement, 5, 0, nil},
+		{"unknown action", "double", 1, 3, ErrInvalidCartAction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			kettle := s.product("Kettle", 25000, 500)
+			s.addToCart(kettle, 3)
+
+			err := UpdateCartQuantity(s.ctx, s.store, kettle, "", s.userID.Hex(), tt.action, tt.quantity)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("UpdateCartQuantity() error = %v, want %v", err, tt.err)
+			}
+			if got := s.cartQuantity(kettle); got != tt.want {
+				t.Errorf("quantity = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateCartQuantityOfMissingLine(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+
+	err := UpdateCartQuantity(s.ctx, s.store, kettle, "", s.userID.Hex(), CartActionSet, 2)
+	if !errors.Is(err, ErrCartItemNotFound) {
+		t.Errorf("UpdateCartQuantity() error = %v, want %v", err, ErrCartItemNotFound)
+	}
+}
+
+func TestAddProductToCartMergesLines(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 500)
+
+	s.addToCart(kettle, 40)
+	s.addToCart(kettle, 60)
+	if got := s.cartQuantity(kettle); got != MaxLineQuantity {
+		t.Fatalf("quantity = %d after adding 40 and 60, want %d", got, MaxLineQuantity)
+	}
+
+	for _, quantity := range []uint64{1, MaxLineQuantity + 1, math.MaxUint64} {
+		if err := AddProductToCart(s.ctx, s.store, s.store, kettle, "", s.userID.Hex(), quantity); !errors.Is(err, ErrQuantityTooLarge) {
+			t.Errorf("adding %d to a full line: err = %v, want %v", quantity, err, ErrQuantityTooLarge)
+		}
+	}
+	if err := AddProductToCart(s.ctx, s.store, s.store, kettle, "", s.userID.Hex(), 0); !errors.Is(err, ErrInvalidQuantity) {
+		t.Errorf("adding 0: err = %v, want %v", err, ErrInvalidQuantity)
+	}
+	if got := s.cartQuantity(kettle); got != MaxLineQuantity {
+		t.Errorf("quantity = %d after rejected adds, want %d", got, MaxLineQuantity)
+	}
+}
+
+func TestCartTotal(t *testing.T) {
+	price := models.NewMoney(12345, models.BaseCurrency)
+	lines := []models.ProductUser{
+		{ProductID: primitive.NewObjectID(), Price: price, Quantity: 3},
+		// Lines saved before quantities existed count as one unit.
+		{ProductID: primitive.NewObjectID(), Price: price},
+	}
+	total, err := CartTotal(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total.Amount != 4*12345 {
+		t.Errorf("total = %d, want %d", total.Amount, 4*12345)
+	}
+
+	huge := []models.ProductUser{{ProductID: primitive.NewObjectID(), Price: models.NewMoney(math.MaxInt64/2, models.BaseCurrency), Quantity: 3}}
+	if _, err := CartTotal(huge); !errors.Is(err, models.ErrAmountOverflow) {
+		t.Errorf("CartTotal() of an overflowing line: err = %v, want %v", err, models.ErrAmountOverflow)
+	}
+}
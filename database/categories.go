@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidSlug    = errors.New("slug must be lowercase letters and digits separated by single hyphens")
+	ErrCategoryCycle  = errors.New("a category cannot be moved under itself or one of its subcategories")
+	ErrCategoryInUse  = errors.New("category still has subcategories or products")
+	ErrParentNotFound = errors.New("parent category not found")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CategoryNode is a category together with its subcategories, as returned by
+// CategoryTree.
+type CategoryNode struct {
+	models.Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// CategoryDetails describes one category for browsing: the way down to it
+// from the root, its direct subcategories, and the slugs of the category and
+// all of its descendants.
+type CategoryDetails struct {
+	Category    models.Category   `json:"category"`
+	Path        []models.Category `json:"path"`
+	Children    []models.Category `json:"children"`
+	Descendants []string          `json:"-"`
+}
+
+// categoryTree indexes the flat category list by slug and by parent.
+type categoryTree struct {
+	bySlug   map[string]models.Category
+	children map[string][]models.Category
+}
+
+func loadCategoryTree(ctx context.Context, categories CategoryStore) (categoryTree, error) {
+	list, err := categories.ListCategories(ctx)
+	if err != nil {
+		log.Println(err)
+		return categoryTree{}, err
+	}
+
+	tree := categoryTree{bySlug: map[string]models.Category{}, children: map[string][]models.Category{}}
+	for _, category := range list {
+		tree.bySlug[category.Slug] = category
+		tree.children[category.Parent] = append(tree.children[category.Parent], category)
+	}
+	for _, children := range tree.children {
+		sort.Slice(children, func(i, j int) bool {
+			if children[i].DisplayOrder != children[j].DisplayOrder {
+				return children[i].DisplayOrder < children[j].DisplayOrder
+			}
+			return children[i].Name < children[j].Name
+		})
+	}
+	return tree, nil
+}
+
+// descendants returns slug followed by the slugs of everything below it.
+// Each category is visited once, so a cycle cannot keep the walk going.
+func (t categoryTree) descendants(slug string) []string {
+	slugs := []string{slug}
+	seen := map[string]bool{slug: true}
+	for i := 0; i < len(slugs); i++ {
+		for _, child := range t.children[slugs[i]] {
+			if !seen[child.Slug] {
+				seen[child.Slug] = true
+				slugs = append(slugs, child.Slug)
+			}
+		}
+	}
+	return slugs
+}
+
+// path returns the way down from the root to slug. It stops at the first
+// category seen twice, should the tree contain a cycle.
+func (t categoryTree) path(slug string) []models.Category {
+	path := []models.Category{}
+	seen := map[string]bool{}
+	for category, ok := t.bySlug[slug]; ok && !seen[category.Slug]; category, ok = t.bySlug[category.Parent] {
+		seen[category.Slug] = true
+		path = append([]models.Category{category}, path...)
+	}
+	return path
+}
+
+// inCycle reports whether following parents up from slug comes back to it.
+func (t categoryTree) inCycle(slug string) bool {
+	seen := map[string]bool{}
+	for category, ok := t.bySlug[slug]; ok; category, ok = t.bySlug[category.Parent] {
+		if seen[category.Slug] {
+			return true
+		}
+		seen[category.Slug] = true
+	}
+	return false
+}
+
+func (t categoryTree) nodes(parent string) []*CategoryNode {
+	nodes := []*CategoryNode{}
+	for _, category := range t.children[parent] {
+		nodes = append(nodes, &CategoryNode{Category: category, Children: t.nodes(category.Slug)})
+	}
+	return nodes
+}
+
+// CategoryTree returns the root categories with their subcategories nested
+// below them, each level in display order.
+func CategoryTree(ctx context.Context, categories CategoryStore) ([]*CategoryNode, error) {
+	tree, err := loadCategoryTree(ctx, categories)
+	if err != nil {
+		return nil, err
+	}
+	return tree.nodes(""), nil
+}
+
+// GetCategory returns the category slug with its path, subcategories and
+// descendants.
+func GetCategory(ctx context.Context, categories CategoryStore, slug string) (CategoryDetails, error) {
+	tree, err := loadCategoryTree(ctx, categories)
+	if err != nil {
+		return CategoryDetails{}, err
+	}
+	category, ok := tree.bySlug[slug]
+	if !ok {
+		return CategoryDetails{}, ErrCategoryNotFound
+	}
+	return CategoryDetails{
+		Category:    category,
+		Path:        tree.path(slug),
+		Children:    append([]models.Category{}, tree.children[slug]...),
+		Descendants: tree.descendants(slug),
+	}, nil
+}
+
+// WithSubcategories widens a list of category slugs to include every
+// subcategory of each. Unknown slugs are kept as they are.
+func WithSubcategories(ctx context.Context, categories CategoryStore, slugs []string) ([]string, error) {
+	if len(slugs) == 0 {
+		return slugs, nil
+	}
+	tree, err := loadCategoryTree(ctx, categories)
+	if err != nil {
+		return nil, err
+	}
+	widened := []string{}
+	for _, slug := range slugs {
+		widened = append(widened, tree.descendants(slug)...)
+	}
+	return widened, nil
+}
+
+// CheckCategories normalizes the categories assigned to a product and makes
+// sure each of them exists.
+func CheckCategories(ctx context.Context, categories CategoryStore, slugs []string) ([]string, error) {
+	slugs = NormalizeTags(slugs)
+	if len(slugs) == 0 {
+		return slugs, nil
+	}
+	tree, err := loadCategoryTree(ctx, categories)
+	if err != nil {
+		return nil, err
+	}
+	for _, slug := range slugs {
+		if _, ok := tree.bySlug[slug]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrCategoryNotFound, slug)
+		}
+	}
+	return slugs, nil
+}
+
+// CreateCategory adds a category below an existing parent, or at the root.
+func CreateCategory(ctx context.Context, categories CategoryStore, category models.Category) (models.Category, error) {
+	category.Slug = strings.ToLower(strings.TrimSpace(category.Slug))
+	category.Parent = strings.ToLower(strings.TrimSpace(category.Parent))
+	if !slugPattern.MatchString(category.Slug) {
+		return models.Category{}, ErrInvalidSlug
+	}
+
+	if category.Parent != "" {
+		tree, err := loadCategoryTree(ctx, categories)
+		if err != nil {
+			return models.Category{}, err
+		}
+		if _, ok := tree.bySlug[category.Parent]; !ok {
+			return models.Category{}, ErrParentNotFound
+		}
+	}
+
+	category.ID = primitive.NewObjectID()
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = category.CreatedAt
+	if err := categories.InsertCategory(ctx, category); err != nil {
+		return models.Category{}, err
+	}
+	return category, nil
+}
+
+// UpdateCategory renames, reorders or moves a category. A category can be
+// moved anywhere except below itself. Two moves at once can each pass that
+// check and still close a loop together, so after a move the tree is read
+// again and a move that made a cycle is undone.
+func UpdateCategory(ctx context.Context, categories CategoryStore, slug string, update models.CategoryUpdate) (models.Category, error) {
+	var previousParent string
+	if update.Parent != nil {
+		parent := strings.ToLower(strings.TrimSpace(*update.Parent))
+		update.Parent = &parent
+
+		tree, err := loadCategoryTree(ctx, categories)
+		if err != nil {
+			return models.Category{}, err
+		}
+		current, ok := tree.bySlug[slug]
+		if !ok {
+			return models.Category{}, ErrCategoryNotFound
+		}
+		previousParent = current.Parent
+		if parent != "" {
+			if _, ok := tree.bySlug[parent]; !ok {
+				return models.Category{}, ErrParentNotFound
+			}
+			for _, below := range tree.descendants(slug) {
+				if below == parent {
+					return models.Category{}, ErrCategoryCycle
+				}
+			}
+		}
+	}
+
+	category, err := categories.UpdateCategory(ctx, slug, update)
+	if err != nil || update.Parent == nil || *update.Parent == "" {
+		return category, err
+	}
+
+	tree, err := loadCategoryTree(ctx, categories)
+	if err != nil {
+		return category, err
+	}
+	if tree.inCycle(slug) {
+		if _, err := categories.UpdateCategory(ctx, slug, models.CategoryUpdate{Parent: &previousParent}); err != nil {
+			log.Println(err)
+		}
+		return models.Category{}, ErrCategoryCycle
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category that no subcategory and no product,
+// deleted ones included, refers to any more.
+func DeleteCategory(ctx context.Context, categories CategoryStore, products ProductStore, slug string) error {
+	tree, err := loadCategoryTree(ctx, categories)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.bySlug[slug]; !ok {
+		return ErrCategoryNotFound
+	}
+	if len(tree.children[slug]) > 0 {
+		return ErrCategoryInUse
+	}
+
+	_, total, err := products.FindProducts(ctx, ProductQuery{Categories: []string{slug}, IncludeDeleted: true, Limit: 1})
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if total > 0 {
+		return ErrCategoryInUse
+	}
+
+	return categories.DeleteCategory(ctx, slug)
+}
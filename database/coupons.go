@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidCoupon       = errors.New("a coupon needs a percent between 1 and 100 or a positive amount, and must end after it starts")
+	ErrCouponInactive      = errors.New("coupon is not valid at this time")
+	ErrCouponMinimum       = errors.New("cart total is below the coupon's minimum")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to anything in the cart")
+)
+
+// NormalizeCouponCode makes coupon codes case-insensitive.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func CreateCoupon(ctx context.Context, coupons CouponStore, coupon models.Coupon) (models.Coupon, error) {
+	coupon.Code = NormalizeCouponCode(coupon.Code)
+	switch coupon.Kind {
+	case models.CouponPercent:
+		if coupon.Percent == 0 || coupon.Percent > 100 {
+			return models.Coupon{}, ErrInvalidCoupon
+		}
+		coupon.Amount = models.Money{}
+	case models.CouponFixed:
+		amount, err := NormalizePrice(coupon.Amount)
+		if errors.Is(err, ErrInvalidPrice) {
+			return models.Coupon{}, ErrInvalidCoupon
+		}
+		if err != nil {
+			return models.Coupon{}, err
+		}
+		coupon.Amount = amount
+		coupon.Percent = 0
+	}
+	if !coupon.MinCartValue.IsZero() {
+		minimum, err := NormalizePrice(coupon.MinCartValue)
+		if err != nil {
+			return models.Coupon{}, err
+		}
+		coupon.MinCartValue = minimum
+	}
+	if !coupon.StartsAt.IsZero() && !coupon.EndsAt.IsZero() && !coupon.EndsAt.After(coupon.StartsAt) {
+		return models.Coupon{}, ErrInvalidCoupon
+	}
+
+	coupon.ID = primitive.NewObjectID()
+	coupon.Used = 0
+	coupon.Categories = NormalizeTags(coupon.Categories)
+	coupon.CreatedAt = time.Now()
+	if err := coupons.InsertCoupon(ctx, coupon); err != nil {
+		return models.Coupon{}, err
+	}
+	return coupon, nil
+}
+
+// findCartCoupon looks up code and works out what it takes off cart for
+// userID. It checks every rule, including the usage limits as they stand
+// now; RedeemCoupon enforces the limits again atomically at checkout.
+func findCartCoupon(ctx context.Context, coupons CouponStore, products ProductStore, categories CategoryStore, code string, userID primitive.ObjectID, cart []models.ProductUser) (models.Coupon, models.Money, error) {
+	var none models.Money
+	coupon, err := coupons.FindCouponByCode(ctx, NormalizeCouponCode(code))
+	if err != nil {
+		return models.Coupon{}, none, err
+	}
+
+	now := time.Now()
+	if (!coupon.StartsAt.IsZero() && now.Before(coupon.StartsAt)) || (!coupon.EndsAt.IsZero() && !now.Before(coupon.EndsAt)) {
+		return coupon, none, ErrCouponInactive
+	}
+	if coupon.UsageLimit > 0 && coupon.Used >= coupon.UsageLimit {
+		return coupon, none, ErrCouponExhausted
+	}
+	if coupon.PerUserLimit > 0 {
+		uses, err := coupons.CountCouponUses(ctx, coupon.Code, userID)
+		if err != nil {
+			log.Println(err)
+			return coupon, none, err
+		}
+		if uses >= coupon.PerUserLimit {
+			return coupon, none, ErrCouponUsedUp
+		}
+	}
+
+	subtotal, err := CartTotal(cart)
+	if err != nil {
+		return coupon, none, err
+	}
+	if below, err := subtotal.Cmp(coupon.MinCartValue); err != nil {
+		return coupon, none, err
+	} else if below < 0 {
+		return coupon, none, fmt.Errorf("%w of %s", ErrCouponMinimum, coupon.MinCartValue)
+	}
+
+	eligible, err := couponEligibleTotal(ctx, products, categories, coupon, cart)
+	if err != nil {
+		return coupon, none, err
+	}
+	if eligible.IsZero() {
+		return coupon, none, ErrCouponNotApplicable
+	}
+
+	discount, err := couponDiscount(coupon, eligible)
+	if err != nil {
+		return coupon, none, err
+	}
+	return coupon, discount, nil
+}
+
+// couponEligibleTotal sums the cart lines the coupon is scoped to: all of
+// them for an unscoped coupon, otherwise the listed products and products in
+// the listed categories or their subcategories.
+func couponEligibleTotal(ctx context.Context, products ProductStore, categories CategoryStore, coupon models.Coupon, cart []models.ProductUser) (models.Money, error) {
+	if len(coupon.ProductIDs) == 0 && len(coupon.Categories) == 0 {
+		return CartTotal(cart)
+	}
+
+	scope, err := WithSubcategories(ctx, categories, coupon.Categories)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	var eligible []models.ProductUser
+	for _, item := range cart {
+		if containsID(coupon.ProductIDs, item.ProductID) {
+			eligible = append(eligible, item)
+			continue
+		}
+		if len(scope) == 0 {
+			continue
+		}
+		product, err := products.FindProductByID(ctx, item.ProductID)
+		if err != nil {
+			log.Println(err)
+			return models.Money{}, err
+		}
+		if inAny(product.Categories, scope) {
+			eligible = append(eligible, item)
+		}
+	}
+	return CartTotal(eligible)
+}
+
+// couponDiscount is what the coupon takes off eligible, never more than
+// eligible itself.
+func couponDiscount(coupon models.Coupon, eligible models.Money) (models.Money, error) {
+	if coupon.Kind == models.CouponPercent {
+		return eligible.Percent(coupon.Percent), nil
+	}
+	return coupon.Amount.Min(eligible)
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyCartCoupon checks code against the user's current cart and, when it
+// is valid, keeps it on the cart until checkout or removal.
+func ApplyCartCoupon(ctx context.Context, users UserStore, products ProductStore, coupons CouponStore, categories CategoryStore, promotions PromotionStore, taxes TaxStore, userID string, code string) (CartSummary, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return CartSummary{}, ErrUserIdIsNotValid
+	}
+
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		log.Println(err)
+		return CartSummary{}, ErrCantGetItem
+	}
+	cart, _, err := priceCart(ctx, products, user.UserCart)
+	if err != nil {
+		log.Println(err)
+		return CartSummary{}, ErrCantGetItem
+	}
+
+	coupon, _, err := findCartCoupon(ctx, coupons, products, categories, code, userObjectID, cart)
+	if err != nil {
+		return CartSummary{}, err
+	}
+	if err := users.SetCartCoupon(ctx, userObjectID, coupon.Code); err != nil {
+		log.Println(err)
+		return CartSummary{}, ErrCantUpdateUser
+	}
+
+	return GetCart(ctx, users, products, coupons, categories, promotions, taxes, userID)
+}
+
+func RemoveCartCoupon(ctx context.Context, users UserStore, products ProductStore, coupons CouponStore, categories CategoryStore, promotions PromotionStore, taxes TaxStore, userID string) (CartSummary, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return CartSummary{}, ErrUserIdIsNotValid
+	}
+
+	if err := users.SetCartCoupon(ctx, userObjectID, ""); err != nil {
+		log.Println(err)
+		return CartSummary{}, ErrCantUpdateUser
+	}
+
+	return GetCart(ctx, users, products, coupons, categories, promotions, taxes, userID)
+}
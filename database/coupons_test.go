@@ -0,0 +1,173 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// coupon creates a coupon from c, failing the test if it is refused.
+func (s *shop) coupon(c models.Coupon) models.Coupon {
+	s.t.Helper()
+	coupon, err := CreateCoupon(s.ctx, s.store, c)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return coupon
+}
+
+// applyCoupon puts code on the customer's cart.
+func (s *shop) applyCoupon(code string) (CartSummary, error) {
+	return ApplyCartCoupon(s.ctx, s.store, s.store, s.store, s.store, s.store, s.store, s.userID.Hex(), code)
+}
+
+func TestCreateCouponValidates(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		coupon models.Coupon
+	}{
+		{"zero percent", models.Coupon{Code: "NONE", Kind: models.CouponPercent}},
+		{"over a hundred percent", models.Coupon{Code: "MORE", Kind: models.CouponPercent, Percent: 101}},
+		{"no amount", models.Coupon{Code: "FREE", Kind: models.CouponFixed}},
+		{"ends before it starts", models.Coupon{Code: "BACK", Kind: models.CouponPercent, Percent: 10, StartsAt: now, EndsAt: now.Add(-time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CreateCoupon(t.Context(), NewMemoryStore(), tt.coupon); !errors.Is(err, ErrInvalidCoupon) {
+				t.Errorf("CreateCoupon() error = %v, want %v", err, ErrInvalidCoupon)
+			}
+		})
+	}
+}
+
+func TestApplyCartCoupon(t *testing.T) {
+	tests := []struct {
+		name     string
+		coupon   models.Coupon
+		code     string
+		discount int64
+		err      error
+	}{
+		{"percent", models.Coupon{Code: "TEN", Kind: models.CouponPercent, Percent: 10}, "ten", 5000, nil},
+		{"fixed", models.Coupon{Code: "FLAT", Kind: models.CouponFixed, Amount: models.NewMoney(7500, models.BaseCurrency)}, "FLAT", 7500, nil},
+		{"fixed above the cart total", models.Coupon{Code: "HUGE", Kind: models.CouponFixed, Amount: models.NewMoney(90000, models.BaseCurrency)}, "HUGE", 50000, nil},
+		{"minimum met", models.Coupon{Code: "MIN", Kind: models.CouponPercent, Percent: 10, MinCartValue: models.NewMoney(50000, models.BaseCurrency)}, "MIN", 5000, nil},
+		{"minimum not met", models.Coupon{Code: "MIN", Kind: models.CouponPercent, Percent: 10, MinCartValue: models.NewMoney(50001, models.BaseCurrency)}, "MIN", 0, ErrCouponMinimum},
+		{"not started", models.Coupon{Code: "SOON", Kind: models.CouponPercent, Percent: 10, StartsAt: time.Now().Add(time.Hour)}, "SOON", 0, ErrCouponInactive},
+		{"ended", models.Coupon{Code: "OVER", Kind: models.CouponPercent, Percent: 10, EndsAt: time.Now().Add(-time.Hour)}, "OVER", 0, ErrCouponInactive},
+		{"for another product", models.Coupon{Code: "OTHER", Kind: models.CouponPercent, Percent: 10, ProductIDs: []primitive.ObjectID{primitive.NewObjectID()}}, "OTHER", 0, ErrCouponNotApplicable},
+		{"unknown", models.Coupon{Code: "TEN", Kind: models.CouponPercent, Percent: 10}, "ELEVEN", 0, ErrCouponNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			s.addToCart(s.product("Kettle", 25000, 5), 2)
+			s.coupon(tt.coupon)
+
+			summary, err := s.applyCoupon(tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ApplyCartCoupon() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && summary.CouponDiscount.Amount != tt.discount {
+				t.Errorf("coupon discount = %d, want %d", summary.CouponDiscount.Amount, tt.discount)
+			}
+		})
+	}
+}
+
+func TestCouponScopedToCategory(t *testing.T) {
+	s := newShop(t)
+	for _, category := range []models.Category{{Slug: "kitchen", Name: "Kitchen"}, {Slug: "kettles", Name: "Kettles", Parent: "kitchen"}} {
+		if _, err := CreateCategory(s.ctx, s.store, category); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inCategory := func(slug string) func(*models.Product) {
+		return func(product *models.Product) { product.Categories = []string{slug} }
+	}
+	s.addToCart(s.product("Kettle", 25000, 5, inCategory("kettles")), 1)
+	s.addToCart(s.product("Lamp", 40000, 5, inCategory("lighting")), 1)
+	s.coupon(models.Coupon{Code: "KITCHEN", Kind: models.CouponPercent, Percent: 20, Categories: []string{"kitchen"}})
+
+	summary, err := s.applyCoupon("KITCHEN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.CouponDiscount.Amount != 5000 {
+		t.Errorf("coupon discount = %d, want 20%% of the kettle only, 5000", summary.CouponDiscount.Amount)
+	}
+}
+
+func TestCouponRedeemedAtCheckout(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 10)
+	s.coupon(models.Coupon{Code: "ONCE", Kind: models.CouponPercent, Percent: 10, PerUserLimit: 1})
+
+	s.addToCart(kettle, 2)
+	if _, err := s.applyCoupon("ONCE"); err != nil {
+		t.Fatal(err)
+	}
+	order, err := s.checkout(models.PaymentMethodCOD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.CouponCode != "ONCE" || order.Discount.Amount != 5000 {
+		t.Errorf("order has coupon %q for %d off, want ONCE for 5000", order.CouponCode, order.Discount.Amount)
+	}
+	if coupon, err := s.store.FindCouponByCode(s.ctx, "ONCE"); err != nil || coupon.Used != 1 {
+		t.Errorf("coupon used %d time(s), %v after checkout, want once", coupon.Used, err)
+	}
+
+	s.addToCart(kettle, 1)
+	if _, err := s.applyCoupon("ONCE"); !errors.Is(err, ErrCouponUsedUp) {
+		t.Errorf("applying a coupon used up by this customer: err = %v, want %v", err, ErrCouponUsedUp)
+	}
+
+	if _, _, err := CancelOrder(s.ctx, s.store, s.store, s.store, s.store, nil, s.userID, order.OrderID, "changed my mind", s.userID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.applyCoupon("ONCE"); err != nil {
+		t.Errorf("applying the coupon after cancelling the order that used it: %v", err)
+	}
+}
+
+func TestRedeemCouponUsageLimit(t *testing.T) {
+	s := newShop(t)
+	coupon := s.coupon(models.Coupon{Code: "FIRST", Kind: models.CouponPercent, Percent: 10, UsageLimit: 1})
+
+	if err := s.store.RedeemCoupon(s.ctx, coupon, primitive.NewObjectID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.RedeemCoupon(s.ctx, coupon, s.userID); !errors.Is(err, ErrCouponExhausted) {
+		t.Errorf("redeeming past the usage limit: err = %v, want %v", err, ErrCouponExhausted)
+	}
+
+	s.addToCart(s.product("Kettle", 25000, 5), 1)
+	if _, err := s.applyCoupon("FIRST"); !errors.Is(err, ErrCouponExhausted) {
+		t.Errorf("applying an exhausted coupon: err = %v, want %v", err, ErrCouponExhausted)
+	}
+}
+
+func TestDeleteCoupon(t *testing.T) {
+	s := newShop(t)
+	s.coupon(models.Coupon{Code: "GONE", Kind: models.CouponPercent, Percent: 10})
+	if err := s.store.DeleteCoupon(s.ctx, "GONE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.DeleteCoupon(s.ctx, "GONE"); !errors.Is(err, ErrCouponNotFound) {
+		t.Errorf("deleting twice: err = %v, want %v", err, ErrCouponNotFound)
+	}
+
+	s.coupon(models.Coupon{Code: "GONE", Kind: models.CouponPercent, Percent: 15})
+	coupons, err := s.store.ListCoupons(s.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coupons) != 1 || coupons[0].Percent != 15 {
+		t.Errorf("coupons after deleting and recreating GONE = %+v, want the new one listed once", coupons)
+	}
+}
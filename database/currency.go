@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+var (
+	ErrInvalidRate         = errors.New("rate must be a positive decimal number such as 0.012")
+	ErrBaseCurrencyRate    = errors.New("the base currency cannot have an exchange rate")
+	ErrUnsupportedCurrency = errors.New("prices are not available in this currency")
+)
+
+// SaveExchangeRate checks rate and stores it, replacing any earlier rate for
+// the same currency.
+func SaveExchangeRate(ctx context.Context, rates ExchangeRateStore, rate models.ExchangeRate) (models.ExchangeRate, error) {
+	rate.Currency = strings.ToUpper(strings.TrimSpace(rate.Currency))
+	if rate.Currency == models.BaseCurrency {
+		return models.ExchangeRate{}, ErrBaseCurrencyRate
+	}
+	if _, err := parseRate(rate.Rate); err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if rate.Rounding == "" {
+		rate.Rounding = models.RoundNearest
+	}
+	if rate.Increment == 0 {
+		rate.Increment = 1
+	}
+	rate.UpdatedAt = time.Now()
+
+	if err := rates.SaveExchangeRate(ctx, rate); err != nil {
+		log.Println(err)
+		return models.ExchangeRate{}, err
+	}
+	return rate, nil
+}
+
+func parseRate(rate string) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(rate))
+	if !ok || value.Sign() <= 0 {
+		return nil, ErrInvalidRate
+	}
+	return value, nil
+}
+
+// baseRate shows prices in the catalog currency as they are.
+func baseRate() models.ExchangeRate {
+	return models.ExchangeRate{Currency: models.BaseCurrency, Rate: "1", Rounding: models.RoundNearest, Increment: 1}
+}
+
+// RateFor returns the rate to show prices in currency at. An empty currency
+// means the catalog currency.
+func RateFor(ctx context.Context, rates ExchangeRateStore, currency string) (models.ExchangeRate, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == models.BaseCurrency {
+		return baseRate(), nil
+	}
+
+	rate, err := rates.FindExchangeRate(ctx, currency)
+	if errors.Is(err, ErrRateNotFound) {
+		return models.ExchangeRate{}, ErrUnsupportedCurrency
+	}
+	if err != nil {
+		log.Println(err)
+		return models.ExchangeRate{}, err
+	}
+	return rate, nil
+}
+
+// ConvertMoney converts m from the catalog currency into rate's currency,
+// rounding the result the way rate says.
+func ConvertMoney(m models.Money, rate models.ExchangeRate) (models.Money, error) {
+	if m.Currency == rate.Currency {
+		return m, nil
+	}
+	if m.Currency != "" && m.Currency != models.BaseCurrency {
+		return models.Money{}, models.ErrCurrencyMismatch
+	}
+	factor, err := parseRate(rate.Rate)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	// Scale from the minor units of one currency to those of the other.
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), factor)
+	value.Mul(value, new(big.Rat).SetFrac(pow10(models.MinorUnits(rate.Currency)), pow10(models.MinorUnits(models.BaseCurrency))))
+
+	increment := max(rate.Increment, 1)
+	value.Quo(value, new(big.Rat).SetInt64(increment))
+	return models.NewMoney(roundRat(value, rate.Rounding)*increment, rate.Currency), nil
+}
+
+// majorUnit is how many minor units of models.BaseCurrency make up one of its
+// major units, such as 100 paise to the rupee.
+func majorUnit() int64 {
+	return pow10(models.MinorUnits(models.BaseCurrency)).Int64()
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundRat rounds value to a whole number: up or down towards positive or
+// negative infinity, or to the nearest one with halves away from zero.
+func roundRat(value *big.Rat, rounding string) int64 {
+	// big.Int.Div rounds towards negative infinity for a positive divisor,
+	// and the denominator of a big.Rat is always positive.
+	floor := func(v *big.Rat) *big.Int {
+		return new(big.Int).Div(v.Num(), v.Denom())
+	}
+
+	switch rounding {
+	case models.RoundDown:
+		return floor(value).Int64()
+	case models.RoundUp:
+		return -floor(new(big.Rat).Neg(value)).Int64()
+	}
+	half := big.NewRat(1, 2)
+	if value.Sign() < 0 {
+		return -floor(new(big.Rat).Add(new(big.Rat).Neg(value), half)).Int64()
+	}
+	return floor(new(big.Rat).Add(value, half)).Int64()
+}
+
+// ConvertProducts returns copies of products priced in rate's currency,
+// variants included.
+func ConvertProducts(products []models.Product, rate models.ExchangeRate) ([]models.Product, error) {
+	converted := make([]models.Product, 0, len(products))
+	for _, product := range products {
+		var err error
+		if product.Price, err = ConvertMoney(product.Price, rate); err != nil {
+			return nil, err
+		}
+		product.Variants = append([]models.Variant(nil), product.Variants...)
+		for i := range product.Variants {
+			if product.Variants[i].Price.IsZero() {
+				continue
+			}
+			if product.Variants[i].Price, err = ConvertMoney(product.Variants[i].Price, rate); err != nil {
+				return nil, err
+			}
+		}
+		converted = append(converted, product)
+	}
+	return converted, nil
+}
+
+// ConvertCart returns summary with every amount in rate's currency. The
+// subtotal, tax and total are converted as a whole, and the discount is what
+// lies between them, so the displayed figures always add up.
+func ConvertCart(summary CartSummary, rate models.ExchangeRate) (CartSummary, error) {
+	convertLines := func(lines []models.ProductUser) ([]models.ProductUser, error) {
+		if lines == nil {
+			return nil, nil
+		}
+		converted := make([]models.ProductUser, 0, len(lines))
+		for _, line := range lines {
+			var err error
+			if line.Price, err = ConvertMoney(line.Price, rate); err != nil {
+				return nil, err
+			}
+			converted = append(converted, line)
+		}
+		return converted, nil
+	}
+
+	var err error
+	if summary.Items, err = convertLines(summary.Items); err != nil {
+		return CartSummary{}, err
+	}
+	if summary.Unavailable, err = convertLines(summary.Unavailable); err != nil {
+		return CartSummary{}, err
+	}
+
+	promotions := make([]models.PromotionSaving, 0, len(summary.Promotions))
+	for _, saving := range summary.Promotions {
+		if saving.Amount, err = ConvertMoney(saving.Amount, rate); err != nil {
+			return CartSummary{}, err
+		}
+		promotions = append(promotions, saving)
+	}
+	if len(promotions) > 0 {
+		summary.Promotions = promotions
+	}
+
+	if summary.Subtotal, err = ConvertMoney(summary.Subtotal, rate); err != nil {
+		return CartSummary{}, err
+	}
+	if summary.CouponDiscount, err = ConvertMoney(summary.CouponDiscount, rate); err != nil {
+		return CartSummary{}, err
+	}
+	if summary.Tax, err = ConvertMoney(summary.Tax, rate); err != nil {
+		return CartSummary{}, err
+	}
+	if summary.Total, err = ConvertMoney(summary.Total, rate); err != nil {
+		return CartSummary{}, err
+	}
+	beforeDiscount := summary.Subtotal
+	if !summary.TaxInclusive {
+		if beforeDiscount, err = beforeDiscount.Add(summary.Tax); err != nil {
+			return CartSummary{}, err
+		}
+	}
+	if summary.Discount, err = beforeDiscount.Sub(summary.Total); err != nil {
+		return CartSummary{}, err
+	}
+	return summary, nil
+}
+
+// showOrderIn records on order which currency the customer saw it in and
+// what its total was there. The order itself stays in the catalog currency,
+// which is what it is settled in.
+func showOrderIn(order *models.Order, rate models.ExchangeRate) error {
+	total, err := order.Total()
+	if err != nil {
+		return err
+	}
+	display, err := ConvertMoney(total, rate)
+	if err != nil {
+		return err
+	}
+
+	order.SettlementCurrency = order.Price.Currency
+	order.DisplayCurrency = rate.Currency
+	order.ExchangeRate = rate.Rate
+	order.DisplayTotal = display
+	return nil
+}
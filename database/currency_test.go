@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+func TestConvertMoney(t *testing.T) {
+	usd := func(rounding string, increment int64) models.ExchangeRate {
+		return models.ExchangeRate{Currency: "USD", Rate: "0.012", Rounding: rounding, Increment: increment}
+	}
+	tests := []struct {
+		name   string
+		amount int64
+		rate   models.ExchangeRate
+		want   models.Money
+	}{
+		{"exact", 100000, usd(models.RoundNearest, 1), models.NewMoney(1200, "USD")},
+		{"nearest", 99900, usd(models.RoundNearest, 1), models.NewMoney(1199, "USD")},
+		{"down", 99900, usd(models.RoundDown, 1), models.NewMoney(1198, "USD")},
+		{"up", 99850, usd(models.RoundUp, 1), models.NewMoney(1199, "USD")},
+		{"up when exact", 100000, usd(models.RoundUp, 1), models.NewMoney(1200, "USD")},
+		{"nearest increment", 99900, usd(models.RoundNearest, 5), models.NewMoney(1200, "USD")},
+		{"down to an increment", 99900, usd(models.RoundDown, 5), models.NewMoney(1195, "USD")},
+		{"up to a whole dollar", 99900, usd(models.RoundUp, 100), models.NewMoney(1200, "USD")},
+		{"half rounds away from zero", 50, models.ExchangeRate{Currency: "USD", Rate: "0.01", Rounding: models.RoundNearest}, models.NewMoney(1, "USD")},
+		{"negative half rounds away from zero", -50, models.ExchangeRate{Currency: "USD", Rate: "0.01", Rounding: models.RoundNearest}, models.NewMoney(-1, "USD")},
+		{"negative down", -50, models.ExchangeRate{Currency: "USD", Rate: "0.01", Rounding: models.RoundDown}, models.NewMoney(-1, "USD")},
+		{"to no decimals", 12345, models.ExchangeRate{Currency: "JPY", Rate: "1.8", Rounding: models.RoundNearest}, models.NewMoney(222, "JPY")},
+		{"to three decimals", 100000, models.ExchangeRate{Currency: "KWD", Rate: "0.0037", Rounding: models.RoundNearest}, models.NewMoney(3700, "KWD")},
+		{"base currency", 12345, baseRate(), models.NewMoney(12345, models.BaseCurrency)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertMoney(models.NewMoney(tt.amount, models.BaseCurrency), tt.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertMoney(%d) = %v, want %v", tt.amount, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ConvertMoney(models.NewMoney(100, "EUR"), usd(models.RoundNearest, 1)); !errors.Is(err, models.ErrCurrencyMismatch) {
+		t.Errorf("converting from a currency other than the catalog's: err = %v, want %v", err, models.ErrCurrencyMismatch)
+	}
+}
+
+func TestSaveExchangeRate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for _, rate := range []string{"", "0", "-0.5", "abc"} {
+		if _, err := SaveExchangeRate(ctx, store, models.ExchangeRate{Currency: "USD", Rate: rate}); !errors.Is(err, ErrInvalidRate) {
+			t.Errorf("rate %q: err = %v, want %v", rate, err, ErrInvalidRate)
+		}
+	}
+	if _, err := SaveExchangeRate(ctx, store, models.ExchangeRate{Currency: models.BaseCurrency, Rate: "1"}); !errors.Is(err, ErrBaseCurrencyRate) {
+		t.Errorf("rate for the base currency: err = %v, want %v", err, ErrBaseCurrencyRate)
+	}
+
+	saved, err := SaveExchangeRate(ctx, store, models.ExchangeRate{Currency: " usd ", Rate: "0.012"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Currency != "USD" || saved.Rounding != models.RoundNearest || saved.Increment != 1 {
+		t.Errorf("saved rate = %+v, want USD rounded to the nearest cent", saved)
+	}
+}
+
+func TestRateFor(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	if _, err := SaveExchangeRate(ctx, store, models.ExchangeRate{Currency: "USD", Rate: "0.012"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		currency string
+		want     string
+		err      error
+	}{
+		{"", models.BaseCurrency, nil},
+		{models.BaseCurrency, models.BaseCurrency, nil},
+		{"usd", "USD", nil},
+		{"EUR", "", ErrUnsupportedCurrency},
+	}
+	for _, tt := range tests {
+		rate, err := RateFor(ctx, store, tt.currency)
+		if !errors.Is(err, tt.err) || rate.Currency != tt.want {
+			t.Errorf("RateFor(%q) = %q, %v, want %q, %v", tt.currency, rate.Currency, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestConvertCartAddsUp(t *testing.T) {
+	inr := func(amount int64) models.Money { return models.NewMoney(amount, models.BaseCurrency) }
+	summary := CartSummary{
+		Subtotal:       inr(99900),
+		CouponDiscount: inr(9990),
+		Discount:       inr(9990),
+		Tax:            inr(16182),
+		Total:          inr(106092),
+	}
+	rate := models.ExchangeRate{Currency: "USD", Rate: "0.012", Rounding: models.RoundNearest, Increment: 1}
+
+	converted, err := ConvertCart(summary, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := converted.Subtotal.Add(converted.Tax)
+	if err == nil {
+		sum, err = sum.Sub(converted.Discount)
+	}
+	if err != nil || sum != converted.Total {
+		t.Errorf("subtotal %v + tax %v - discount %v = %v, %v, want the total %v", converted.Subtotal, converted.Tax, converted.Discount, sum, err, converted.Total)
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+// PriceBuckets are the lower bounds of the price ranges counted by the price
+// facet, in major units of models.BaseCurrency. The last bucket is open
+// ended.
+var PriceBuckets = []uint64{0, 25, 50, 100, 250, 500, 1000}
+
+// FacetCount is the number of matching products sharing one facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets summarises a whole product search, not just the page returned, so
+// the storefront can offer filters. Categories and brands are ordered by
+// count; prices and ratings by value.
+type Facets struct {
+	Categories []FacetCount `json:"categories"`
+	Brands     []FacetCount `json:"brands"`
+	Prices     []FacetCount `json:"prices"`
+	Ratings    []FacetCount `json:"ratings"`
+}
+
+// priceBucketLabel names the bucket starting at lower, e.g. "25-50" or "1000+".
+func priceBucketLabel(lower uint64) string {
+	for i, bound := range PriceBuckets {
+		if bound == lower && i+1 < len(PriceBuckets) {
+			return fmt.Sprintf("%d-%d", lower, PriceBuckets[i+1])
+		}
+	}
+	return fmt.Sprintf("%d+", lower)
+}
+
+// priceBucket returns the lower bound of the bucket price, in minor units,
+// falls in.
+func priceBucket(price uint64) uint64 {
+	price /= uint64(majorUnit())
+	lower := PriceBuckets[0]
+	for _, bound := range PriceBuckets {
+		if price >= bound {
+			lower = bound
+		}
+	}
+	return lower
+}
+
+// countFacets computes Facets in process for the memory store.
+func countFacets(products []models.Product) Facets {
+	categories := map[string]int64{}
+	brands := map[string]int64{}
+	prices := map[uint64]int64{}
+	ratings := map[uint8]int64{}
+	for _, product := range products {
+		for _, category := range product.Categories {
+			categories[category]++
+		}
+		if product.Brand != "" {
+			brands[product.Brand]++
+		}
+		prices[priceBucket(uint64(product.Price.Amount))]++
+		ratings[product.Rating]++
+	}
+
+	facets := Facets{
+		Categories: byCount(categories),
+		Brands:     byCount(brands),
+		Prices:     []FacetCount{},
+		Ratings:    []FacetCount{},
+	}
+	for _, lower := range PriceBuckets {
+		if prices[lower] > 0 {
+			facets.Prices = append(facets.Prices, FacetCount{Value: priceBucketLabel(lower), Count: prices[lower]})
+		}
+	}
+	for rating := uint8(0); rating <= 5; rating++ {
+		if ratings[rating] > 0 {
+			facets.Ratings = append(facets.Ratings, FacetCount{Value: strconv.Itoa(int(rating)), Count: ratings[rating]})
+		}
+	}
+	return facets
+}
+
+// byCount orders facet values by count, most common first, then by value.
+func byCount(counts map[string]int64) []FacetCount {
+	facets := []FacetCount{}
+	for value, count := range counts {
+		facets = append(facets, FacetCount{Value: value, Count: count})
+	}
+	sortFacets(facets)
+	return facets
+}
+
+func sortFacets(facets []FacetCount) {
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+}
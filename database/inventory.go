@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidStockReason = errors.New("reason must be one of restock, correction, damaged, lost or returned")
+	ErrInvalidStockDelta  = errors.New("delta must not be zero")
+	ErrCantReserveStock   = errors.New("cannot reserve stock for the cart")
+)
+
+// ReservationTTL is how long stock stays held for a checkout that has been
+// started but not completed.
+var ReservationTTL = 15 * time.Minute
+
+var StockReasons = map[string]bool{
+	"restock":    true,
+	"correction": true,
+	"damaged":    true,
+	"lost":       true,
+	"returned":   true,
+}
+
+// StockError reports every order line that could not be fulfilled.
+type StockError struct {
+	Shortages []models.StockShortage
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("insufficient stock for %d item(s)", len(e.Shortages))
+}
+
+// reserveLines takes stock for every line or for none of them. When any line
+// is short the lines already taken are handed back and a *StockError listing
+// each short line is returned.
+func reserveLines(ctx context.Context, products ProductStore, lines []models.ReservationLine) error {
+	var taken []models.ReservationLine
+	var shortages []models.StockShortage
+
+	for _, line := range lines {
+		err := products.DecrementStock(ctx, line.ProductID, line.VariantSKU, line.Quantity)
+		if err == nil {
+			taken = append(taken, line)
+			continue
+		}
+		if !errors.Is(err, ErrInsufficientStock) {
+			releaseLines(ctx, products, taken)
+			return err
+		}
+
+		shortage := models.StockShortage{ProductID: line.ProductID, VariantSKU: line.VariantSKU, Requested: line.Quantity}
+		if product, err := products.FindProductByID(ctx, line.ProductID); err == nil {
+			shortage.ProductName = product.ProductName
+			shortage.Available = product.Stock
+			if line.VariantSKU != "" {
+				variant, _ := product.Variant(line.VariantSKU)
+				shortage.Available = variant.Stock
+			}
+		}
+		shortages = append(shortages, shortage)
+	}
+
+	if len(shortages) > 0 {
+		releaseLines(ctx, products, taken)
+		return &StockError{Shortages: shortages}
+	}
+	return nil
+}
+
+func releaseLines(ctx context.Context, products ProductStore, lines []models.ReservationLine) {
+	for _, line := range lines {
+		if err := products.IncrementStock(ctx, line.ProductID, line.VariantSKU, line.Quantity); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func reservationLines(items []models.ProductUser) []models.ReservationLine {
+	lines := []models.ReservationLine{}
+	for _, item := range items {
+		lines = append(lines, models.ReservationLine{ProductID: item.ProductID, VariantSKU: item.VariantSKU, Quantity: lineQuantity(item)})
+	}
+	return lines
+}
+
+func sameLines(a, b []models.ReservationLine) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	quantities := map[lineKey]uint64{}
+	for _, line := range a {
+		quantities[lineKey{line.ProductID, line.VariantSKU}] += line.Quantity
+	}
+	for _, line := range b {
+		if quantities[lineKey{line.ProductID, line.VariantSKU}] != line.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// ReserveCart holds stock for everything in the user's cart for
+// ReservationTTL. Any earlier reservation of the same user is released first.
+func ReserveCart(ctx context.Context, users UserStore, products ProductStore, inventory InventoryStore, userID string) (models.Reservation, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return models.Reservation{}, ErrUserIdIsNotValid
+	}
+
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		log.Println(err)
+		return models.Reservation{}, ErrCantGetItem
+	}
+	if len(user.UserCart) == 0 {
+		return models.Reservation{}, errors.New("cart is empty")
+	}
+	cart, err := checkoutCart(ctx, products, user.UserCart)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	if err := ReleaseUserReservation(ctx, products, inventory, userObjectID); err != nil {
+		return models.Reservation{}, err
+	}
+
+	lines := reservationLines(cart)
+	if err := reserveLines(ctx, products, lines); err != nil {
+		return models.Reservation{}, err
+	}
+
+	now := time.Now()
+	reservation := models.Reservation{
+		ID:        primitive.NewObjectID(),
+		UserID:    userObjectID,
+		Lines:     lines,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ReservationTTL),
+	}
+	if err := inventory.InsertReservation(ctx, reservation); err != nil {
+		log.Println(err)
+		releaseLines(ctx, products, lines)
+		return models.Reservation{}, ErrCantReserveStock
+	}
+
+	return reservation, nil
+}
+
+// ReleaseUserReservation hands back any stock currently held for the user.
+func ReleaseUserReservation(ctx context.Context, products ProductStore, inventory InventoryStore, userID primitive.ObjectID) error {
+	reservation, err := inventory.FindReservationByUser(ctx, userID)
+	if errors.Is(err, ErrReservationNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Println(err)
+		return ErrCantReserveStock
+	}
+	return releaseReservation(ctx, products, inventory, reservation)
+}
+
+func releaseReservation(ctx context.Context, products ProductStore, inventory InventoryStore, reservation models.Reservation) error {
+	deleted, err := inventory.DeleteReservation(ctx, reservation.ID)
+	if err != nil {
+		log.Println(err)
+		return ErrCantReserveStock
+	}
+	if deleted {
+		releaseLines(ctx, products, reservation.Lines)
+	}
+	return nil
+}
+
+// ReleaseExpiredReservations returns the stock of every reservation whose
+// checkout window has passed.
+func ReleaseExpiredReservations(ctx context.Context, products ProductStore, inventory InventoryStore) error {
+	reservations, err := inventory.FindExpiredReservations(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, reservation := range reservations {
+		if err := releaseReservation(ctx, products, inventory, reservation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SweepExpiredReservations calls ReleaseExpiredReservations every interval
+// until ctx is cancelled.
+func SweepExpiredReservations(ctx context.Context, products ProductStore, inventory InventoryStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ReleaseExpiredReservations(ctx, products, inventory); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// takeStockForOrder makes sure stock for lines is taken before an order is
+// written. A live reservation covering exactly these lines is consumed as is;
+// otherwise any stale reservation is released and stock is taken directly.
+func takeStockForOrder(ctx context.Context, products ProductStore, inventory InventoryStore, userID primitive.ObjectID, lines []models.ReservationLine) error {
+	reservation, err := inventory.FindReservationByUser(ctx, userID)
+	if err != nil && !errors.Is(err, ErrReservationNotFound) {
+		log.Println(err)
+		return ErrCantReserveStock
+	}
+
+	if err == nil {
+		if reservation.ExpiresAt.After(time.Now()) && sameLines(reservation.Lines, lines) {
+			deleted, err := inventory.DeleteReservation(ctx, reservation.ID)
+			if err != nil {
+				log.Println(err)
+				return ErrCantReserveStock
+			}
+			if deleted {
+				return nil
+			}
+		} else if err := releaseReservation(ctx, products, inventory, reservation); err != nil {
+			return err
+		}
+	}
+
+	return reserveLines(ctx, products, lines)
+}
+
+// AdjustStock applies a manual stock change and records it with its reason.
+// Products with variants are adjusted one variant at a time.
+func AdjustStock(ctx context.Context, products ProductStore, inventory InventoryStore, productID primitive.ObjectID, sku string, delta int64, reason string, note string) (models.StockAdjustment, error) {
+	if !StockReasons[reason] {
+		return models.StockAdjustment{}, ErrInvalidStockReason
+	}
+
+	product, err := products.FindProductByID(ctx, productID)
+	if err != nil {
+		return models.StockAdjustment{}, err
+	}
+	if _, err := resolveVariant(product, sku); err != nil {
+		return models.StockAdjustment{}, err
+	}
+
+	switch {
+	case delta > 0:
+		err = products.IncrementStock(ctx, productID, sku, uint64(delta))
+	case delta < 0:
+		err = products.DecrementStock(ctx, productID, sku, uint64(-delta))
+	default:
+		return models.StockAdjustment{}, ErrInvalidStockDelta
+	}
+	if err != nil {
+		return models.StockAdjustment{}, err
+	}
+
+	adjustment := models.StockAdjustment{
+		ID:         primitive.NewObjectID(),
+		ProductID:  productID,
+		VariantSKU: sku,
+		Delta:      delta,
+		Reason:     reason,
+		Note:       note,
+		CreatedAt:  time.Now(),
+	}
+	if err := inventory.InsertStockAdjustment(ctx, adjustment); err != nil {
+		log.Println(err)
+	}
+
+	return adjustment, nil
+}
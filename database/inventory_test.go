@@ -0,0 +1,174 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+func TestReserveCartHoldsStockForCheckout(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+	s.addToCart(kettle, 2)
+
+	if _, err := ReserveCart(s.ctx, s.store, s.store, s.store, s.userID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if stock := s.stock(kettle); stock != 3 {
+		t.Fatalf("stock = %d with 2 of 5 reserved, want 3", stock)
+	}
+
+	// Reserving again replaces the reservation rather than adding to it.
+	if _, err := ReserveCart(s.ctx, s.store, s.store, s.store, s.userID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if stock := s.stock(kettle); stock != 3 {
+		t.Fatalf("stock = %d after reserving the same cart twice, want 3", stock)
+	}
+
+	// Checkout uses the reservation instead of taking the stock again.
+	if _, err := s.checkout(models.PaymentMethodCOD); err != nil {
+		t.Fatal(err)
+	}
+	if stock := s.stock(kettle); stock != 3 {
+		t.Errorf("stock = %d after checking out a reserved cart, want 3", stock)
+	}
+	if _, err := s.store.FindReservationByUser(s.ctx, s.userID); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("reservation still there after checkout: err = %v", err)
+	}
+}
+
+func TestCheckoutReleasesStaleReservation(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+	s.addToCart(kettle, 2)
+	if _, err := ReserveCart(s.ctx, s.store, s.store, s.store, s.userID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cart changed after it was reserved, so the reservation no longer
+	// covers it and is handed back before the new lines are taken.
+	s.addToCart(kettle, 1)
+	if _, err := s.checkout(models.PaymentMethodCOD); err != nil {
+		t.Fatal(err)
+	}
+	if stock := s.stock(kettle); stock != 2 {
+		t.Errorf("stock = %d after checking out 3 of 5, want 2", stock)
+	}
+}
+
+func TestReleaseUserReservation(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+	s.addToCart(kettle, 4)
+	if _, err := ReserveCart(s.ctx, s.store, s.store, s.store, s.userID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := ReleaseUserReservation(s.ctx, s.store, s.store, s.userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stock := s.stock(kettle); stock != 5 {
+		t.Errorf("stock = %d after releasing twice, want 5", stock)
+	}
+}
+
+func TestReleaseExpiredReservations(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+	s.addToCart(kettle, 2)
+
+	defer func(ttl time.Duration) { ReservationTTL = ttl }(ReservationTTL)
+	ReservationTTL = -time.Second
+	if _, err := ReserveCart(s.ctx, s.store, s.store, s.store, s.userID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseExpiredReservations(s.ctx, s.store, s.store); err != nil {
+		t.Fatal(err)
+	}
+	if stock := s.stock(kettle); stock != 5 {
+		t.Errorf("stock = %d after the reservation expired, want 5", stock)
+	}
+}
+
+func TestReserveCartIsAllOrNothing(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+	toaster := s.product("Toaster", 30000, 1)
+	s.addToCart(kettle, 2)
+	s.addToCart(toaster, 3)
+
+	_, err := ReserveCart(s.ctx, s.store, s.store, s.store, s.userID.Hex())
+	var stockErr *StockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("ReserveCart() error = %v, want a *StockError", err)
+	}
+	want := models.StockShortage{ProductID: toaster, ProductName: "Toaster", Requested: 3, Available: 1}
+	if len(stockErr.Shortages) != 1 || stockErr.Shortages[0] != want {
+		t.Errorf("shortages = %+v, want [%+v]", stockErr.Shortages, want)
+	}
+	if stock := s.stock(kettle); stock != 5 {
+		t.Errorf("kettle stock = %d after a failed reservation, want it given back", stock)
+	}
+	if stock := s.stock(toaster); stock != 1 {
+		t.Errorf("toaster stock = %d after a failed reservation, want 1", stock)
+	}
+}
+
+func TestInstantBuyerTakesStock(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 1)
+
+	buy := func() error {
+		_, err := InstantBuyer(s.ctx, s.store, s.store, s.store, s.store, s.store, baseRate(), models.PaymentMethodCOD, kettle, "", s.userID.Hex(), "")
+		return err
+	}
+	if err := buy(); err != nil {
+		t.Fatal(err)
+	}
+	var stockErr *StockError
+	if err := buy(); !errors.As(err, &stockErr) {
+		t.Errorf("buying the last unit twice: err = %v, want a *StockError", err)
+	}
+	if stock := s.stock(kettle); stock != 0 {
+		t.Errorf("stock = %d, want 0", stock)
+	}
+}
+
+func TestAdjustStock(t *testing.T) {
+	tests := []struct {
+		name   string
+		delta  int64
+		reason string
+		want   uint64
+		err    error
+	}{
+		{"restock", 10, "restock", 13, nil},
+		{"write off", -2, "damaged", 1, nil},
+		{"write off everything", -3, "lost", 0, nil},
+		{"below zero", -4, "correction", 3, ErrInsufficientStock},
+		{"zero", 0, "correction", 3, ErrInvalidStockDelta},
+		{"unknown reason", 1, "gift", 3, ErrInvalidStockReason},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			kettle := s.product("Kettle", 25000, 3)
+
+			adjustment, err := AdjustStock(s.ctx, s.store, s.store, kettle, "", tt.delta, tt.reason, "")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("AdjustStock() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (adjustment.Delta != tt.delta || adjustment.Reason != tt.reason) {
+				t.Errorf("adjustment = %+v, want delta %d for %s", adjustment, tt.delta, tt.reason)
+			}
+			if stock := s.stock(kettle); stock != tt.want {
+				t.Errorf("stock = %d, want %d", stock, tt.want)
+			}
+		})
+	}
+}
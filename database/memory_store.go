@@ -0,0 +1,1167 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore is a Store kept entirely in process memory. It is meant for
+// unit tests and local demos; nothing survives a restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	users    map[primitive.ObjectID]models.User
+	products map[primitive.ObjectID]models.Product
+	// productOrder remembers insertion order so listings are stable.
+	productOrder     []primitive.ObjectID
+	orders           map[primitive.ObjectID]models.Order
+	orderOrder       []primitive.ObjectID
+	reservations     map[primitive.ObjectID]models.Reservation
+	stockAdjustments []models.StockAdjustment
+	audit            []models.AuditEntry
+	synonyms         []models.SynonymGroup
+	categories       []models.Category
+	coupons          map[string]models.Coupon
+	couponOrder      []string
+	couponUses       map[string]uint64
+	promotions       []models.Promotion
+	exchangeRates    map[string]models.ExchangeRate
+	taxRules         []models.TaxRule
+	paymentEvents    []models.PaymentEvent
+	ledger           []models.LedgerEntry
+	returns          []models.Return
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:         map[primitive.ObjectID]models.User{},
+		products:      map[primitive.ObjectID]models.Product{},
+		orders:        map[primitive.ObjectID]models.Order{},
+		reservations:  map[primitive.ObjectID]models.Reservation{},
+		coupons:       map[string]models.Coupon{},
+		couponUses:    map[string]uint64{},
+		exchangeRates: map[string]models.ExchangeRate{},
+	}
+}
+
+func (s *MemoryStore) FindUserByID(ctx context.Context, userID primitive.ObjectID) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *MemoryStore) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return cloneUser(user), nil
+		}
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+func (s *MemoryStore) CountUsersByEmail(ctx context.Context, email string) (int64, error) {
+	return s.countUsers(func(user models.User) bool { return user.Email == email }), nil
+}
+
+func (s *MemoryStore) CountUsersByPhone(ctx context.Context, phone string) (int64, error) {
+	return s.countUsers(func(user models.User) bool { return user.Phone == phone }), nil
+}
+
+func (s *MemoryStore) countUsers(match func(models.User) bool) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for _, user := range s.users {
+		if match(user) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *MemoryStore) InsertUser(ctx context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	s.users[user.ID] = cloneUser(user)
+	return nil
+}
+
+func (s *MemoryStore) UpdateRefreshToken(ctx context.Context, userID primitive.ObjectID, refreshToken string, family string) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		user.RefreshToken = refreshToken
+		user.TokenFamily = family
+		return nil
+	})
+}
+
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, userID primitive.ObjectID, oldToken string, newToken string) (bool, error) {
+	rotated := false
+	err := s.updateUser(userID, func(user *models.User) error {
+		if user.RefreshToken == oldToken {
+			user.RefreshToken = newToken
+			rotated = true
+		}
+		return nil
+	})
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	return rotated, err
+}
+
+func (s *MemoryStore) RevokeRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		user.RefreshToken = ""
+		user.TokenFamily = ""
+		return nil
+	})
+}
+
+func (s *MemoryStore) UpdateUserRole(ctx context.Context, userID primitive.ObjectID, role string) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		user.Role = role
+		return nil
+	})
+}
+
+func (s *MemoryStore) AddCartItem(ctx context.Context, userID primitive.ObjectID, item models.ProductUser) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		for i := range user.UserCart {
+			if sameCartLine(user.UserCart[i], item.ProductID, item.VariantSKU) {
+				user.UserCart[i].Quantity += item.Quantity
+				return nil
+			}
+		}
+		user.UserCart = append(user.UserCart, item)
+		return nil
+	})
+}
+
+func (s *MemoryStore) SetCartItemQuantity(ctx context.Context, userID primitive.ObjectID, productID primitive.ObjectID, sku string, quantity uint64) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		for i := range user.UserCart {
+			if sameCartLine(user.UserCart[i], productID, sku) {
+				user.UserCart[i].Quantity = quantity
+				return nil
+			}
+		}
+		return ErrCartItemNotFound
+	})
+}
+
+func (s *MemoryStore) PullCartItem(ctx context.Context, userID primitive.ObjectID, productID primitive.ObjectID, sku string) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		cart := []models.ProductUser{}
+		for _, item := range user.UserCart {
+			if !sameCartLine(item, productID, sku) {
+				cart = append(cart, item)
+			}
+		}
+		user.UserCart = cart
+		return nil
+	})
+}
+
+func (s *MemoryStore) SetCartCoupon(ctx context.Context, userID primitive.ObjectID, code string) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		user.CouponCode = code
+		return nil
+	})
+}
+
+func (s *MemoryStore) ClearCart(ctx context.Context, userID primitive.ObjectID) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		user.UserCart = []models.ProductUser{}
+		user.CouponCode = ""
+		return nil
+	})
+}
+
+func (s *MemoryStore) AddAddress(ctx context.Context, userID primitive.ObjectID, address models.Address) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		user.AddressDetails = append(user.AddressDetails, address)
+		return nil
+	})
+}
+
+func (s *MemoryStore) UpdateAddress(ctx context.Context, userID primitive.ObjectID, index int, address models.Address) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		if index < 0 || index >= len(user.AddressDetails) {
+			return ErrUserNotFound
+		}
+		address.AddressID = user.AddressDetails[index].AddressID
+		user.AddressDetails[index] = address
+		return nil
+	})
+}
+
+func (s *MemoryStore) ClearAddresses(ctx context.Context, userID primitive.ObjectID) error {
+	return s.updateUser(userID, func(user *models.User) error {
+		user.AddressDetails = []models.Address{}
+		return nil
+	})
+}
+
+// updateUser applies fn to a copy of the user and stores the result only when
+// fn succeeds, so a failed update leaves the user untouched.
+func (s *MemoryStore) updateUser(userID primitive.ObjectID, fn func(user *models.User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	user = cloneUser(user)
+	if err := fn(&user); err != nil {
+		return err
+	}
+	s.users[userID] = user
+	return nil
+}
+
+func (s *MemoryStore) FindProductByID(ctx context.Context, productID primitive.ObjectID) (models.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	product, ok := s.products[productID]
+	if !ok {
+		return models.Product{}, ErrCantFindProduct
+	}
+	return product, nil
+}
+
+func (s *MemoryStore) InsertProduct(ctx context.Context, product models.Product) (primitive.ObjectID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if product.ProductID.IsZero() {
+		product.ProductID = primitive.NewObjectID()
+	}
+	if _, ok := s.products[product.ProductID]; ok {
+		return product.ProductID, ErrProductExists
+	}
+	for _, variant := range product.Variants {
+		if _, ok := s.skuOwner(variant.SKU); ok {
+			return product.ProductID, ErrSKUExists
+		}
+	}
+
+	s.products[product.ProductID] = product
+	s.productOrder = append(s.productOrder, product.ProductID)
+	return product.ProductID, nil
+}
+
+func (s *MemoryStore) UpdateProduct(ctx context.Context, productID primitive.ObjectID, update models.ProductUpdate) (models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[productID]
+	if !ok || product.Deleted {
+		return models.Product{}, ErrCantFindProduct
+	}
+	if update.ProductName != nil {
+		product.ProductName = *update.ProductName
+	}
+	if update.Price != nil {
+		product.Price = *update.Price
+	}
+	if update.Rating != nil {
+		product.Rating = *update.Rating
+	}
+	if update.Image != nil {
+		product.Image = *update.Image
+	}
+	if update.Description != nil {
+		product.Description = *update.Description
+	}
+	if update.Tags != nil {
+		product.Tags = NormalizeTags(*update.Tags)
+	}
+	if update.Categories != nil {
+		product.Categories = *update.Categories
+	}
+	if update.Brand != nil {
+		product.Brand = *update.Brand
+	}
+	if update.Attributes != nil {
+		product.Attributes = *update.Attributes
+	}
+	if update.TaxClass != nil {
+		product.TaxClass = *update.TaxClass
+	}
+	product.UpdatedAt = time.Now()
+	s.products[productID] = product
+	return product, nil
+}
+
+func (s *MemoryStore) SoftDeleteProduct(ctx context.Context, productID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[productID]
+	if !ok || product.Deleted {
+		return ErrCantFindProduct
+	}
+	now := time.Now()
+	product.Deleted = true
+	product.DeletedAt = &now
+	product.UpdatedAt = now
+	s.products[productID] = product
+	return nil
+}
+
+func (s *MemoryStore) FindProducts(ctx context.Context, query ProductQuery) ([]models.Product, int64, error) {
+	products, scores := s.queryProducts(query)
+	if query.Text != "" && (query.Sort == "" || query.Sort == SortRelevance) {
+		sort.SliceStable(products, func(i, j int) bool {
+			return scores[products[i].ProductID] > scores[products[j].ProductID]
+		})
+	} else {
+		sortProducts(products, query.Sort)
+	}
+
+	return paginate(products, query.Skip, query.Limit), int64(len(products)), nil
+}
+
+func (s *MemoryStore) ProductFacets(ctx context.Context, query ProductQuery) (Facets, error) {
+	products, _ := s.queryProducts(query)
+	return countFacets(products), nil
+}
+
+// queryProducts returns the products matching query in insertion order,
+// along with their text scores when query.Text is set.
+func (s *MemoryStore) queryProducts(query ProductQuery) ([]models.Product, map[primitive.ObjectID]int32) {
+	terms := searchTerms(query.Text)
+	scores := map[primitive.ObjectID]int32{}
+
+	products := s.filterProducts(func(product models.Product) bool {
+		if !query.matches(product) {
+			return false
+		}
+		if query.Text == "" {
+			return true
+		}
+		scores[product.ProductID] = textScore(product, terms)
+		return scores[product.ProductID] > 0
+	})
+	return products, scores
+}
+
+func (s *MemoryStore) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	pattern := regexp.MustCompile("(?i)" + suggestionPattern(prefix))
+	products := s.filterProducts(func(product models.Product) bool {
+		return !product.Deleted && pattern.MatchString(product.ProductName)
+	})
+	sortProducts(products, SortRating)
+	return suggestions(products, limit), nil
+}
+
+func (s *MemoryStore) CatalogText(ctx context.Context) ([]string, error) {
+	products := s.filterProducts(func(product models.Product) bool { return !product.Deleted })
+	seen := map[string]bool{}
+	text := []string{}
+	for _, product := range products {
+		for _, value := range append([]string{product.ProductName}, product.Tags...) {
+			if !seen[value] {
+				seen[value] = true
+				text = append(text, value)
+			}
+		}
+	}
+	return text, nil
+}
+
+// sortProducts mirrors the Mongo store's ordering; products are already in
+// insertion order, which stands in for _id order.
+func sortProducts(products []models.Product, by string) {
+	switch by {
+	case SortPriceAsc:
+		sort.SliceStable(products, func(i, j int) bool { return products[i].Price.Amount < products[j].Price.Amount })
+	case SortPriceDesc:
+		sort.SliceStable(products, func(i, j int) bool { return products[i].Price.Amount > products[j].Price.Amount })
+	case SortRating:
+		sort.SliceStable(products, func(i, j int) bool { return products[i].Rating > products[j].Rating })
+	case SortNewest:
+		for i, j := 0, len(products)-1; i < j; i, j = i+1, j-1 {
+			products[i], products[j] = products[j], products[i]
+		}
+	}
+}
+
+func (s *MemoryStore) DecrementStock(ctx context.Context, productID primitive.ObjectID, sku string, quantity uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[productID]
+	if !ok {
+		return ErrInsufficientStock
+	}
+	stock, ok := productStock(&product, sku)
+	if !ok || *stock < quantity {
+		return ErrInsufficientStock
+	}
+	*stock -= quantity
+	s.products[productID] = product
+	return nil
+}
+
+func (s *MemoryStore) IncrementStock(ctx context.Context, productID primitive.ObjectID, sku string, quantity uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[productID]
+	if !ok {
+		return ErrCantFindProduct
+	}
+	stock, ok := productStock(&product, sku)
+	if !ok {
+		return ErrVariantNotFound
+	}
+	*stock += quantity
+	s.products[productID] = product
+	return nil
+}
+
+// productStock points at the stock counter of the product or of its variant.
+// The variants are copied first so that products already handed out keep
+// their own values.
+func productStock(product *models.Product, sku string) (*uint64, bool) {
+	if sku == "" {
+		return &product.Stock, true
+	}
+	product.Variants = append([]models.Variant{}, product.Variants...)
+	for i := range product.Variants {
+		if product.Variants[i].SKU == sku {
+			return &product.Variants[i].Stock, true
+		}
+	}
+	return nil, false
+}
+
+func (s *MemoryStore) SaveVariant(ctx context.Context, productID primitive.ObjectID, variant models.Variant) (models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[productID]
+	if !ok || product.Deleted {
+		return models.Product{}, ErrCantFindProduct
+	}
+	if owner, ok := s.skuOwner(variant.SKU); ok && owner != productID {
+		return models.Product{}, ErrSKUExists
+	}
+
+	product.Variants = append([]models.Variant{}, product.Variants...)
+	found := false
+	for i := range product.Variants {
+		if product.Variants[i].SKU == variant.SKU {
+			variant.Stock = product.Variants[i].Stock
+			product.Variants[i] = variant
+			found = true
+		}
+	}
+	if !found {
+		product.Variants = append(product.Variants, variant)
+	}
+	product.UpdatedAt = time.Now()
+	s.products[productID] = product
+	return product, nil
+}
+
+func (s *MemoryStore) RemoveVariant(ctx context.Context, productID primitive.ObjectID, sku string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[productID]
+	if !ok {
+		return ErrVariantNotFound
+	}
+	variants := []models.Variant{}
+	for _, variant := range product.Variants {
+		if variant.SKU != sku {
+			variants = append(variants, variant)
+		}
+	}
+	if len(variants) == len(product.Variants) {
+		return ErrVariantNotFound
+	}
+	product.Variants = variants
+	product.UpdatedAt = time.Now()
+	s.products[productID] = product
+	return nil
+}
+
+// skuOwner finds the product a SKU belongs to. Callers hold s.mu.
+func (s *MemoryStore) skuOwner(sku string) (primitive.ObjectID, bool) {
+	for id, product := range s.products {
+		if _, ok := product.Variant(sku); ok {
+			return id, true
+		}
+	}
+	return primitive.ObjectID{}, false
+}
+
+func (s *MemoryStore) filterProducts(match func(models.Product) bool) []models.Product {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := []models.Product{}
+	for _, id := range s.productOrder {
+		if product := s.products[id]; match(product) {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+func (s *MemoryStore) InsertOrder(ctx context.Context, order models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[order.OrderID] = cloneOrder(order)
+	s.orderOrder = append(s.orderOrder, order.OrderID)
+	return nil
+}
+
+func (s *MemoryStore) ListOrdersByUser(ctx context.Context, userID primitive.ObjectID, filter OrderFilter) ([]models.Order, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := []models.Order{}
+	for i := len(s.orderOrder) - 1; i >= 0; i-- {
+		order := s.orders[s.orderOrder[i]]
+		if order.UserID == userID && filter.matches(order) {
+			matched = append(matched, cloneOrder(order))
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].OrderedAt.After(matched[j].OrderedAt)
+	})
+
+	return paginate(matched, filter.Skip, filter.Limit), int64(len(matched)), nil
+}
+
+func (s *MemoryStore) FindUnpaidOrders(ctx context.Context, placedBefore time.Time) ([]models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orders := []models.Order{}
+	for _, id := range s.orderOrder {
+		order := s.orders[id]
+		payment := order.PaymentMethod
+		if order.Status != models.OrderPending || !order.OrderedAt.Before(placedBefore) || !payment.Digital {
+			continue
+		}
+		if payment.Status == "" || payment.Status == models.PaymentFailed || payment.Status == models.PaymentVoided {
+			orders = append(orders, cloneOrder(order))
+		}
+	}
+	return orders, nil
+}
+
+func (s *MemoryStore) FindOrderByID(ctx context.Context, orderID primitive.ObjectID) (models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return cloneOrder(order), nil
+}
+
+func (s *MemoryStore) UpdateOrderStatus(ctx context.Context, orderID primitive.ObjectID, change models.StatusChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok || order.Status != change.From {
+		return ErrOrderStatusChanged
+	}
+	order = cloneOrder(order)
+	order.Status = change.To
+	order.StatusHistory = append(order.StatusHistory, change)
+	s.orders[orderID] = order
+	return nil
+}
+
+func (s *MemoryStore) UpdateOrderPayment(ctx context.Context, orderID primitive.ObjectID, from models.PaymentStatus, payment models.Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok || order.PaymentMethod.Status != from {
+		return ErrPaymentChanged
+	}
+	order.PaymentMethod = payment
+	s.orders[orderID] = order
+	return nil
+}
+
+func (s *MemoryStore) AddOrderRefund(ctx context.Context, orderID primitive.ObjectID, known int, refund models.Refund) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok || len(order.Refunds) != known {
+		return ErrRefundConflict
+	}
+	order = cloneOrder(order)
+	order.Refunds = append(order.Refunds, refund)
+	s.orders[orderID] = order
+	return nil
+}
+
+func (s *MemoryStore) UpdateOrderRefund(ctx context.Context, orderID primitive.ObjectID, refund models.Refund) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	order = cloneOrder(order)
+	for i := range order.Refunds {
+		if order.Refunds[i].ID == refund.ID {
+			order.Refunds[i] = refund
+			s.orders[orderID] = order
+			return nil
+		}
+	}
+	return ErrOrderNotFound
+}
+
+func (s *MemoryStore) InsertReservation(ctx context.Context, reservation models.Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservation.Lines = append([]models.ReservationLine{}, reservation.Lines...)
+	s.reservations[reservation.ID] = reservation
+	return nil
+}
+
+func (s *MemoryStore) FindReservationByUser(ctx context.Context, userID primitive.ObjectID) (models.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, reservation := range s.reservations {
+		if reservation.UserID == userID {
+			return reservation, nil
+		}
+	}
+	return models.Reservation{}, ErrReservationNotFound
+}
+
+func (s *MemoryStore) FindExpiredReservations(ctx context.Context, now time.Time) ([]models.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reservations := []models.Reservation{}
+	for _, reservation := range s.reservations {
+		if !reservation.ExpiresAt.After(now) {
+			reservations = append(reservations, reservation)
+		}
+	}
+	return reservations, nil
+}
+
+func (s *MemoryStore) DeleteReservation(ctx context.Context, reservationID primitive.ObjectID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reservations[reservationID]; !ok {
+		return false, nil
+	}
+	delete(s.reservations, reservationID)
+	return true, nil
+}
+
+func (s *MemoryStore) InsertStockAdjustment(ctx context.Context, adjustment models.StockAdjustment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stockAdjustments = append(s.stockAdjustments, adjustment)
+	return nil
+}
+
+func paginate[T any](items []T, skip, limit int64) []T {
+	if skip >= int64(len(items)) {
+		return []T{}
+	}
+	items = items[skip:]
+	if limit > 0 && limit < int64(len(items)) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func (s *MemoryStore) InsertAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+func cloneOrder(order models.Order) models.Order {
+	order.OrderCart = append([]models.ProductUser{}, order.OrderCart...)
+	order.StatusHistory = append([]models.StatusChange{}, order.StatusHistory...)
+	order.Refunds = append([]models.Refund(nil), order.Refunds...)
+	return order
+}
+
+func sameCartLine(item models.ProductUser, productID primitive.ObjectID, sku string) bool {
+	return item.ProductID == productID && item.VariantSKU == sku
+}
+
+func cloneUser(user models.User) models.User {
+	user.UserCart = append([]models.ProductUser{}, user.UserCart...)
+	user.AddressDetails = append([]models.Address{}, user.AddressDetails...)
+	user.Order = append([]models.Order{}, user.Order...)
+	return user
+}
+
+func (s *MemoryStore) ListSynonymGroups(ctx context.Context) ([]models.SynonymGroup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := []models.SynonymGroup{}
+	for _, group := range s.synonyms {
+		group.Terms = append([]string{}, group.Terms...)
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func (s *MemoryStore) SaveSynonymGroup(ctx context.Context, group models.SynonymGroup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group.Terms = append([]string{}, group.Terms...)
+	for i := range s.synonyms {
+		if s.synonyms[i].ID == group.ID {
+			s.synonyms[i] = group
+			return nil
+		}
+	}
+	s.synonyms = append(s.synonyms, group)
+	return nil
+}
+
+func (s *MemoryStore) DeleteSynonymGroup(ctx context.Context, groupID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.synonyms {
+		if s.synonyms[i].ID == groupID {
+			s.synonyms = append(s.synonyms[:i], s.synonyms[i+1:]...)
+			return nil
+		}
+	}
+	return ErrSynonymNotFound
+}
+
+func (s *MemoryStore) ListCategories(ctx context.Context) ([]models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.Category{}, s.categories...), nil
+}
+
+func (s *MemoryStore) InsertCategory(ctx context.Context, category models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.categories {
+		if existing.Slug == category.Slug {
+			return ErrCategoryExists
+		}
+	}
+	s.categories = append(s.categories, category)
+	return nil
+}
+
+func (s *MemoryStore) UpdateCategory(ctx context.Context, slug string, update models.CategoryUpdate) (models.Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, category := range s.categories {
+		if category.Slug != slug {
+			continue
+		}
+		if update.Name != nil {
+			category.Name = *update.Name
+		}
+		if update.Parent != nil {
+			category.Parent = *update.Parent
+		}
+		if update.DisplayOrder != nil {
+			category.DisplayOrder = *update.DisplayOrder
+		}
+		category.UpdatedAt = time.Now()
+		s.categories[i] = category
+		return category, nil
+	}
+	return models.Category{}, ErrCategoryNotFound
+}
+
+func (s *MemoryStore) DeleteCategory(ctx context.Context, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, category := range s.categories {
+		if category.Slug == slug {
+			s.categories = append(s.categories[:i], s.categories[i+1:]...)
+			return nil
+		}
+	}
+	return ErrCategoryNotFound
+}
+
+func (s *MemoryStore) InsertCoupon(ctx context.Context, coupon models.Coupon) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.coupons[coupon.Code]; ok {
+		return ErrCouponExists
+	}
+	s.coupons[coupon.Code] = coupon
+	s.couponOrder = append(s.couponOrder, coupon.Code)
+	return nil
+}
+
+func (s *MemoryStore) FindCouponByCode(ctx context.Context, code string) (models.Coupon, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coupon, ok := s.coupons[code]
+	if !ok {
+		return models.Coupon{}, ErrCouponNotFound
+	}
+	return coupon, nil
+}
+
+func (s *MemoryStore) ListCoupons(ctx context.Context) ([]models.Coupon, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coupons := []models.Coupon{}
+	for _, code := range s.couponOrder {
+		if coupon, ok := s.coupons[code]; ok {
+			coupons = append(coupons, coupon)
+		}
+	}
+	return coupons, nil
+}
+
+func (s *MemoryStore) DeleteCoupon(ctx context.Context, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.coupons[code]; !ok {
+		return ErrCouponNotFound
+	}
+	delete(s.coupons, code)
+	// The code may be created again, and must then be listed only once.
+	s.couponOrder = slices.DeleteFunc(s.couponOrder, func(listed string) bool { return listed == code })
+	return nil
+}
+
+func (s *MemoryStore) CountCouponUses(ctx context.Context, code string, userID primitive.ObjectID) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.couponUses[couponUseID(code, userID)], nil
+}
+
+func (s *MemoryStore) RedeemCoupon(ctx context.Context, coupon models.Coupon, userID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.coupons[coupon.Code]
+	if !ok || (coupon.UsageLimit > 0 && stored.Used >= coupon.UsageLimit) {
+		return ErrCouponExhausted
+	}
+	key := couponUseID(coupon.Code, userID)
+	if coupon.PerUserLimit > 0 && s.couponUses[key] >= coupon.PerUserLimit {
+		return ErrCouponUsedUp
+	}
+	stored.Used++
+	s.coupons[coupon.Code] = stored
+	s.couponUses[key]++
+	return nil
+}
+
+func (s *MemoryStore) ReleaseCoupon(ctx context.Context, code string, userID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.coupons[code]; ok && stored.Used > 0 {
+		stored.Used--
+		s.coupons[code] = stored
+	}
+	if key := couponUseID(code, userID); s.couponUses[key] > 0 {
+		s.couponUses[key]--
+	}
+	return nil
+}
+
+func (s *MemoryStore) InsertPromotion(ctx context.Context, promotion models.Promotion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.promotions = append(s.promotions, promotion)
+	return nil
+}
+
+func (s *MemoryStore) ListPromotions(ctx context.Context) ([]models.Promotion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.Promotion{}, s.promotions...), nil
+}
+
+func (s *MemoryStore) DeletePromotion(ctx context.Context, promotionID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.promotions {
+		if s.promotions[i].ID == promotionID {
+			s.promotions = append(s.promotions[:i], s.promotions[i+1:]...)
+			return nil
+		}
+	}
+	return ErrPromotionNotFound
+}
+
+func (s *MemoryStore) FindExchangeRate(ctx context.Context, currency string) (models.ExchangeRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rate, ok := s.exchangeRates[currency]
+	if !ok {
+		return models.ExchangeRate{}, ErrRateNotFound
+	}
+	return rate, nil
+}
+
+func (s *MemoryStore) ListExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rates := []models.ExchangeRate{}
+	for _, rate := range s.exchangeRates {
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Currency < rates[j].Currency })
+	return rates, nil
+}
+
+func (s *MemoryStore) SaveExchangeRate(ctx context.Context, rate models.ExchangeRate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.exchangeRates[rate.Currency] = rate
+	return nil
+}
+
+func (s *MemoryStore) DeleteExchangeRate(ctx context.Context, currency string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.exchangeRates[currency]; !ok {
+		return ErrRateNotFound
+	}
+	delete(s.exchangeRates, currency)
+	return nil
+}
+
+func (s *MemoryStore) InsertTaxRule(ctx context.Context, rule models.TaxRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.taxRules = append(s.taxRules, rule)
+	return nil
+}
+
+func (s *MemoryStore) ListTaxRules(ctx context.Context) ([]models.TaxRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.TaxRule{}, s.taxRules...), nil
+}
+
+func (s *MemoryStore) DeleteTaxRule(ctx context.Context, ruleID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.taxRules {
+		if s.taxRules[i].ID == ruleID {
+			s.taxRules = append(s.taxRules[:i], s.taxRules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrTaxRuleNotFound
+}
+
+func (s *MemoryStore) InsertPaymentEvent(ctx context.Context, event models.PaymentEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.paymentEvents {
+		if existing.ID == event.ID {
+			return ErrEventExists
+		}
+	}
+	s.paymentEvents = append(s.paymentEvents, event)
+	return nil
+}
+
+func (s *MemoryStore) FindPaymentEvent(ctx context.Context, id string) (models.PaymentEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, event := range s.paymentEvents {
+		if event.ID == id {
+			return event, nil
+		}
+	}
+	return models.PaymentEvent{}, ErrEventNotFound
+}
+
+func (s *MemoryStore) ListPaymentEvents(ctx context.Context, limit int64) ([]models.PaymentEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []models.PaymentEvent{}
+	for i := len(s.paymentEvents) - 1; i >= 0 && int64(len(events)) < limit; i-- {
+		events = append(events, s.paymentEvents[i])
+	}
+	return events, nil
+}
+
+func (s *MemoryStore) UpdatePaymentEvent(ctx context.Context, event models.PaymentEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.paymentEvents {
+		if s.paymentEvents[i].ID == event.ID {
+			s.paymentEvents[i].OrderID = event.OrderID
+			s.paymentEvents[i].Outcome = event.Outcome
+			s.paymentEvents[i].Error = event.Error
+			s.paymentEvents[i].ProcessedAt = event.ProcessedAt
+			s.paymentEvents[i].Replays = event.Replays
+			return nil
+		}
+	}
+	return ErrEventNotFound
+}
+
+func (s *MemoryStore) InsertLedgerEntry(ctx context.Context, entry models.LedgerEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ledger = append(s.ledger, entry)
+	return nil
+}
+
+func (s *MemoryStore) ListLedgerEntries(ctx context.Context, limit int64) ([]models.LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []models.LedgerEntry{}
+	for i := len(s.ledger) - 1; i >= 0 && int64(len(entries)) < limit; i-- {
+		entries = append(entries, s.ledger[i])
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) InsertReturn(ctx context.Context, ret models.Return) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.returns = append(s.returns, cloneReturn(ret))
+	return nil
+}
+
+func (s *MemoryStore) FindReturnByID(ctx context.Context, returnID primitive.ObjectID) (models.Return, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ret := range s.returns {
+		if ret.ID == returnID {
+			return cloneReturn(ret), nil
+		}
+	}
+	return models.Return{}, ErrReturnNotFound
+}
+
+func (s *MemoryStore) ListReturnsByOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Return, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	returns := []models.Return{}
+	for _, ret := range s.returns {
+		if ret.OrderID == orderID {
+			returns = append(returns, cloneReturn(ret))
+		}
+	}
+	return returns, nil
+}
+
+func (s *MemoryStore) ListReturns(ctx context.Context, status models.ReturnStatus, limit int64) ([]models.Return, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	returns := []models.Return{}
+	for i := len(s.returns) - 1; i >= 0 && int64(len(returns)) < limit; i-- {
+		if status == "" || s.returns[i].Status == status {
+			returns = append(returns, cloneReturn(s.returns[i]))
+		}
+	}
+	return returns, nil
+}
+
+func (s *MemoryStore) UpdateReturn(ctx context.Context, from models.ReturnStatus, ret models.Return) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.returns {
+		if s.returns[i].ID == ret.ID {
+			if s.returns[i].Status != from {
+				return ErrReturnChanged
+			}
+			s.returns[i] = cloneReturn(ret)
+			return nil
+		}
+	}
+	return ErrReturnChanged
+}
+
+func cloneReturn(ret models.Return) models.Return {
+	ret.Lines = append([]models.ReturnLine{}, ret.Lines...)
+	ret.Photos = append([]string(nil), ret.Photos...)
+	ret.History = append([]models.ReturnChange{}, ret.History...)
+	return ret
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	embeddedOrdersMigration = "embedded_orders_to_collection"
+	moneyAmountsMigration   = "money_amounts"
+)
+
+// MigrateEmbeddedOrders lifts orders that older releases pushed into the users
+// document out into the orders collection. It records itself in the
+// migrations collection and does nothing on later runs. Orders are upserted by
+// ID, so a run that was interrupted half way can simply be repeated.
+func (s *MongoStore) MigrateEmbeddedOrders(ctx context.Context) (int, error) {
+	done, err := s.migrations.CountDocuments(ctx, bson.M{"_id": embeddedOrdersMigration})
+	if err != nil {
+		return 0, err
+	}
+	if done > 0 {
+		return 0, nil
+	}
+
+	cursor, err := s.users.Find(ctx, bson.M{"orders.0": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	moved := 0
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return moved, err
+		}
+
+		for _, order := range user.Order {
+			order.UserID = user.ID
+			if order.Status == "" {
+				order.Status = models.OrderPending
+				order.StatusHistory = []models.StatusChange{{
+					To:   models.OrderPending,
+					At:   order.OrderedAt,
+					Note: "migrated from the users collection",
+				}}
+			}
+
+			_, err := s.orders.ReplaceOne(ctx, bson.M{"_id": order.OrderID}, order, options.Replace().SetUpsert(true))
+			if err != nil {
+				return moved, err
+			}
+			moved++
+		}
+
+		_, err := s.users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{"orders": ""}})
+		if err != nil {
+			return moved, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return moved, err
+	}
+
+	_, err = s.migrations.InsertOne(ctx, bson.M{"_id": embeddedOrdersMigration, "applied_at": time.Now(), "orders_moved": moved})
+	if err != nil {
+		return moved, err
+	}
+
+	log.Printf("moved %d embedded orders into the orders collection", moved)
+	return moved, nil
+}
+
+// MigrateMoneyAmounts turns the bare numbers older releases stored for prices
+// and discounts into models.Money in models.BaseCurrency, and splits the
+// single coupon value into a percent or an amount. It must run before
+// MigrateEmbeddedOrders, which decodes the orders embedded in users. Every
+// step only matches documents still in the old shape, so an interrupted run
+// can simply be repeated.
+func (s *MongoStore) MigrateMoneyAmounts(ctx context.Context) error {
+	done, err := s.migrations.CountDocuments(ctx, bson.M{"_id": moneyAmountsMigration})
+	if err != nil {
+		return err
+	}
+	if done > 0 {
+		return nil
+	}
+
+	lines := func(field string) bson.M {
+		return bson.M{"$cond": bson.A{
+			bson.M{"$isArray": field},
+			bson.M{"$map": bson.M{"input": field, "as": "line", "in": bson.M{
+				"$mergeObjects": bson.A{"$$line", bson.M{"price": legacyMoney("$$line.price")}},
+			}}},
+			"$$REMOVE",
+		}}
+	}
+	orders := func(field string) bson.M {
+		return bson.M{"$cond": bson.A{
+			bson.M{"$isArray": field},
+			bson.M{"$map": bson.M{"input": field, "as": "order", "in": bson.M{
+				"$mergeObjects": bson.A{"$$order", bson.M{
+					"price":      legacyMoney("$$order.price"),
+					"discount":   legacyMoney("$$order.discount"),
+					"order_list": lines("$$order.order_list"),
+				}},
+			}}},
+			"$$REMOVE",
+		}}
+	}
+
+	steps := []struct {
+		collection *mongo.Collection
+		filter     bson.M
+		update     bson.A
+	}{
+		{s.products, bson.M{"price": bson.M{"$type": "number"}}, bson.A{bson.M{"$set": bson.M{
+			"price":    legacyMoney("$price"),
+			"variants": lines("$variants"),
+		}}}},
+		{s.users, bson.M{"$or": bson.A{
+			bson.M{"user_cart.price": bson.M{"$type": "number"}},
+			bson.M{"orders.price": bson.M{"$type": "number"}},
+		}}, bson.A{bson.M{"$set": bson.M{
+			"user_cart": lines("$user_cart"),
+			"orders":    orders("$orders"),
+		}}}},
+		{s.orders, bson.M{"price": bson.M{"$type": "number"}}, bson.A{bson.M{"$set": bson.M{
+			"price":      legacyMoney("$price"),
+			"discount":   legacyMoney("$discount"),
+			"order_list": lines("$order_list"),
+		}}}},
+		{s.coupons, bson.M{"value": bson.M{"$exists": true}}, bson.A{
+			bson.M{"$set": bson.M{
+				"percent":        bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$kind", models.CouponPercent}}, "$value", "$$REMOVE"}},
+				"amount":         bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$kind", models.CouponFixed}}, legacyMoney("$value"), "$$REMOVE"}},
+				"min_cart_value": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$min_cart_value", 0}}, legacyMoney("$min_cart_value"), "$$REMOVE"}},
+			}},
+			bson.M{"$unset": "value"},
+		}},
+		{s.promotions, bson.M{"bundle_price": bson.M{"$type": "number"}}, bson.A{bson.M{"$set": bson.M{
+			"bundle_price": legacyMoney("$bundle_price"),
+		}}}},
+	}
+
+	migrated := int64(0)
+	for _, step := range steps {
+		result, err := step.collection.UpdateMany(ctx, step.filter, step.update)
+		if err != nil {
+			return err
+		}
+		migrated += result.ModifiedCount
+	}
+
+	_, err = s.migrations.InsertOne(ctx, bson.M{"_id": moneyAmountsMigration, "applied_at": time.Now(), "currency": models.BaseCurrency, "documents": migrated})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("converted prices in %d documents to %s amounts", migrated, models.BaseCurrency)
+	return nil
+}
+
+// legacyMoney is an aggregation expression turning the number at field into
+// a models.Money in models.BaseCurrency. Legacy values are in major units,
+// whole rupees, and are scaled to minor units. Values that are not numbers
+// are left as they are and missing ones stay missing.
+func legacyMoney(field string) bson.M {
+	minor := bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{field, majorUnit()}}, 0}}
+	return bson.M{"$cond": bson.A{
+		bson.M{"$isNumber": field},
+		bson.M{"amount": bson.M{"$toLong": minor}, "currency": models.BaseCurrency},
+		field,
+	}}
+}
+
+// MigrateLegacyStock gives products saved before stock was tracked, which
+// have no stock field at all, initial units of stock. DecrementStock only
+// takes stock a product has, so without this they could never be sold. Only
+// products still missing the field are touched, so it is cheap to run on
+// every start, and the counts can be corrected later with stock adjustments.
+func (s *MongoStore) MigrateLegacyStock(ctx context.Context, initial uint64) (int64, error) {
+	result, err := s.products.UpdateMany(ctx, bson.M{"stock": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"stock": initial}})
+	if err != nil {
+		return 0, err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("set the stock of %d products that had none to %d", result.ModifiedCount, initial)
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,1265 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is the MongoDB backed Store.
+type MongoStore struct {
+	users            *mongo.Collection
+	products         *mongo.Collection
+	orders           *mongo.Collection
+	reservations     *mongo.Collection
+	stockAdjustments *mongo.Collection
+	migrations       *mongo.Collection
+	audit            *mongo.Collection
+	synonyms         *mongo.Collection
+	categories       *mongo.Collection
+	coupons          *mongo.Collection
+	couponUses       *mongo.Collection
+	promotions       *mongo.Collection
+	exchangeRates    *mongo.Collection
+	taxRules         *mongo.Collection
+	paymentEvents    *mongo.Collection
+	ledger           *mongo.Collection
+	returns          *mongo.Collection
+}
+
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	return &MongoStore{
+		users:            UserData(client, "users"),
+		products:         ProductData(client, "products"),
+		orders:           collection(client, "orders"),
+		reservations:     collection(client, "reservations"),
+		stockAdjustments: collection(client, "stock_adjustments"),
+		migrations:       collection(client, "migrations"),
+		audit:            collection(client, "audit"),
+		synonyms:         collection(client, "synonyms"),
+		categories:       collection(client, "categories"),
+		coupons:          collection(client, "coupons"),
+		couponUses:       collection(client, "coupon_uses"),
+		promotions:       collection(client, "promotions"),
+		exchangeRates:    collection(client, "exchange_rates"),
+		taxRules:         collection(client, "tax_rules"),
+		paymentEvents:    collection(client, "payment_events"),
+		ledger:           collection(client, "refund_ledger"),
+		returns:          collection(client, "returns"),
+	}
+}
+
+func collection(client *mongo.Client, collectionName string) *mongo.Collection {
+	return client.Database("ecommerce").Collection(collectionName)
+}
+
+// EnsureIndexes creates the indexes the queries in this file rely on. It is
+// safe to call on every start.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.orders.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "order_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "order_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.products.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "price.amount", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "rating", Value: -1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "categories", Value: 1}, {Key: "price.amount", Value: 1}}},
+		{Keys: bson.D{{Key: "brand", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "variants.sku", Value: 1}},
+			Options: options.Index().SetName("variant_sku").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{
+				{Key: "product_name", Value: "text"},
+				{Key: "tags", Value: "text"},
+				{Key: "description", Value: "text"},
+			},
+			Options: options.Index().SetName("product_text").SetWeights(searchWeights),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.categories.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.coupons.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.returns.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "order_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	return err
+}
+
+func (s *MongoStore) FindUserByID(ctx context.Context, userID primitive.ObjectID) (models.User, error) {
+	return s.findUser(ctx, bson.M{"_id": userID})
+}
+
+func (s *MongoStore) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return s.findUser(ctx, bson.M{"email": email})
+}
+
+func (s *MongoStore) findUser(ctx context.Context, filter bson.M) (models.User, error) {
+	var user models.User
+	err := s.users.FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *MongoStore) CountUsersByEmail(ctx context.Context, email string) (int64, error) {
+	return s.users.CountDocuments(ctx, bson.M{"email": email})
+}
+
+func (s *MongoStore) CountUsersByPhone(ctx context.Context, phone string) (int64, error) {
+	return s.users.CountDocuments(ctx, bson.M{"phone": phone})
+}
+
+func (s *MongoStore) InsertUser(ctx context.Context, user models.User) error {
+	_, err := s.users.InsertOne(ctx, user)
+	return err
+}
+
+func (s *MongoStore) UpdateRefreshToken(ctx context.Context, userID primitive.ObjectID, refreshToken string, family string) error {
+	return s.updateUser(ctx, userID, bson.M{"$set": bson.M{"refresh_token": refreshToken, "token_family": family, "updatedat": time.Now()}})
+}
+
+func (s *MongoStore) RotateRefreshToken(ctx context.Context, userID primitive.ObjectID, oldToken string, newToken string) (bool, error) {
+	result, err := s.users.UpdateOne(ctx,
+		bson.M{"_id": userID, "refresh_token": oldToken},
+		bson.M{"$set": bson.M{"refresh_token": newToken, "updatedat": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (s *MongoStore) RevokeRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	return s.updateUser(ctx, userID, bson.M{"$set": bson.M{"refresh_token": "", "token_family": "", "updatedat": time.Now()}})
+}
+
+func (s *MongoStore) UpdateUserRole(ctx context.Context, userID primitive.ObjectID, role string) error {
+	return s.updateUser(ctx, userID, bson.M{"$set": bson.M{"role": role, "updatedat": time.Now()}})
+}
+
+func (s *MongoStore) AddCartItem(ctx context.Context, userID primitive.ObjectID, item models.ProductUser) error {
+	// Two concurrent adds of a new product can both miss the $inc; the guarded
+	// $push lets only one of them append, so the other simply retries.
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := s.users.UpdateOne(ctx,
+			bson.M{"_id": userID, "user_cart": bson.M{"$elemMatch": cartLine(item.ProductID, item.VariantSKU)}},
+			bson.M{"$inc": bson.M{"user_cart.$.quantity": item.Quantity}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+
+		result, err = s.users.UpdateOne(ctx,
+			bson.M{"_id": userID, "user_cart": bson.M{"$not": bson.M{"$elemMatch": cartLine(item.ProductID, item.VariantSKU)}}},
+			bson.M{"$push": bson.M{"user_cart": item}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+	}
+
+	count, err := s.users.CountDocuments(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+	return ErrCantUpdateUser
+}
+
+// cartLine matches the cart line of a product variant. Lines of products
+// without variants carry no variant_sku at all.
+func cartLine(productID primitive.ObjectID, sku string) bson.M {
+	if sku == "" {
+		return bson.M{"_id": productID, "variant_sku": bson.M{"$exists": false}}
+	}
+	return bson.M{"_id": productID, "variant_sku": sku}
+}
+
+func (s *MongoStore) SetCartItemQuantity(ctx context.Context, userID primitive.ObjectID, productID primitive.ObjectID, sku string, quantity uint64) error {
+	result, err := s.users.UpdateOne(ctx,
+		bson.M{"_id": userID, "user_cart": bson.M{"$elemMatch": cartLine(productID, sku)}},
+		bson.M{"$set": bson.M{"user_cart.$.quantity": quantity}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) PullCartItem(ctx context.Context, userID primitive.ObjectID, productID primitive.ObjectID, sku string) error {
+	return s.updateUser(ctx, userID, bson.M{"$pull": bson.M{"user_cart": cartLine(productID, sku)}})
+}
+
+func (s *MongoStore) SetCartCoupon(ctx context.Context, userID primitive.ObjectID, code string) error {
+	if code == "" {
+		return s.updateUser(ctx, userID, bson.M{"$unset": bson.M{"coupon_code": ""}})
+	}
+	return s.updateUser(ctx, userID, bson.M{"$set": bson.M{"coupon_code": code}})
+}
+
+func (s *MongoStore) ClearCart(ctx context.Context, userID primitive.ObjectID) error {
+	return s.updateUser(ctx, userID, bson.M{
+		"$set":   bson.M{"user_cart": []models.ProductUser{}},
+		"$unset": bson.M{"coupon_code": ""},
+	})
+}
+
+func (s *MongoStore) AddAddress(ctx context.Context, userID primitive.ObjectID, address models.Address) error {
+	return s.updateUser(ctx, userID, bson.M{"$push": bson.M{"address_details": address}})
+}
+
+func (s *MongoStore) UpdateAddress(ctx context.Context, userID primitive.ObjectID, index int, address models.Address) error {
+	prefix := fmt.Sprintf("address_details.%d.", index)
+	filter := bson.M{"_id": userID, fmt.Sprintf("address_details.%d", index): bson.M{"$exists": true}}
+	update := bson.M{"$set": bson.M{
+		prefix + "house":    address.House,
+		prefix + "street":   address.Street,
+		prefix + "city":     address.City,
+		prefix + "pin_code": address.Pincode,
+		prefix + "state":    address.State,
+	}}
+
+	result, err := s.users.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) ClearAddresses(ctx context.Context, userID primitive.ObjectID) error {
+	return s.updateUser(ctx, userID, bson.M{"$set": bson.M{"address_details": []models.Address{}}})
+}
+
+func (s *MongoStore) updateUser(ctx context.Context, userID primitive.ObjectID, update bson.M) error {
+	result, err := s.users.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) FindProductByID(ctx context.Context, productID primitive.ObjectID) (models.Product, error) {
+	var product models.Product
+	err := s.products.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return product, ErrCantFindProduct
+	}
+	return product, err
+}
+
+func (s *MongoStore) InsertProduct(ctx context.Context, product models.Product) (primitive.ObjectID, error) {
+	if product.ProductID.IsZero() {
+		product.ProductID = primitive.NewObjectID()
+	}
+
+	_, err := s.products.InsertOne(ctx, product)
+	if mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), "variant_sku") {
+		return product.ProductID, ErrSKUExists
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return product.ProductID, ErrProductExists
+	}
+	return product.ProductID, err
+}
+
+func (s *MongoStore) FindProducts(ctx context.Context, query ProductQuery) ([]models.Product, int64, error) {
+	filter := productFilter(query)
+	total, err := s.products.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort := productSort(query.Sort)
+	if query.Text != "" && (query.Sort == "" || query.Sort == SortRelevance) {
+		sort = bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}}
+	}
+	opts := options.Find().SetSort(sort).SetSkip(query.Skip)
+	if query.Limit > 0 {
+		opts.SetLimit(query.Limit)
+	}
+	products, err := s.findProducts(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+func productFilter(query ProductQuery) bson.M {
+	filter := bson.M{}
+	if !query.IncludeDeleted {
+		filter["deleted"] = bson.M{"$ne": true}
+	}
+	if query.Text != "" {
+		filter["$text"] = bson.M{"$search": query.Text}
+	}
+	price := bson.M{}
+	if query.MinPrice > 0 {
+		price["$gte"] = query.MinPrice
+	}
+	if query.MaxPrice > 0 {
+		price["$lte"] = query.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["price.amount"] = price
+	}
+	if query.MinRating > 0 {
+		filter["rating"] = bson.M{"$gte": query.MinRating}
+	}
+	if len(query.Categories) > 0 {
+		filter["categories"] = bson.M{"$in": query.Categories}
+	}
+	if query.Brand != "" {
+		filter["brand"] = query.Brand
+	}
+	for name, value := range query.Attributes {
+		filter["attributes."+name] = value
+	}
+	return filter
+}
+
+func (s *MongoStore) ProductFacets(ctx context.Context, query ProductQuery) (Facets, error) {
+	countBy := func(field string, sort bson.D) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{field: bson.M{"$nin": bson.A{nil, ""}}}},
+			bson.M{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": sort},
+		}
+	}
+	byCount := bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}
+	boundaries := bson.A{}
+	for _, bound := range PriceBuckets {
+		boundaries = append(boundaries, int64(bound)*majorUnit())
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: productFilter(query)}},
+		{{Key: "$facet", Value: bson.M{
+			"categories": append(bson.A{bson.M{"$unwind": "$categories"}}, countBy("categories", byCount)...),
+			"brands":     countBy("brand", byCount),
+			"ratings":    countBy("rating", bson.D{{Key: "_id", Value: 1}}),
+			"prices": bson.A{bson.M{"$bucket": bson.M{
+				"groupBy":    "$price.amount",
+				"boundaries": boundaries,
+				"default":    "open",
+			}}},
+		}}},
+	}
+	cursor, err := s.products.Aggregate(ctx, pipeline)
+	if err != nil {
+		return Facets{}, err
+	}
+	defer cursor.Close(ctx)
+
+	type bucket struct {
+		ID    interface{} `bson:"_id"`
+		Count int64       `bson:"count"`
+	}
+	var results []struct {
+		Categories []bucket `bson:"categories"`
+		Brands     []bucket `bson:"brands"`
+		Ratings    []bucket `bson:"ratings"`
+		Prices     []bucket `bson:"prices"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return Facets{}, err
+	}
+
+	facets := Facets{Categories: []FacetCount{}, Brands: []FacetCount{}, Prices: []FacetCount{}, Ratings: []FacetCount{}}
+	if len(results) == 0 {
+		return facets, nil
+	}
+	for _, b := range results[0].Categories {
+		facets.Categories = append(facets.Categories, FacetCount{Value: fmt.Sprint(b.ID), Count: b.Count})
+	}
+	for _, b := range results[0].Brands {
+		facets.Brands = append(facets.Brands, FacetCount{Value: fmt.Sprint(b.ID), Count: b.Count})
+	}
+	for _, b := range results[0].Ratings {
+		facets.Ratings = append(facets.Ratings, FacetCount{Value: fmt.Sprint(b.ID), Count: b.Count})
+	}
+	for _, b := range results[0].Prices {
+		// $bucket names each bucket by its lower bound in minor units; prices
+		// above the last boundary land in the default bucket, which is the
+		// open-ended one.
+		lower := PriceBuckets[len(PriceBuckets)-1]
+		switch bound := b.ID.(type) {
+		case int32:
+			lower = uint64(int64(bound) / majorUnit())
+		case int64:
+			lower = uint64(bound / majorUnit())
+		}
+		facets.Prices = append(facets.Prices, FacetCount{Value: priceBucketLabel(lower), Count: b.Count})
+	}
+	return facets, nil
+}
+
+func (s *MongoStore) CatalogText(ctx context.Context) ([]string, error) {
+	live := bson.M{"deleted": bson.M{"$ne": true}}
+	text := []string{}
+	for _, field := range []string{"product_name", "tags"} {
+		values, err := s.products.Distinct(ctx, field, live)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			if value, ok := value.(string); ok {
+				text = append(text, value)
+			}
+		}
+	}
+	return text, nil
+}
+
+func (s *MongoStore) SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error) {
+	filter := bson.M{
+		"deleted":      bson.M{"$ne": true},
+		"product_name": bson.M{"$regex": suggestionPattern(prefix), "$options": "i"},
+	}
+	// Fetch a few extra so that repeated names still leave limit suggestions.
+	opts := options.Find().
+		SetSort(bson.D{{Key: "rating", Value: -1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit * 2).
+		SetProjection(bson.M{"product_name": 1})
+	products, err := s.findProducts(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return suggestions(products, limit), nil
+}
+
+// productSort always ends on _id so that pages stay stable when the sort key
+// has ties. ObjectIDs start with their creation time, which makes _id the
+// natural key for "newest" as well.
+func productSort(sort string) bson.D {
+	switch sort {
+	case SortPriceAsc:
+		return bson.D{{Key: "price.amount", Value: 1}, {Key: "_id", Value: 1}}
+	case SortPriceDesc:
+		return bson.D{{Key: "price.amount", Value: -1}, {Key: "_id", Value: 1}}
+	case SortRating:
+		return bson.D{{Key: "rating", Value: -1}, {Key: "_id", Value: 1}}
+	case SortNewest:
+		return bson.D{{Key: "_id", Value: -1}}
+	}
+	return bson.D{{Key: "_id", Value: 1}}
+}
+
+func (s *MongoStore) UpdateProduct(ctx context.Context, productID primitive.ObjectID, update models.ProductUpdate) (models.Product, error) {
+	set := bson.M{"updated_at": time.Now()}
+	if update.ProductName != nil {
+		set["product_name"] = *update.ProductName
+	}
+	if update.Price != nil {
+		set["price"] = *update.Price
+	}
+	if update.Rating != nil {
+		set["rating"] = *update.Rating
+	}
+	if update.Image != nil {
+		set["image"] = *update.Image
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Tags != nil {
+		set["tags"] = NormalizeTags(*update.Tags)
+	}
+	if update.Categories != nil {
+		set["categories"] = *update.Categories
+	}
+	if update.Brand != nil {
+		set["brand"] = *update.Brand
+	}
+	if update.Attributes != nil {
+		set["attributes"] = *update.Attributes
+	}
+	if update.TaxClass != nil {
+		set["tax_class"] = *update.TaxClass
+	}
+
+	var product models.Product
+	err := s.products.FindOneAndUpdate(ctx,
+		bson.M{"_id": productID, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&product)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return product, ErrCantFindProduct
+	}
+	return product, err
+}
+
+func (s *MongoStore) SoftDeleteProduct(ctx context.Context, productID primitive.ObjectID) error {
+	now := time.Now()
+	result, err := s.products.UpdateOne(ctx,
+		bson.M{"_id": productID, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"deleted": true, "deleted_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindProduct
+	}
+	return nil
+}
+
+func (s *MongoStore) DecrementStock(ctx context.Context, productID primitive.ObjectID, sku string, quantity uint64) error {
+	filter := bson.M{"_id": productID, "stock": bson.M{"$gte": quantity}}
+	update := bson.M{"$inc": bson.M{"stock": -int64(quantity)}}
+	if sku != "" {
+		filter = bson.M{"_id": productID, "variants": bson.M{"$elemMatch": bson.M{"sku": sku, "stock": bson.M{"$gte": quantity}}}}
+		update = bson.M{"$inc": bson.M{"variants.$.stock": -int64(quantity)}}
+	}
+
+	result, err := s.products.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (s *MongoStore) IncrementStock(ctx context.Context, productID primitive.ObjectID, sku string, quantity uint64) error {
+	filter := bson.M{"_id": productID}
+	update := bson.M{"$inc": bson.M{"stock": int64(quantity)}}
+	if sku != "" {
+		filter["variants.sku"] = sku
+		update = bson.M{"$inc": bson.M{"variants.$.stock": int64(quantity)}}
+	}
+
+	result, err := s.products.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 && sku != "" {
+		return ErrVariantNotFound
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindProduct
+	}
+	return nil
+}
+
+func (s *MongoStore) SaveVariant(ctx context.Context, productID primitive.ObjectID, variant models.Variant) (models.Product, error) {
+	live := bson.M{"$ne": true}
+	// As in AddCartItem, a concurrent add of the same SKU can slip between
+	// the $set and the guarded $push, so the pair is tried twice.
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := s.products.UpdateOne(ctx,
+			bson.M{"_id": productID, "deleted": live, "variants.sku": variant.SKU},
+			bson.M{"$set": bson.M{
+				"variants.$.options": variant.Options,
+				"variants.$.price":   variant.Price,
+				"variants.$.image":   variant.Image,
+				"updated_at":         time.Now(),
+			}},
+		)
+		if err != nil {
+			return models.Product{}, err
+		}
+		if result.MatchedCount > 0 {
+			return s.FindProductByID(ctx, productID)
+		}
+
+		result, err = s.products.UpdateOne(ctx,
+			bson.M{"_id": productID, "deleted": live, "variants.sku": bson.M{"$ne": variant.SKU}},
+			bson.M{"$push": bson.M{"variants": variant}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			return models.Product{}, ErrSKUExists
+		}
+		if err != nil {
+			return models.Product{}, err
+		}
+		if result.MatchedCount > 0 {
+			return s.FindProductByID(ctx, productID)
+		}
+
+		product, err := s.FindProductByID(ctx, productID)
+		if err != nil {
+			return models.Product{}, err
+		}
+		if product.Deleted {
+			return models.Product{}, ErrCantFindProduct
+		}
+	}
+	return models.Product{}, ErrSKUExists
+}
+
+func (s *MongoStore) RemoveVariant(ctx context.Context, productID primitive.ObjectID, sku string) error {
+	result, err := s.products.UpdateOne(ctx,
+		bson.M{"_id": productID, "variants.sku": sku},
+		bson.M{"$pull": bson.M{"variants": bson.M{"sku": sku}}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrVariantNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) findProducts(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Product, error) {
+	cursor, err := s.products.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []models.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (s *MongoStore) InsertOrder(ctx context.Context, order models.Order) error {
+	_, err := s.orders.InsertOne(ctx, order)
+	return err
+}
+
+func (s *MongoStore) FindOrderByID(ctx context.Context, orderID primitive.ObjectID) (models.Order, error) {
+	var order models.Order
+	err := s.orders.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return order, ErrOrderNotFound
+	}
+	return order, err
+}
+
+func (s *MongoStore) ListOrdersByUser(ctx context.Context, userID primitive.ObjectID, filter OrderFilter) ([]models.Order, int64, error) {
+	query := bson.M{"user_id": userID}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	orderedAt := bson.M{}
+	if !filter.From.IsZero() {
+		orderedAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		orderedAt["$lt"] = filter.To
+	}
+	if len(orderedAt) > 0 {
+		query["order_at"] = orderedAt
+	}
+
+	total, err := s.orders.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "order_at", Value: -1}}).SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	cursor, err := s.orders.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (s *MongoStore) FindUnpaidOrders(ctx context.Context, placedBefore time.Time) ([]models.Order, error) {
+	cursor, err := s.orders.Find(ctx, bson.M{
+		"status":                 models.OrderPending,
+		"order_at":               bson.M{"$lt": placedBefore},
+		"payment_method.digital": true,
+		"payment_method.status":  bson.M{"$in": bson.A{"", models.PaymentFailed, models.PaymentVoided}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (s *MongoStore) UpdateOrderStatus(ctx context.Context, orderID primitive.ObjectID, change models.StatusChange) error {
+	result, err := s.orders.UpdateOne(ctx,
+		bson.M{"_id": orderID, "status": change.From},
+		bson.M{
+			"$set":  bson.M{"status": change.To},
+			"$push": bson.M{"status_history": change},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrOrderStatusChanged
+	}
+	return nil
+}
+
+func (s *MongoStore) UpdateOrderPayment(ctx context.Context, orderID primitive.ObjectID, from models.PaymentStatus, payment models.Payment) error {
+	result, err := s.orders.UpdateOne(ctx,
+		bson.M{"_id": orderID, "payment_method.status": from},
+		bson.M{"$set": bson.M{"payment_method": payment}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrPaymentChanged
+	}
+	return nil
+}
+
+func (s *MongoStore) AddOrderRefund(ctx context.Context, orderID primitive.ObjectID, known int, refund models.Refund) error {
+	filter := bson.M{"_id": orderID, fmt.Sprintf("refunds.%d", known): bson.M{"$exists": false}}
+	if known > 0 {
+		filter[fmt.Sprintf("refunds.%d", known-1)] = bson.M{"$exists": true}
+	}
+	result, err := s.orders.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"refunds": refund}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrRefundConflict
+	}
+	return nil
+}
+
+func (s *MongoStore) UpdateOrderRefund(ctx context.Context, orderID primitive.ObjectID, refund models.Refund) error {
+	result, err := s.orders.UpdateOne(ctx,
+		bson.M{"_id": orderID, "refunds._id": refund.ID},
+		bson.M{"$set": bson.M{"refunds.$": refund}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertReservation(ctx context.Context, reservation models.Reservation) error {
+	_, err := s.reservations.InsertOne(ctx, reservation)
+	return err
+}
+
+func (s *MongoStore) FindReservationByUser(ctx context.Context, userID primitive.ObjectID) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := s.reservations.FindOne(ctx, bson.M{"user_id": userID}).Decode(&reservation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return reservation, ErrReservationNotFound
+	}
+	return reservation, err
+}
+
+func (s *MongoStore) FindExpiredReservations(ctx context.Context, now time.Time) ([]models.Reservation, error) {
+	cursor, err := s.reservations.Find(ctx, bson.M{"expires_at": bson.M{"$lte": now}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reservations := []models.Reservation{}
+	if err := cursor.All(ctx, &reservations); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+func (s *MongoStore) DeleteReservation(ctx context.Context, reservationID primitive.ObjectID) (bool, error) {
+	result, err := s.reservations.DeleteOne(ctx, bson.M{"_id": reservationID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s *MongoStore) InsertStockAdjustment(ctx context.Context, adjustment models.StockAdjustment) error {
+	_, err := s.stockAdjustments.InsertOne(ctx, adjustment)
+	return err
+}
+
+func (s *MongoStore) InsertAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	_, err := s.audit.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoStore) ListSynonymGroups(ctx context.Context) ([]models.SynonymGroup, error) {
+	cursor, err := s.synonyms.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []models.SynonymGroup{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (s *MongoStore) SaveSynonymGroup(ctx context.Context, group models.SynonymGroup) error {
+	_, err := s.synonyms.ReplaceOne(ctx, bson.M{"_id": group.ID}, group, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) DeleteSynonymGroup(ctx context.Context, groupID primitive.ObjectID) error {
+	result, err := s.synonyms.DeleteOne(ctx, bson.M{"_id": groupID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSynonymNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) ListCategories(ctx context.Context) ([]models.Category, error) {
+	cursor, err := s.categories.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []models.Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (s *MongoStore) InsertCategory(ctx context.Context, category models.Category) error {
+	_, err := s.categories.InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCategoryExists
+	}
+	return err
+}
+
+func (s *MongoStore) UpdateCategory(ctx context.Context, slug string, update models.CategoryUpdate) (models.Category, error) {
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Parent != nil && *update.Parent != "" {
+		set["parent"] = *update.Parent
+	} else if update.Parent != nil {
+		unset["parent"] = ""
+	}
+	if update.DisplayOrder != nil {
+		set["display_order"] = *update.DisplayOrder
+	}
+	change := bson.M{"$set": set}
+	if len(unset) > 0 {
+		change["$unset"] = unset
+	}
+
+	var category models.Category
+	err := s.categories.FindOneAndUpdate(ctx, bson.M{"slug": slug}, change,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&category)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return category, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+func (s *MongoStore) DeleteCategory(ctx context.Context, slug string) error {
+	result, err := s.categories.DeleteOne(ctx, bson.M{"slug": slug})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertCoupon(ctx context.Context, coupon models.Coupon) error {
+	_, err := s.coupons.InsertOne(ctx, coupon)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCouponExists
+	}
+	return err
+}
+
+func (s *MongoStore) FindCouponByCode(ctx context.Context, code string) (models.Coupon, error) {
+	var coupon models.Coupon
+	err := s.coupons.FindOne(ctx, bson.M{"code": code}).Decode(&coupon)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return coupon, ErrCouponNotFound
+	}
+	return coupon, err
+}
+
+func (s *MongoStore) ListCoupons(ctx context.Context) ([]models.Coupon, error) {
+	cursor, err := s.coupons.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	coupons := []models.Coupon{}
+	if err := cursor.All(ctx, &coupons); err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+func (s *MongoStore) DeleteCoupon(ctx context.Context, code string) error {
+	result, err := s.coupons.DeleteOne(ctx, bson.M{"code": code})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCouponNotFound
+	}
+	return nil
+}
+
+// couponUseID keys the per-user usage counter of a coupon.
+func couponUseID(code string, userID primitive.ObjectID) string {
+	return code + ":" + userID.Hex()
+}
+
+func (s *MongoStore) CountCouponUses(ctx context.Context, code string, userID primitive.ObjectID) (uint64, error) {
+	var use struct {
+		Count uint64 `bson:"count"`
+	}
+	err := s.couponUses.FindOne(ctx, bson.M{"_id": couponUseID(code, userID)}).Decode(&use)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return use.Count, err
+}
+
+func (s *MongoStore) RedeemCoupon(ctx context.Context, coupon models.Coupon, userID primitive.ObjectID) error {
+	filter := bson.M{"code": coupon.Code}
+	if coupon.UsageLimit > 0 {
+		filter["used"] = bson.M{"$lt": coupon.UsageLimit}
+	}
+	result, err := s.coupons.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"used": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCouponExhausted
+	}
+
+	// When the user is at the limit the filter misses the existing counter,
+	// and the upsert then collides with it on _id instead of adding another.
+	useFilter := bson.M{"_id": couponUseID(coupon.Code, userID)}
+	if coupon.PerUserLimit > 0 {
+		useFilter["count"] = bson.M{"$lt": coupon.PerUserLimit}
+	}
+	_, err = s.couponUses.UpdateOne(ctx, useFilter,
+		bson.M{"$inc": bson.M{"count": 1}, "$setOnInsert": bson.M{"code": coupon.Code, "user_id": userID}},
+		options.Update().SetUpsert(true),
+	)
+	if err == nil {
+		return nil
+	}
+
+	if _, undoErr := s.coupons.UpdateOne(ctx, bson.M{"code": coupon.Code}, bson.M{"$inc": bson.M{"used": -1}}); undoErr != nil {
+		log.Println(undoErr)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCouponUsedUp
+	}
+	return err
+}
+
+func (s *MongoStore) ReleaseCoupon(ctx context.Context, code string, userID primitive.ObjectID) error {
+	_, err := s.coupons.UpdateOne(ctx,
+		bson.M{"code": code, "used": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"used": -1}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.couponUses.UpdateOne(ctx,
+		bson.M{"_id": couponUseID(code, userID), "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}},
+	)
+	return err
+}
+
+func (s *MongoStore) InsertPromotion(ctx context.Context, promotion models.Promotion) error {
+	_, err := s.promotions.InsertOne(ctx, promotion)
+	return err
+}
+
+func (s *MongoStore) ListPromotions(ctx context.Context) ([]models.Promotion, error) {
+	cursor, err := s.promotions.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	promotions := []models.Promotion{}
+	if err := cursor.All(ctx, &promotions); err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (s *MongoStore) DeletePromotion(ctx context.Context, promotionID primitive.ObjectID) error {
+	result, err := s.promotions.DeleteOne(ctx, bson.M{"_id": promotionID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) FindExchangeRate(ctx context.Context, currency string) (models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := s.exchangeRates.FindOne(ctx, bson.M{"_id": currency}).Decode(&rate)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return rate, ErrRateNotFound
+	}
+	return rate, err
+}
+
+func (s *MongoStore) ListExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	cursor, err := s.exchangeRates.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rates := []models.ExchangeRate{}
+	if err := cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (s *MongoStore) SaveExchangeRate(ctx context.Context, rate models.ExchangeRate) error {
+	_, err := s.exchangeRates.ReplaceOne(ctx, bson.M{"_id": rate.Currency}, rate, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) DeleteExchangeRate(ctx context.Context, currency string) error {
+	result, err := s.exchangeRates.DeleteOne(ctx, bson.M{"_id": currency})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrRateNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertTaxRule(ctx context.Context, rule models.TaxRule) error {
+	_, err := s.taxRules.InsertOne(ctx, rule)
+	return err
+}
+
+func (s *MongoStore) ListTaxRules(ctx context.Context) ([]models.TaxRule, error) {
+	cursor, err := s.taxRules.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := []models.TaxRule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *MongoStore) DeleteTaxRule(ctx context.Context, ruleID primitive.ObjectID) error {
+	result, err := s.taxRules.DeleteOne(ctx, bson.M{"_id": ruleID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrTaxRuleNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertPaymentEvent(ctx context.Context, event models.PaymentEvent) error {
+	_, err := s.paymentEvents.InsertOne(ctx, event)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrEventExists
+	}
+	return err
+}
+
+func (s *MongoStore) FindPaymentEvent(ctx context.Context, id string) (models.PaymentEvent, error) {
+	var event models.PaymentEvent
+	err := s.paymentEvents.FindOne(ctx, bson.M{"_id": id}).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return event, ErrEventNotFound
+	}
+	return event, err
+}
+
+func (s *MongoStore) ListPaymentEvents(ctx context.Context, limit int64) ([]models.PaymentEvent, error) {
+	cursor, err := s.paymentEvents.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"received_at": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.PaymentEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *MongoStore) UpdatePaymentEvent(ctx context.Context, event models.PaymentEvent) error {
+	result, err := s.paymentEvents.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$set": bson.M{
+		"order_id":     event.OrderID,
+		"outcome":      event.Outcome,
+		"error":        event.Error,
+		"processed_at": event.ProcessedAt,
+		"replays":      event.Replays,
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+func (s *MongoStore) InsertLedgerEntry(ctx context.Context, entry models.LedgerEntry) error {
+	_, err := s.ledger.InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoStore) ListLedgerEntries(ctx context.Context, limit int64) ([]models.LedgerEntry, error) {
+	cursor, err := s.ledger.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.LedgerEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *MongoStore) InsertReturn(ctx context.Context, ret models.Return) error {
+	_, err := s.returns.InsertOne(ctx, ret)
+	return err
+}
+
+func (s *MongoStore) FindReturnByID(ctx context.Context, returnID primitive.ObjectID) (models.Return, error) {
+	var ret models.Return
+	err := s.returns.FindOne(ctx, bson.M{"_id": returnID}).Decode(&ret)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ret, ErrReturnNotFound
+	}
+	return ret, err
+}
+
+func (s *MongoStore) ListReturnsByOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Return, error) {
+	return s.findReturns(ctx, bson.M{"order_id": orderID}, options.Find().SetSort(bson.M{"_id": 1}))
+}
+
+func (s *MongoStore) ListReturns(ctx context.Context, status models.ReturnStatus, limit int64) ([]models.Return, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	return s.findReturns(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}).SetLimit(limit))
+}
+
+func (s *MongoStore) findReturns(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Return, error) {
+	cursor, err := s.returns.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	returns := []models.Return{}
+	if err := cursor.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+func (s *MongoStore) UpdateReturn(ctx context.Context, from models.ReturnStatus, ret models.Return) error {
+	result, err := s.returns.ReplaceOne(ctx, bson.M{"_id": ret.ID, "status": from}, ret)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrReturnChanged
+	}
+	return nil
+}
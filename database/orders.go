@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidOrderStatus = errors.New("unknown order status")
+	ErrIllegalTransition  = errors.New("order cannot move to that status")
+	ErrNotCancellable     = errors.New("an order can only be cancelled before it ships")
+	ErrUseCancelOrder     = errors.New("cancelling an order also restocks and refunds it, so it has to go through order cancellation")
+)
+
+func newOrder(userID primitive.ObjectID, cart []models.ProductUser, price models.Money, address models.Address, payment models.Payment) models.Order {
+	now := time.Now()
+	return models.Order{
+		OrderID:         primitive.NewObjectID(),
+		UserID:          userID,
+		OrderCart:       cart,
+		OrderedAt:       now,
+		Price:           price,
+		Discount:        models.NewMoney(0, price.Currency),
+		Tax:             models.NewMoney(0, price.Currency),
+		TaxInclusive:    PricesIncludeTax,
+		PaymentMethod:   payment,
+		ShippingAddress: address,
+		Status:          models.OrderPending,
+		StatusHistory:   []models.StatusChange{{To: models.OrderPending, At: now}},
+	}
+}
+
+// UpdateOrderStatus moves an order to next if the lifecycle allows it and
+// appends the change to the order's status history. Orders paid digitally
+// are only packed once the payment has been captured. Cancelling has to give
+// back stock, coupon and payment, so it is refused here; see CancelOrder.
+func UpdateOrderStatus(ctx context.Context, orders OrderStore, orderID primitive.ObjectID, next models.OrderStatus, note string) (models.Order, error) {
+	if next == models.OrderCancelled {
+		return models.Order{}, ErrUseCancelOrder
+	}
+	return moveOrder(ctx, orders, orderID, next, note)
+}
+
+func moveOrder(ctx context.Context, orders OrderStore, orderID primitive.ObjectID, next models.OrderStatus, note string) (models.Order, error) {
+	if !next.Valid() {
+		return models.Order{}, ErrInvalidOrderStatus
+	}
+
+	order, err := orders.FindOrderByID(ctx, orderID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if !order.Status.CanTransitionTo(next) {
+		return order, ErrIllegalTransition
+	}
+	if next == models.OrderPacked && !order.PaymentMethod.Paid() {
+		return order, ErrOrderNotPaid
+	}
+
+	change := models.StatusChange{From: order.Status, To: next, At: time.Now(), Note: note}
+	if err := orders.UpdateOrderStatus(ctx, orderID, change); err != nil {
+		if !errors.Is(err, ErrOrderStatusChanged) {
+			log.Println(err)
+		}
+		return order, err
+	}
+
+	order.Status = next
+	order.StatusHistory = append(order.StatusHistory, change)
+	return order, nil
+}
+
+// GetUserOrder returns the order only if it belongs to userID, so one customer
+// can never read another's order by guessing its ID.
+func GetUserOrder(ctx context.Context, orders OrderStore, userID primitive.ObjectID, orderID primitive.ObjectID) (models.Order, error) {
+	order, err := orders.FindOrderByID(ctx, orderID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if order.UserID != userID {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// CancelOrder cancels one of the user's orders that has not shipped yet,
+// noting reason in its status history. The order's stock goes back on sale,
+// its coupon can be used again, and a captured card payment is refunded in
+// full. The order stays cancelled when the refund fails; the refund is then
+// returned with ErrRefundFailed for support to follow up. An order whose card
+// payment is still being processed cannot be cancelled until it is decided.
+func CancelOrder(ctx context.Context, orders OrderStore, products ProductStore, coupons CouponStore, ledger LedgerStore, provider payments.Provider, userID primitive.ObjectID, orderID primitive.ObjectID, reason string, issuedBy primitive.ObjectID) (models.Order, *models.Refund, error) {
+	order, err := GetUserOrder(ctx, orders, userID, orderID)
+	if err != nil {
+		return models.Order{}, nil, err
+	}
+	if !order.Status.CanTransitionTo(models.OrderCancelled) {
+		return order, nil, ErrNotCancellable
+	}
+	if order.PaymentMethod.Digital && order.PaymentMethod.Status == models.PaymentPending {
+		return order, nil, ErrPaymentPending
+	}
+
+	order, err = moveOrder(ctx, orders, orderID, models.OrderCancelled, reason)
+	if err != nil {
+		return order, nil, err
+	}
+
+	releaseLines(ctx, products, reservationLines(order.OrderCart))
+	if order.CouponCode != "" {
+		if err := coupons.ReleaseCoupon(ctx, order.CouponCode, userID); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if !order.PaymentMethod.Digital || order.PaymentMethod.Status != models.PaymentCaptured {
+		return order, nil, nil
+	}
+	refund, err := RefundOrder(ctx, orders, ledger, provider, orderID, nil, "order cancelled: "+reason, issuedBy)
+	if refund.ID.IsZero() {
+		return order, nil, err
+	}
+	order.Refunds = append(order.Refunds, refund)
+	return order, &refund, err
+}
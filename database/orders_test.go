@@ -0,0 +1,199 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUpdateOrderStatusWalksLifecycle(t *testing.T) {
+	s := newShop(t)
+	order := s.order(s.product("Kettle", 25000, 5), 1, models.PaymentMethodCOD)
+
+	steps := []models.OrderStatus{models.OrderPacked, models.OrderShipped, models.OrderDelivered}
+	for _, next := range steps {
+		if _, err := UpdateOrderStatus(s.ctx, s.store, order.OrderID, next, "moved by test"); err != nil {
+			t.Fatalf("moving to %s: %v", next, err)
+		}
+	}
+
+	stored := s.reload(order)
+	if stored.Status != models.OrderDelivered {
+		t.Errorf("order is %s, want %s", stored.Status, models.OrderDelivered)
+	}
+	history := stored.StatusHistory[len(stored.StatusHistory)-len(steps):]
+	from := models.OrderPending
+	for i, change := range history {
+		if change.From != from || change.To != steps[i] || change.Note != "moved by test" {
+			t.Errorf("history[%d] = %s -> %s (%q), want %s -> %s", i, change.From, change.To, change.Note, from, steps[i])
+		}
+		from = change.To
+	}
+}
+
+func TestUpdateOrderStatusRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		next   models.OrderStatus
+		err    error
+	}{
+		{"unknown status", models.PaymentMethodCOD, "lost", ErrInvalidOrderStatus},
+		{"skipping ahead", models.PaymentMethodCOD, models.OrderDelivered, ErrIllegalTransition},
+		{"moving back", models.PaymentMethodCOD, models.OrderPending, ErrIllegalTransition},
+		{"cancelling", models.PaymentMethodCOD, models.OrderCancelled, ErrUseCancelOrder},
+		{"packing an unpaid card order", models.PaymentMethodCard, models.OrderPacked, ErrOrderNotPaid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			order := s.order(s.product("Kettle", 25000, 5), 1, tt.method)
+
+			if _, err := UpdateOrderStatus(s.ctx, s.store, order.OrderID, tt.next, ""); !errors.Is(err, tt.err) {
+				t.Fatalf("UpdateOrderStatus(%s) error = %v, want %v", tt.next, err, tt.err)
+			}
+			if stored := s.reload(order); stored.Status != models.OrderPending || len(stored.StatusHistory) != len(order.StatusHistory) {
+				t.Errorf("order is %s with %d history entries after a refused move, want it untouched", stored.Status, len(stored.StatusHistory))
+			}
+		})
+	}
+}
+
+func TestUpdateOrderStatusPacksCapturedCardOrder(t *testing.T) {
+	s := newShop(t)
+	order := s.order(s.product("Kettle", 25000, 5), 1, models.PaymentMethodCard)
+	if _, err := PayOrder(s.ctx, s.store, payments.NewFakeProvider("secret", ""), order.OrderID, payments.CardSuccess); err != nil {
+		t.Fatal(err)
+	}
+
+	packed, err := UpdateOrderStatus(s.ctx, s.store, order.OrderID, models.OrderPacked, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packed.Status != models.OrderPacked {
+		t.Errorf("order is %s, want %s", packed.Status, models.OrderPacked)
+	}
+}
+
+func TestCancelOrderRestocksAndRefunds(t *testing.T) {
+	s := newShop(t)
+	provider := payments.NewFakeProvider("secret", "")
+	kettle := s.product("Kettle", 25000, 5)
+	order := s.paidCardOrder(provider, kettle, 2)
+	if stock := s.stock(kettle); stock != 3 {
+		t.Fatalf("stock = %d after selling 2 of 5, want 3", stock)
+	}
+
+	cancelled, refund, err := CancelOrder(s.ctx, s.store, s.store, s.store, s.store, provider, s.userID, order.OrderID, "ordered twice", s.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != models.OrderCancelled {
+		t.Errorf("order is %s, want %s", cancelled.Status, models.OrderCancelled)
+	}
+	if last := cancelled.StatusHistory[len(cancelled.StatusHistory)-1]; last.Note != "ordered twice" {
+		t.Errorf("cancellation noted as %q, want the reason", last.Note)
+	}
+	if stock := s.stock(kettle); stock != 5 {
+		t.Errorf("stock = %d after cancelling, want 5", stock)
+	}
+	if refund == nil || refund.Amount.Amount != 50000 || refund.Status != models.RefundCompleted {
+		t.Fatalf("refund = %+v, want 50000 refunded", refund)
+	}
+	if refunds := s.reload(order).Refunds; len(refunds) != 1 || refunds[0].ID != refund.ID {
+		t.Errorf("order records refunds %+v, want the cancellation refund", refunds)
+	}
+}
+
+func TestCancelCashOnDeliveryOrder(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+	order := s.order(kettle, 2, models.PaymentMethodCOD)
+
+	_, refund, err := CancelOrder(s.ctx, s.store, s.store, s.store, s.store, nil, s.userID, order.OrderID, "ordered twice", s.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund != nil {
+		t.Errorf("refund = %+v for an order nothing was paid for", refund)
+	}
+	if stock := s.stock(kettle); stock != 5 {
+		t.Errorf("stock = %d after cancelling, want 5", stock)
+	}
+}
+
+func TestCancelOrderKeepsCancellationWhenRefundFails(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 25000, 5)
+	order := s.paidCardOrder(payments.NewFakeProvider("secret", ""), kettle, 1)
+
+	// Another fake provider knows nothing of the payment, so its refund fails.
+	cancelled, refund, err := CancelOrder(s.ctx, s.store, s.store, s.store, s.store, payments.NewFakeProvider("secret", ""), s.userID, order.OrderID, "ordered twice", s.userID)
+	if !errors.Is(err, ErrRefundFailed) {
+		t.Fatalf("CancelOrder() error = %v, want %v", err, ErrRefundFailed)
+	}
+	if refund == nil || refund.Status != models.RefundFailed {
+		t.Errorf("refund = %+v, want a failed refund for support to follow up", refund)
+	}
+	if cancelled.Status != models.OrderCancelled || s.reload(order).Status != models.OrderCancelled {
+		t.Errorf("order is %s after its refund failed, want it cancelled all the same", s.reload(order).Status)
+	}
+	if stock := s.stock(kettle); stock != 5 {
+		t.Errorf("stock = %d after cancelling, want 5", stock)
+	}
+}
+
+func TestCancelOrderRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(s *shop, provider *payments.FakeProvider, order models.Order)
+		userID  func(s *shop) primitive.ObjectID
+		err     error
+	}{
+		{"shipped", func(s *shop, provider *payments.FakeProvider, order models.Order) {
+			s.payByCard(provider, order)
+			for _, next := range []models.OrderStatus{models.OrderPacked, models.OrderShipped} {
+				if _, err := UpdateOrderStatus(s.ctx, s.store, order.OrderID, next, ""); err != nil {
+					s.t.Fatal(err)
+				}
+			}
+		}, nil, ErrNotCancellable},
+		{"already cancelled", func(s *shop, provider *payments.FakeProvider, order models.Order) {
+			if _, _, err := CancelOrder(s.ctx, s.store, s.store, s.store, s.store, provider, s.userID, order.OrderID, "first", s.userID); err != nil {
+				s.t.Fatal(err)
+			}
+		}, nil, ErrNotCancellable},
+		{"payment being processed", func(s *shop, provider *payments.FakeProvider, order models.Order) {
+			if _, err := PayOrder(s.ctx, s.store, provider, order.OrderID, payments.CardTimeout); !errors.Is(err, ErrPaymentPending) {
+				s.t.Fatal(err)
+			}
+		}, nil, ErrPaymentPending},
+		{"someone else's order", nil, func(*shop) primitive.ObjectID { return primitive.NewObjectID() }, ErrOrderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			provider := payments.NewFakeProvider("secret", "")
+			kettle := s.product("Kettle", 25000, 5)
+			order := s.order(kettle, 1, models.PaymentMethodCard)
+			if tt.prepare != nil {
+				tt.prepare(s, provider, order)
+			}
+			userID := s.userID
+			if tt.userID != nil {
+				userID = tt.userID(s)
+			}
+			stock := s.stock(kettle)
+
+			if _, _, err := CancelOrder(s.ctx, s.store, s.store, s.store, s.store, provider, userID, order.OrderID, "too late", userID); !errors.Is(err, tt.err) {
+				t.Fatalf("CancelOrder() error = %v, want %v", err, tt.err)
+			}
+			if after := s.stock(kettle); after != stock {
+				t.Errorf("stock = %d after a refused cancellation, want %d", after, stock)
+			}
+		})
+	}
+}
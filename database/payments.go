@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidPaymentMethod = errors.New("payment method must be cod or card")
+	ErrPaymentFailed        = errors.New("payment failed")
+	ErrPaymentPending       = errors.New("the payment is still being processed")
+	ErrNotPayable           = errors.New("this order is not waiting to be paid by card")
+	ErrOrderNotPaid         = errors.New("the order has not been paid for yet")
+)
+
+// PaymentDeadline is how long a card order may wait to be paid. Its stock
+// is taken when it is placed, so an order still unpaid after that is
+// cancelled to put the stock back on sale.
+var PaymentDeadline = time.Hour
+
+// paymentFor returns the payment of a new order paid for by method, cash on
+// delivery when method is empty.
+func paymentFor(method string) (models.Payment, error) {
+	switch method {
+	case "", models.PaymentMethodCOD:
+		return models.Payment{COD: true}, nil
+	case models.PaymentMethodCard:
+		return models.Payment{Digital: true}, nil
+	}
+	return models.Payment{}, ErrInvalidPaymentMethod
+}
+
+// PayOrder charges the total of a pending card order to card. The payment is
+// authorized and captured straight away, and the order moves to paid once
+// the money is taken. A declined card leaves the order pending, so it can be
+// paid with another card. When the provider does not answer in time the
+// outcome is unknown, and the order waits for the provider to report it.
+func PayOrder(ctx context.Context, orders OrderStore, provider payments.Provider, orderID primitive.ObjectID, card string) (models.Order, error) {
+	order, err := orders.FindOrderByID(ctx, orderID)
+	if err != nil {
+		return models.Order{}, err
+	}
+	payment := order.PaymentMethod
+	if order.Status != models.OrderPending || !payment.Digital || (payment.Status != "" && payment.Status != models.PaymentFailed && payment.Status != models.PaymentVoided) {
+		return order, ErrNotPayable
+	}
+	total, err := order.Total()
+	if err != nil {
+		return order, err
+	}
+
+	// Claim the payment first so that two attempts cannot both charge.
+	from := payment.Status
+	payment = models.Payment{Digital: true, Provider: provider.Name(), Status: models.PaymentPending, Amount: total}
+	if err := orders.UpdateOrderPayment(ctx, orderID, from, payment); err != nil {
+		if errors.Is(err, ErrPaymentChanged) {
+			return order, ErrNotPayable
+		}
+		log.Println(err)
+		return order, err
+	}
+
+	payment.TransactionID, err = provider.Authorize(ctx, payments.Request{Reference: orderID.Hex(), Amount: total, Card: card})
+	switch {
+	case errors.Is(err, payments.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		// Leave the payment pending for the provider to settle.
+	case err != nil:
+		payment.Status, payment.Error = models.PaymentFailed, err.Error()
+	default:
+		if err := provider.Capture(ctx, payment.TransactionID, total); err != nil {
+			log.Println(err)
+			if err := provider.Void(ctx, payment.TransactionID); err != nil {
+				log.Println(err)
+			}
+			payment.Status, payment.Error = models.PaymentVoided, err.Error()
+		} else {
+			payment.Status = models.PaymentCaptured
+		}
+	}
+
+	if err := orders.UpdateOrderPayment(ctx, orderID, models.PaymentPending, payment); err != nil {
+		log.Println(err)
+		return order, err
+	}
+	order.PaymentMethod = payment
+
+	switch payment.Status {
+	case models.PaymentPending:
+		return order, ErrPaymentPending
+	case models.PaymentFailed, models.PaymentVoided:
+		return order, fmt.Errorf("%w: %s", ErrPaymentFailed, payment.Error)
+	}
+	return UpdateOrderStatus(ctx, orders, orderID, models.OrderPaid, "paid by card")
+}
+
+// CancelUnpaidOrders cancels every card order that has not been paid within
+// PaymentDeadline, through CancelOrder, so that its stock and coupon are
+// given back. Orders whose payment is still being processed are left for
+// the provider to decide.
+func CancelUnpaidOrders(ctx context.Context, orders OrderStore, products ProductStore, coupons CouponStore, ledger LedgerStore, provider payments.Provider) error {
+	unpaid, err := orders.FindUnpaidOrders(ctx, time.Now().Add(-PaymentDeadline))
+	if err != nil {
+		return err
+	}
+	for _, order := range unpaid {
+		_, _, err := CancelOrder(ctx, orders, products, coupons, ledger, provider, order.UserID, order.OrderID, "not paid within "+PaymentDeadline.String(), primitive.NilObjectID)
+		// The customer may have paid or cancelled meanwhile.
+		if err != nil && !errors.Is(err, ErrNotCancellable) && !errors.Is(err, ErrPaymentPending) && !errors.Is(err, ErrOrderStatusChanged) {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// SweepUnpaidOrders calls CancelUnpaidOrders every interval until ctx is
+// cancelled.
+func SweepUnpaidOrders(ctx context.Context, orders OrderStore, products ProductStore, coupons CouponStore, ledger LedgerStore, provider payments.Provider, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := CancelUnpaidOrders(ctx, orders, products, coupons, ledger, provider); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+)
+
+func TestPayOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		card          string
+		err           error
+		status        models.OrderStatus
+		paymentStatus models.PaymentStatus
+	}{
+		{"success", payments.CardSuccess, nil, models.OrderPaid, models.PaymentCaptured},
+		{"decline", payments.CardDecline, ErrPaymentFailed, models.OrderPending, models.PaymentFailed},
+		{"timeout", payments.CardTimeout, ErrPaymentPending, models.OrderPending, models.PaymentPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			order := s.order(s.product("Kettle", 49900, 10), 1, models.PaymentMethodCard)
+
+			_, err := PayOrder(s.ctx, s.store, payments.NewFakeProvider("secret", ""), order.OrderID, tt.card)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("PayOrder() error = %v, want %v", err, tt.err)
+			}
+
+			stored := s.reload(order)
+			if stored.Status != tt.status || stored.PaymentMethod.Status != tt.paymentStatus {
+				t.Errorf("order is %s with payment %s, want %s with payment %s", stored.Status, stored.PaymentMethod.Status, tt.status, tt.paymentStatus)
+			}
+			if total, _ := stored.Total(); stored.PaymentMethod.Amount != total {
+				t.Errorf("payment amount = %v, want the order total %v", stored.PaymentMethod.Amount, total)
+			}
+		})
+	}
+}
+
+func TestPayOrderAfterDecline(t *testing.T) {
+	s := newShop(t)
+	provider := payments.NewFakeProvider("secret", "")
+	order := s.order(s.product("Kettle", 49900, 10), 1, models.PaymentMethodCard)
+
+	if _, err := PayOrder(s.ctx, s.store, provider, order.OrderID, payments.CardDecline); !errors.Is(err, ErrPaymentFailed) {
+		t.Fatalf("declined card: err = %v, want %v", err, ErrPaymentFailed)
+	}
+	paid, err := PayOrder(s.ctx, s.store, provider, order.OrderID, payments.CardSuccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid.Status != models.OrderPaid {
+		t.Errorf("order is %s after paying with a good card, want %s", paid.Status, models.OrderPaid)
+	}
+	if _, err := PayOrder(s.ctx, s.store, provider, order.OrderID, payments.CardSuccess); !errors.Is(err, ErrNotPayable) {
+		t.Errorf("paying a paid order: err = %v, want %v", err, ErrNotPayable)
+	}
+}
+
+func TestPendingPaymentSettles(t *testing.T) {
+	tests := []struct {
+		name          string
+		approve       bool
+		status        models.OrderStatus
+		paymentStatus models.PaymentStatus
+	}{
+		{"approved", true, models.OrderPaid, models.PaymentCaptured},
+		{"declined", false, models.OrderPending, models.PaymentFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			provider := payments.NewFakeProvider("secret", "")
+			order := s.order(s.product("Kettle", 49900, 10), 1, models.PaymentMethodCard)
+
+			pending, err := PayOrder(s.ctx, s.store, provider, order.OrderID, payments.CardTimeout)
+			if !errors.Is(err, ErrPaymentPending) {
+				t.Fatalf("PayOrder() error = %v, want %v", err, ErrPaymentPending)
+			}
+			if _, err := PayOrder(s.ctx, s.store, provider, order.OrderID, payments.CardSuccess); !errors.Is(err, ErrNotPayable) {
+				t.Errorf("paying again while pending: err = %v, want %v", err, ErrNotPayable)
+			}
+
+			event, err := provider.Settle(pending.PaymentMethod.TransactionID, tt.approve)
+			if err != nil {
+				t.Fatal(err)
+			}
+			record, err := ReceivePaymentEvent(s.ctx, s.store, s.store, provider, event, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Outcome != models.EventApplied {
+				t.Errorf("event outcome = %q (%s), want %q", record.Outcome, record.Error, models.EventApplied)
+			}
+
+			stored := s.reload(order)
+			if stored.Status != tt.status || stored.PaymentMethod.Status != tt.paymentStatus {
+				t.Errorf("order is %s with payment %s, want %s with payment %s", stored.Status, stored.PaymentMethod.Status, tt.status, tt.paymentStatus)
+			}
+		})
+	}
+}
+
+func TestCancelUnpaidOrders(t *testing.T) {
+	s := newShop(t)
+	provider := payments.NewFakeProvider("secret", "")
+	kettle := s.product("Kettle", 49900, 10)
+
+	declined := s.order(kettle, 1, models.PaymentMethodCard)
+	if _, err := PayOrder(s.ctx, s.store, provider, declined.OrderID, payments.CardDecline); !errors.Is(err, ErrPaymentFailed) {
+		t.Fatal(err)
+	}
+	neverPaid := s.order(kettle, 2, models.PaymentMethodCard)
+	processing := s.order(kettle, 1, models.PaymentMethodCard)
+	if _, err := PayOrder(s.ctx, s.store, provider, processing.OrderID, payments.CardTimeout); !errors.Is(err, ErrPaymentPending) {
+		t.Fatal(err)
+	}
+	paid := s.order(kettle, 1, models.PaymentMethodCard)
+	if _, err := PayOrder(s.ctx, s.store, provider, paid.OrderID, payments.CardSuccess); err != nil {
+		t.Fatal(err)
+	}
+	cod := s.order(kettle, 1, models.PaymentMethodCOD)
+	if stock := s.stock(kettle); stock != 4 {
+		t.Fatalf("stock = %d after ordering 6 of 10, want 4", stock)
+	}
+
+	defer func(deadline time.Duration) { PaymentDeadline = deadline }(PaymentDeadline)
+	PaymentDeadline = time.Hour
+	if err := CancelUnpaidOrders(s.ctx, s.store, s.store, s.store, s.store, provider); err != nil {
+		t.Fatal(err)
+	}
+	if stock := s.stock(kettle); stock != 4 {
+		t.Errorf("stock = %d after sweeping orders still within the deadline, want 4", stock)
+	}
+
+	PaymentDeadline = 0
+	if err := CancelUnpaidOrders(s.ctx, s.store, s.store, s.store, s.store, provider); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		order  models.Order
+		status models.OrderStatus
+	}{
+		{"declined", declined, models.OrderCancelled},
+		{"never paid", neverPaid, models.OrderCancelled},
+		{"still processing", processing, models.OrderPending},
+		{"paid", paid, models.OrderPaid},
+		{"cash on delivery", cod, models.OrderPending},
+	} {
+		if status := s.reload(tt.order).Status; status != tt.status {
+			t.Errorf("%s order is %s after the deadline, want %s", tt.name, status, tt.status)
+		}
+	}
+	if stock := s.stock(kettle); stock != 7 {
+		t.Errorf("stock = %d after cancelling 3 unpaid units, want 7", stock)
+	}
+}
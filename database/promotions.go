@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidPromotion = errors.New("invalid promotion")
+
+func CreatePromotion(ctx context.Context, promotions PromotionStore, promotion models.Promotion) (models.Promotion, error) {
+	promotion.ProductIDs = uniqueIDs(promotion.ProductIDs)
+	switch promotion.Kind {
+	case models.PromotionBuyXGetY:
+		if promotion.BuyQuantity == 0 || promotion.FreeQuantity == 0 {
+			return models.Promotion{}, fmt.Errorf("%w: buy_x_get_y needs buy_quantity and free_quantity", ErrInvalidPromotion)
+		}
+	case models.PromotionBundle:
+		price, err := NormalizePrice(promotion.BundlePrice)
+		if len(promotion.ProductIDs) < 2 || errors.Is(err, ErrInvalidPrice) {
+			return models.Promotion{}, fmt.Errorf("%w: a bundle needs at least two product_ids and a bundle_price", ErrInvalidPromotion)
+		}
+		if err != nil {
+			return models.Promotion{}, err
+		}
+		promotion.BundlePrice = price
+	case models.PromotionTiered:
+		if len(promotion.Tiers) == 0 {
+			return models.Promotion{}, fmt.Errorf("%w: tiered pricing needs at least one tier", ErrInvalidPromotion)
+		}
+	}
+	if !promotion.StartsAt.IsZero() && !promotion.EndsAt.IsZero() && !promotion.EndsAt.After(promotion.StartsAt) {
+		return models.Promotion{}, fmt.Errorf("%w: it must end after it starts", ErrInvalidPromotion)
+	}
+
+	promotion.ID = primitive.NewObjectID()
+	promotion.CreatedAt = time.Now()
+	if err := promotions.InsertPromotion(ctx, promotion); err != nil {
+		return models.Promotion{}, err
+	}
+	return promotion, nil
+}
+
+func uniqueIDs(ids []primitive.ObjectID) []primitive.ObjectID {
+	var unique []primitive.ObjectID
+	for _, id := range ids {
+		if !containsID(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// ApplyPromotions works out what the promotions running now take off cart,
+// one saving per promotion that applied. Promotions are applied oldest first
+// and every unit in the cart counts towards at most one of them, so a unit
+// given away by one promotion is not discounted again by the next.
+func ApplyPromotions(ctx context.Context, promotions PromotionStore, cart []models.ProductUser) ([]models.PromotionSaving, models.Money, error) {
+	list, err := promotions.ListPromotions(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil, models.Money{}, err
+	}
+
+	subtotal, err := CartTotal(cart)
+	if err != nil {
+		return nil, models.Money{}, err
+	}
+	lines := promotionLines(cart)
+	now := time.Now()
+	var savings []models.PromotionSaving
+	total := models.NewMoney(0, subtotal.Currency)
+	for _, promotion := range list {
+		if (!promotion.StartsAt.IsZero() && now.Before(promotion.StartsAt)) || (!promotion.EndsAt.IsZero() && !now.Before(promotion.EndsAt)) {
+			continue
+		}
+
+		var amount int64
+		switch promotion.Kind {
+		case models.PromotionBuyXGetY:
+			amount = applyBuyXGetY(promotion, lines)
+		case models.PromotionBundle:
+			if promotion.BundlePrice.Currency == total.Currency {
+				amount = applyBundle(promotion, lines)
+			}
+		case models.PromotionTiered:
+			amount = applyTiered(promotion, lines)
+		}
+		if amount == 0 {
+			continue
+		}
+		saving := models.NewMoney(amount, total.Currency)
+		savings = append(savings, models.PromotionSaving{PromotionID: promotion.ID, Name: promotion.Name, Amount: saving})
+		total.Amount += amount
+	}
+	return savings, total, nil
+}
+
+// promotionLine tracks how many units of a cart line no promotion has used
+// yet. All lines share the cart's currency, so prices are bare minor units.
+type promotionLine struct {
+	productID primitive.ObjectID
+	price     int64
+	remaining uint64
+}
+
+// promotionLines returns the cart lines most expensive first, the order the
+// promotions walk through units in.
+func promotionLines(cart []models.ProductUser) []*promotionLine {
+	lines := []*promotionLine{}
+	for _, item := range cart {
+		lines = append(lines, &promotionLine{productID: item.ProductID, price: item.Price.Amount, remaining: lineQuantity(item)})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].price > lines[j].price })
+	return lines
+}
+
+// matchingLines returns the lines with unused units that productIDs covers;
+// an empty productIDs covers every line.
+func matchingLines(lines []*promotionLine, productIDs []primitive.ObjectID) []*promotionLine {
+	var matching []*promotionLine
+	for _, line := range lines {
+		if line.remaining > 0 && (len(productIDs) == 0 || containsID(productIDs, line.productID)) {
+			matching = append(matching, line)
+		}
+	}
+	return matching
+}
+
+// applyBuyXGetY splits the matching units, most expensive first, into groups
+// of BuyQuantity + FreeQuantity and gives away the last FreeQuantity units of
+// each complete group, which are its cheapest.
+func applyBuyXGetY(promotion models.Promotion, lines []*promotionLine) int64 {
+	matching := matchingLines(lines, promotion.ProductIDs)
+	group := promotion.BuyQuantity + promotion.FreeQuantity
+
+	var units uint64
+	for _, line := range matching {
+		units += line.remaining
+	}
+	used := units / group * group
+
+	// free counts the free units among the first n units.
+	free := func(n uint64) uint64 {
+		return n/group*promotion.FreeQuantity + (n%group - min(n%group, promotion.BuyQuantity))
+	}
+
+	var saving int64
+	var position uint64
+	for _, line := range matching {
+		if position >= used {
+			break
+		}
+		taken := min(line.remaining, used-position)
+		saving += int64(free(position+taken)-free(position)) * line.price
+		line.remaining -= taken
+		position += taken
+	}
+	return saving
+}
+
+// applyBundle sells as many complete sets of the bundle's products as the
+// cart holds for BundlePrice each, using the most expensive unit of every
+// product first. Sets that would cost less than BundlePrice on their own are
+// left alone.
+func applyBundle(promotion models.Promotion, lines []*promotionLine) int64 {
+	var saving int64
+	for {
+		set := []*promotionLine{}
+		var price int64
+		sets := ^uint64(0)
+		for _, productID := range promotion.ProductIDs {
+			matching := matchingLines(lines, []primitive.ObjectID{productID})
+			if len(matching) == 0 {
+				return saving
+			}
+			set = append(set, matching[0])
+			price += matching[0].price
+			sets = min(sets, matching[0].remaining)
+		}
+		if price <= promotion.BundlePrice.Amount {
+			return saving
+		}
+
+		saving += int64(sets) * (price - promotion.BundlePrice.Amount)
+		for _, line := range set {
+			line.remaining -= sets
+		}
+	}
+}
+
+// applyTiered takes the percent of the highest tier the number of matching
+// units reaches off all of them.
+func applyTiered(promotion models.Promotion, lines []*promotionLine) int64 {
+	matching := matchingLines(lines, promotion.ProductIDs)
+
+	var units uint64
+	var total int64
+	for _, line := range matching {
+		units += line.remaining
+		total += int64(line.remaining) * line.price
+	}
+
+	var reached *models.PriceTier
+	for i, tier := range promotion.Tiers {
+		if units >= tier.MinQuantity && (reached == nil || tier.MinQuantity > reached.MinQuantity) {
+			reached = &promotion.Tiers[i]
+		}
+	}
+	if reached == nil {
+		return 0
+	}
+
+	for _, line := range matching {
+		line.remaining = 0
+	}
+	return total * int64(reached.Percent) / 100
+}
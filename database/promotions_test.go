@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pricedLine is quantity units of product at price paise, as the cart
+// holds them once priced.
+func pricedLine(productID primitive.ObjectID, price int64, quantity uint64) models.ProductUser {
+	return models.ProductUser{ProductID: productID, Price: models.NewMoney(price, models.BaseCurrency), Quantity: quantity}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	shirt, socks, hat := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	inr := func(amount int64) models.Money { return models.NewMoney(amount, models.BaseCurrency) }
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		cart       []models.ProductUser
+		savings    []int64
+	}{
+		{
+			"buy one get one gives the cheaper unit",
+			[]models.Promotion{{Kind: models.PromotionBuyXGetY, BuyQuantity: 1, FreeQuantity: 1}},
+			[]models.ProductUser{pricedLine(shirt, 3000, 1), pricedLine(socks, 1000, 1)},
+			[]int64{1000},
+		},
+		{
+			"buy two get one groups the dearest units first",
+			[]models.Promotion{{Kind: models.PromotionBuyXGetY, BuyQuantity: 2, FreeQuantity: 1}},
+			[]models.ProductUser{pricedLine(shirt, 3000, 2), pricedLine(socks, 1000, 2), pricedLine(hat, 2000, 1)},
+			[]int64{2000},
+		},
+		{
+			"buy one get one only on listed products",
+			[]models.Promotion{{Kind: models.PromotionBuyXGetY, BuyQuantity: 1, FreeQuantity: 1, ProductIDs: []primitive.ObjectID{socks}}},
+			[]models.ProductUser{pricedLine(shirt, 3000, 1), pricedLine(socks, 1000, 3)},
+			[]int64{1000},
+		},
+		{
+			"bundle sells complete sets",
+			[]models.Promotion{{Kind: models.PromotionBundle, ProductIDs: []primitive.ObjectID{shirt, hat}, BundlePrice: inr(4000)}},
+			[]models.ProductUser{pricedLine(shirt, 3000, 3), pricedLine(hat, 2000, 2)},
+			[]int64{2000},
+		},
+		{
+			"bundle dearer than its parts",
+			[]models.Promotion{{Kind: models.PromotionBundle, ProductIDs: []primitive.ObjectID{shirt, hat}, BundlePrice: inr(6000)}},
+			[]models.ProductUser{pricedLine(shirt, 3000, 1), pricedLine(hat, 2000, 1)},
+			nil,
+		},
+		{
+			"tiered takes the highest tier reached",
+			[]models.Promotion{{Kind: models.PromotionTiered, Tiers: []models.PriceTier{{MinQuantity: 5, Percent: 10}, {MinQuantity: 2, Percent: 5}}}},
+			[]models.ProductUser{pricedLine(socks, 1000, 5)},
+			[]int64{500},
+		},
+		{
+			"tiered below every tier",
+			[]models.Promotion{{Kind: models.PromotionTiered, Tiers: []models.PriceTier{{MinQuantity: 2, Percent: 5}}}},
+			[]models.ProductUser{pricedLine(socks, 1000, 1)},
+			nil,
+		},
+		{
+			"tiered rounds down",
+			[]models.Promotion{{Kind: models.PromotionTiered, Tiers: []models.PriceTier{{MinQuantity: 1, Percent: 15}}}},
+			[]models.ProductUser{pricedLine(socks, 999, 1)},
+			[]int64{149},
+		},
+		{
+			"a unit counts towards one promotion",
+			[]models.Promotion{
+				{Kind: models.PromotionBuyXGetY, BuyQuantity: 1, FreeQuantity: 1, ProductIDs: []primitive.ObjectID{socks}},
+				{Kind: models.PromotionTiered, Tiers: []models.PriceTier{{MinQuantity: 1, Percent: 10}}},
+			},
+			[]models.ProductUser{pricedLine(socks, 1000, 3), pricedLine(shirt, 3000, 1)},
+			[]int64{1000, 400},
+		},
+		{
+			"not running",
+			[]models.Promotion{
+				{Kind: models.PromotionBuyXGetY, BuyQuantity: 1, FreeQuantity: 1, StartsAt: time.Now().Add(time.Hour)},
+				{Kind: models.PromotionBuyXGetY, BuyQuantity: 1, FreeQuantity: 1, EndsAt: time.Now().Add(-time.Hour)},
+			},
+			[]models.ProductUser{pricedLine(socks, 1000, 2)},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			for _, promotion := range tt.promotions {
+				promotion.Name = tt.name
+				if _, err := CreatePromotion(ctx, store, promotion); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			savings, total, err := ApplyPromotions(ctx, store, tt.cart)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want int64
+			for _, amount := range tt.savings {
+				want += amount
+			}
+			if len(savings) != len(tt.savings) || total.Amount != want {
+				t.Fatalf("savings = %+v totalling %d, want %v totalling %d", savings, total.Amount, tt.savings, want)
+			}
+			for i, saving := range savings {
+				if saving.Amount.Amount != tt.savings[i] {
+					t.Errorf("saving %d = %d, want %d", i, saving.Amount.Amount, tt.savings[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCreatePromotionValidates(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+	}{
+		{"buy x get none", models.Promotion{Kind: models.PromotionBuyXGetY, BuyQuantity: 2}},
+		{"bundle of one", models.Promotion{Kind: models.PromotionBundle, ProductIDs: []primitive.ObjectID{primitive.NewObjectID()}, BundlePrice: models.NewMoney(1000, models.BaseCurrency)}},
+		{"bundle without a price", models.Promotion{Kind: models.PromotionBundle, ProductIDs: []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}}},
+		{"tiered without tiers", models.Promotion{Kind: models.PromotionTiered}},
+		{"ends before it starts", models.Promotion{Kind: models.PromotionTiered, Tiers: []models.PriceTier{{MinQuantity: 1, Percent: 5}}, StartsAt: now, EndsAt: now}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CreatePromotion(context.Background(), NewMemoryStore(), tt.promotion); !errors.Is(err, ErrInvalidPromotion) {
+				t.Errorf("CreatePromotion() error = %v, want %v", err, ErrInvalidPromotion)
+			}
+		})
+	}
+}
+
+func TestCheckoutAppliesPromotions(t *testing.T) {
+	s := newShop(t)
+	socks := s.product("Socks", 1000, 10)
+	if _, err := CreatePromotion(s.ctx, s.store, models.Promotion{Name: "Socks BOGO", Kind: models.PromotionBuyXGetY, BuyQuantity: 1, FreeQuantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	order := s.order(socks, 4, models.PaymentMethodCOD)
+	if order.Discount.Amount != 2000 || len(order.Promotions) != 1 || order.Promotions[0].Name != "Socks BOGO" {
+		t.Errorf("order discount = %d from %+v, want 2000 from Socks BOGO", order.Discount.Amount, order.Promotions)
+	}
+	if total, err := order.Total(); err != nil || total.Amount != 2000 {
+		t.Errorf("order total = %d, %v, want 2000", total.Amount, err)
+	}
+}
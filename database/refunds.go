@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotRefundable   = errors.New("nothing has been paid for this order yet")
+	ErrOverRefund      = errors.New("that is more than is left to refund on this line")
+	ErrNothingToRefund = errors.New("everything on this order has already been refunded")
+	ErrInvalidRefund   = errors.New("invalid refund")
+	ErrRefundFailed    = errors.New("refund failed")
+	ErrNoProvider      = errors.New("the payment provider this order was paid through is not available")
+)
+
+// RefundOrder gives back what the customer paid for lines of the order, or
+// for everything not refunded yet when lines is empty. A line with no
+// quantity refunds all of it that is left. Card payments are refunded
+// through provider; cash on delivery refunds are written to the ledger to be
+// paid out by hand. The refund is recorded on the order before the money
+// moves, so two refunds at once cannot both pass the over-refund check.
+func RefundOrder(ctx context.Context, orders OrderStore, ledger LedgerStore, provider payments.Provider, orderID primitive.ObjectID, lines []models.RefundLine, reason string, issuedBy primitive.ObjectID) (models.Refund, error) {
+	order, err := orders.FindOrderByID(ctx, orderID)
+	if err != nil {
+		return models.Refund{}, err
+	}
+
+	refund := models.Refund{
+		ID:        primitive.NewObjectID(),
+		Reason:    reason,
+		Method:    models.PaymentMethodCOD,
+		Status:    models.RefundPending,
+		IssuedBy:  issuedBy,
+		CreatedAt: time.Now(),
+	}
+	payment := order.PaymentMethod
+	switch {
+	case payment.Digital && payment.Status == models.PaymentCaptured:
+		if provider == nil || provider.Name() != payment.Provider {
+			return models.Refund{}, ErrNoProvider
+		}
+		refund.Method = models.PaymentMethodCard
+	case payment.Digital:
+		return models.Refund{}, ErrNotRefundable
+	case order.Status != models.OrderDelivered && order.Status != models.OrderReturned:
+		// Cash on delivery is only collected once the order is delivered.
+		return models.Refund{}, ErrNotRefundable
+	}
+
+	if refund.Lines, err = refundLines(order, lines); err != nil {
+		return models.Refund{}, err
+	}
+	refund.Amount = models.NewMoney(0, order.Price.Currency)
+	for _, line := range refund.Lines {
+		refund.Amount.Amount += line.Amount.Amount
+	}
+
+	if err := orders.AddOrderRefund(ctx, orderID, len(order.Refunds), refund); err != nil {
+		if !errors.Is(err, ErrRefundConflict) {
+			log.Println(err)
+		}
+		return models.Refund{}, err
+	}
+
+	if refund.Method == models.PaymentMethodCard {
+		refund.TransactionID, err = provider.Refund(ctx, payment.TransactionID, refund.Amount)
+	} else {
+		err = ledger.InsertLedgerEntry(ctx, models.LedgerEntry{
+			ID:        primitive.NewObjectID(),
+			OrderID:   orderID,
+			UserID:    order.UserID,
+			RefundID:  refund.ID,
+			Amount:    refund.Amount,
+			Reason:    reason,
+			CreatedAt: refund.CreatedAt,
+		})
+	}
+	refund.Status = models.RefundCompleted
+	if err != nil {
+		log.Println(err)
+		refund.Status, refund.Error = models.RefundFailed, err.Error()
+	}
+
+	if err := orders.UpdateOrderRefund(ctx, orderID, refund); err != nil {
+		log.Println(err)
+		return refund, err
+	}
+	if refund.Status == models.RefundFailed {
+		return refund, fmt.Errorf("%w: %s", ErrRefundFailed, refund.Error)
+	}
+	return refund, nil
+}
+
+// refundLines works out the lines of a refund of requested from order. Each
+// line's units are refunded at what they were paid, and the units refunded
+// last get whatever rounding left over, so refunding a whole line, in one go
+// or in parts, gives back exactly what was paid for it.
+func refundLines(order models.Order, requested []models.RefundLine) ([]models.RefundLine, error) {
+	cart := normalizeCart(order.OrderCart)
+	paid, err := paidPerLine(order, cart)
+	if err != nil {
+		return nil, err
+	}
+
+	refunded := refundedQuantities(order)
+
+	wanted := map[lineKey]uint64{}
+	for _, line := range requested {
+		key := lineKey{line.ProductID, line.VariantSKU}
+		if _, ok := wanted[key]; ok {
+			return nil, fmt.Errorf("%w: product %s is listed twice", ErrInvalidRefund, line.ProductID.Hex())
+		}
+		wanted[key] = line.Quantity
+	}
+
+	lines := []models.RefundLine{}
+	for i, item := range cart {
+		key := lineKey{item.ProductID, item.VariantSKU}
+		quantity := lineQuantity(item)
+		left := quantity - min(refunded[key], quantity)
+
+		take, ok := wanted[key]
+		delete(wanted, key)
+		switch {
+		case len(requested) == 0, ok && take == 0:
+			take = left
+		case !ok:
+			continue
+		case take > left:
+			return nil, fmt.Errorf("%w: only %d of %s left", ErrOverRefund, left, item.ProductName)
+		}
+		if take == 0 {
+			continue
+		}
+
+		before := quantity - left
+		amount := proportion(paid[i], int64(before+take), int64(quantity)) - proportion(paid[i], int64(before), int64(quantity))
+		lines = append(lines, models.RefundLine{
+			ProductID:  item.ProductID,
+			VariantSKU: item.VariantSKU,
+			Quantity:   take,
+			Amount:     models.NewMoney(amount, order.Price.Currency),
+		})
+	}
+	for key := range wanted {
+		return nil, fmt.Errorf("%w: the order has no line for product %s", ErrInvalidRefund, key.productID.Hex())
+	}
+	if len(lines) == 0 {
+		return nil, ErrNothingToRefund
+	}
+	return lines, nil
+}
+
+// refundedQuantities returns how many units of each of the order's lines
+// have been, or are being, refunded.
+func refundedQuantities(order models.Order) map[lineKey]uint64 {
+	refunded := map[lineKey]uint64{}
+	for _, refund := range order.Refunds {
+		if refund.Status == models.RefundFailed {
+			continue
+		}
+		for _, line := range refund.Lines {
+			refunded[lineKey{line.ProductID, line.VariantSKU}] += line.Quantity
+		}
+	}
+	return refunded
+}
+
+// paidPerLine returns what the customer paid for each line of cart, the
+// order's lines, with discounts and tax included, so that the amounts add up
+// to the order's total. Orders placed before tax was broken down per line
+// have their total shared out by line value instead.
+func paidPerLine(order models.Order, cart []models.ProductUser) ([]int64, error) {
+	paid := make([]int64, len(cart))
+	if len(order.TaxLines) == len(cart) {
+		for i, line := range order.TaxLines {
+			paid[i] = line.Taxable.Amount
+			if !order.TaxInclusive {
+				paid[i] += line.Tax.Amount
+			}
+		}
+		return paid, nil
+	}
+
+	total, err := order.Total()
+	if err != nil {
+		return nil, err
+	}
+	subtotal, err := CartTotal(cart)
+	if err != nil {
+		return nil, err
+	}
+	var running int64
+	for i, item := range cart {
+		price, err := item.Price.Times(lineQuantity(item))
+		if err != nil {
+			return nil, err
+		}
+		value := price.Amount
+		paid[i] = proportion(total.Amount, running+value, subtotal.Amount) - proportion(total.Amount, running, subtotal.Amount)
+		running += value
+	}
+	return paid, nil
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// paidCardOrder places an order for quantity of product and pays for it by
+// card through provider.
+func (s *shop) paidCardOrder(provider *payments.FakeProvider, productID primitive.ObjectID, quantity uint64) models.Order {
+	s.t.Helper()
+	return s.payByCard(provider, s.order(productID, quantity, models.PaymentMethodCard))
+}
+
+// payByCard pays for order with a card that goes through.
+func (s *shop) payByCard(provider *payments.FakeProvider, order models.Order) models.Order {
+	s.t.Helper()
+	paid, err := PayOrder(s.ctx, s.store, provider, order.OrderID, payments.CardSuccess)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return paid
+}
+
+func TestRefundOrderInParts(t *testing.T) {
+	s := newShop(t)
+	provider := payments.NewFakeProvider("secret", "")
+	kettle := s.product("Kettle", 1000, 5)
+	s.coupon(models.Coupon{Code: "ONEPAISA", Kind: models.CouponFixed, Amount: models.NewMoney(1, models.BaseCurrency)})
+	s.addToCart(kettle, 3)
+	if _, err := s.applyCoupon("ONEPAISA"); err != nil {
+		t.Fatal(err)
+	}
+	order, err := s.checkout(models.PaymentMethodCard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order = s.payByCard(provider, order)
+	if total, _ := order.Total(); total.Amount != 2999 {
+		t.Fatalf("order total = %d, want 2999", total.Amount)
+	}
+
+	one := []models.RefundLine{{ProductID: kettle, Quantity: 1}}
+	var refunded int64
+	for _, want := range []int64{999, 1000} {
+		refund, err := RefundOrder(s.ctx, s.store, s.store, provider, order.OrderID, one, "broken", s.userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refund.Amount.Amount != want || refund.Method != models.PaymentMethodCard || refund.Status != models.RefundCompleted {
+			t.Errorf("refund of one kettle = %d by %s (%s), want %d by card", refund.Amount.Amount, refund.Method, refund.Status, want)
+		}
+		refunded += refund.Amount.Amount
+	}
+
+	if _, err := RefundOrder(s.ctx, s.store, s.store, provider, order.OrderID, []models.RefundLine{{ProductID: kettle, Quantity: 2}}, "broken", s.userID); !errors.Is(err, ErrOverRefund) {
+		t.Fatalf("refunding 2 of the 1 left: err = %v, want %v", err, ErrOverRefund)
+	}
+
+	rest, err := RefundOrder(s.ctx, s.store, s.store, provider, order.OrderID, nil, "broken", s.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest.Lines) != 1 || rest.Lines[0].Quantity != 1 {
+		t.Errorf("refund of the rest = %+v, want the last kettle", rest.Lines)
+	}
+	if refunded += rest.Amount.Amount; refunded != 2999 {
+		t.Errorf("refunds add up to %d, want what was paid, 2999", refunded)
+	}
+
+	if _, err := RefundOrder(s.ctx, s.store, s.store, provider, order.OrderID, nil, "broken", s.userID); !errors.Is(err, ErrNothingToRefund) {
+		t.Errorf("refunding a fully refunded order: err = %v, want %v", err, ErrNothingToRefund)
+	}
+	if refunds := s.reload(order).Refunds; len(refunds) != 3 {
+		t.Errorf("order records %d refunds, want 3", len(refunds))
+	}
+}
+
+func TestRefundOrderRejects(t *testing.T) {
+	provider := payments.NewFakeProvider("secret", "")
+	tests := []struct {
+		name     string
+		order    func(s *shop, kettle primitive.ObjectID) models.Order
+		provider payments.Provider
+		lines    func(kettle primitive.ObjectID) []models.RefundLine
+		err      error
+	}{
+		{
+			"unpaid card order",
+			func(s *shop, kettle primitive.ObjectID) models.Order {
+				return s.order(kettle, 1, models.PaymentMethodCard)
+			},
+			provider, nil, ErrNotRefundable,
+		},
+		{
+			"undelivered cash on delivery order",
+			func(s *shop, kettle primitive.ObjectID) models.Order {
+				return s.order(kettle, 1, models.PaymentMethodCOD)
+			},
+			provider, nil, ErrNotRefundable,
+		},
+		{
+			"card order without its provider",
+			func(s *shop, kettle primitive.ObjectID) models.Order { return s.paidCardOrder(provider, kettle, 1) },
+			nil, nil, ErrNoProvider,
+		},
+		{
+			"line listed twice",
+			func(s *shop, kettle primitive.ObjectID) models.Order { return s.paidCardOrder(provider, kettle, 2) },
+			provider,
+			func(kettle primitive.ObjectID) []models.RefundLine {
+				return []models.RefundLine{{ProductID: kettle, Quantity: 1}, {ProductID: kettle, Quantity: 1}}
+			},
+			ErrInvalidRefund,
+		},
+		{
+			"product not on the order",
+			func(s *shop, kettle primitive.ObjectID) models.Order { return s.paidCardOrder(provider, kettle, 1) },
+			provider,
+			func(primitive.ObjectID) []models.RefundLine {
+				return []models.RefundLine{{ProductID: primitive.NewObjectID(), Quantity: 1}}
+			},
+			ErrInvalidRefund,
+		},
+		{
+			"more than was bought",
+			func(s *shop, kettle primitive.ObjectID) models.Order { return s.paidCardOrder(provider, kettle, 1) },
+			provider,
+			func(kettle primitive.ObjectID) []models.RefundLine {
+				return []models.RefundLine{{ProductID: kettle, Quantity: 2}}
+			},
+			ErrOverRefund,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			kettle := s.product("Kettle", 1000, 5)
+			order := tt.order(s, kettle)
+			var lines []models.RefundLine
+			if tt.lines != nil {
+				lines = tt.lines(kettle)
+			}
+
+			if _, err := RefundOrder(s.ctx, s.store, s.store, tt.provider, order.OrderID, lines, "broken", s.userID); !errors.Is(err, tt.err) {
+				t.Fatalf("RefundOrder() error = %v, want %v", err, tt.err)
+			}
+			if refunds := s.reload(order).Refunds; len(refunds) != 0 {
+				t.Errorf("order records %d refunds after a refused one", len(refunds))
+			}
+		})
+	}
+}
+
+func TestRefundCashOnDeliveryToLedger(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 1000, 5)
+	order := s.order(kettle, 2, models.PaymentMethodCOD)
+	s.deliver(order)
+
+	refund, err := RefundOrder(s.ctx, s.store, s.store, nil, order.OrderID, []models.RefundLine{{ProductID: kettle}}, "damaged", s.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Method != models.PaymentMethodCOD || refund.Amount.Amount != 2000 {
+		t.Errorf("refund = %d by %s, want 2000 paid out by hand", refund.Amount.Amount, refund.Method)
+	}
+
+	entries, err := s.store.ListLedgerEntries(s.ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].RefundID != refund.ID || entries[0].Amount != refund.Amount {
+		t.Errorf("ledger = %+v, want one entry for the refund", entries)
+	}
+}
@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReturnWindow is how long after delivery an order's lines can be returned.
+var ReturnWindow = 14 * 24 * time.Hour
+
+var (
+	ErrNotReturnable           = errors.New("only delivered orders can be returned")
+	ErrReturnWindowClosed      = errors.New("the return window for this order has closed")
+	ErrInvalidReturn           = errors.New("invalid return")
+	ErrOverReturn              = errors.New("that is more than is left to return on this line")
+	ErrIllegalReturnTransition = errors.New("return cannot move to that status")
+	ErrInvalidReturnStatus     = errors.New("unknown return status")
+)
+
+// RequestReturn opens a return for lines of one of the user's orders. The
+// order must have been delivered within ReturnWindow, and a line cannot be
+// returned more often than it was bought, counting what was refunded already
+// and what other open returns are sending back.
+func RequestReturn(ctx context.Context, orders OrderStore, returns ReturnStore, userID primitive.ObjectID, orderID primitive.ObjectID, lines []models.ReturnLine, comment string, photos []string) (models.Return, error) {
+	order, err := GetUserOrder(ctx, orders, userID, orderID)
+	if err != nil {
+		return models.Return{}, err
+	}
+	if order.Status != models.OrderDelivered {
+		return models.Return{}, ErrNotReturnable
+	}
+	deliveredAt, ok := deliveredAt(order)
+	if !ok || time.Since(deliveredAt) > ReturnWindow {
+		return models.Return{}, ErrReturnWindowClosed
+	}
+
+	existing, err := returns.ListReturnsByOrder(ctx, orderID)
+	if err != nil {
+		log.Println(err)
+		return models.Return{}, err
+	}
+	taken := refundedQuantities(order)
+	for _, ret := range existing {
+		// Completed returns are counted by their refunds.
+		if ret.Status == models.ReturnRejected || ret.Status == models.ReturnCompleted {
+			continue
+		}
+		for _, line := range ret.Lines {
+			taken[lineKey{line.ProductID, line.VariantSKU}] += line.Quantity
+		}
+	}
+
+	bought := map[lineKey]models.ProductUser{}
+	for _, item := range normalizeCart(order.OrderCart) {
+		bought[lineKey{item.ProductID, item.VariantSKU}] = item
+	}
+	seen := map[lineKey]bool{}
+	for _, line := range lines {
+		key := lineKey{line.ProductID, line.VariantSKU}
+		item, ok := bought[key]
+		switch {
+		case seen[key]:
+			return models.Return{}, fmt.Errorf("%w: product %s is listed twice", ErrInvalidReturn, line.ProductID.Hex())
+		case !ok:
+			return models.Return{}, fmt.Errorf("%w: the order has no line for product %s", ErrInvalidReturn, line.ProductID.Hex())
+		}
+		seen[key] = true
+
+		quantity := lineQuantity(item)
+		if left := quantity - min(taken[key], quantity); line.Quantity > left {
+			return models.Return{}, fmt.Errorf("%w: only %d of %s left", ErrOverReturn, left, item.ProductName)
+		}
+	}
+
+	now := time.Now()
+	ret := models.Return{
+		ID:        primitive.NewObjectID(),
+		OrderID:   orderID,
+		UserID:    userID,
+		Lines:     lines,
+		Comment:   comment,
+		Photos:    photos,
+		Status:    models.ReturnRequested,
+		History:   []models.ReturnChange{{To: models.ReturnRequested, At: now, By: userID}},
+		CreatedAt: now,
+	}
+	if err := returns.InsertReturn(ctx, ret); err != nil {
+		log.Println(err)
+		return models.Return{}, err
+	}
+	return ret, nil
+}
+
+// deliveredAt returns when the order was delivered.
+func deliveredAt(order models.Order) (time.Time, bool) {
+	for i := len(order.StatusHistory) - 1; i >= 0; i-- {
+		if order.StatusHistory[i].To == models.OrderDelivered {
+			return order.StatusHistory[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// GetUserReturns returns the returns of one of the user's orders.
+func GetUserReturns(ctx context.Context, orders OrderStore, returns ReturnStore, userID primitive.ObjectID, orderID primitive.ObjectID) ([]models.Return, error) {
+	if _, err := GetUserOrder(ctx, orders, userID, orderID); err != nil {
+		return nil, err
+	}
+	list, err := returns.ListReturnsByOrder(ctx, orderID)
+	if err != nil {
+		log.Println(err)
+	}
+	return list, err
+}
+
+// ReviewReturn approves or rejects a requested return.
+func ReviewReturn(ctx context.Context, returns ReturnStore, returnID primitive.ObjectID, approve bool, note string, by primitive.ObjectID) (models.Return, error) {
+	ret, err := returns.FindReturnByID(ctx, returnID)
+	if err != nil {
+		return models.Return{}, err
+	}
+	next := models.ReturnRejected
+	if approve {
+		next = models.ReturnApproved
+	}
+	return moveReturn(ctx, returns, ret, next, note, by)
+}
+
+// ReceiveReturn records that an approved return has arrived at the
+// warehouse, puts its units back in stock, and refunds them.
+func ReceiveReturn(ctx context.Context, returns ReturnStore, orders OrderStore, products ProductStore, inventory InventoryStore, ledger LedgerStore, provider payments.Provider, returnID primitive.ObjectID, note string, by primitive.ObjectID) (models.Return, error) {
+	ret, err := returns.FindReturnByID(ctx, returnID)
+	if err != nil {
+		return models.Return{}, err
+	}
+	// Moving the return first means its units are only restocked once.
+	if ret, err = moveReturn(ctx, returns, ret, models.ReturnReceived, note, by); err != nil {
+		return ret, err
+	}
+
+	for _, line := range ret.Lines {
+		_, err := AdjustStock(ctx, products, inventory, line.ProductID, line.VariantSKU, int64(line.Quantity), "returned", "return "+ret.ID.Hex())
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return completeReturn(ctx, returns, orders, ledger, provider, ret, by)
+}
+
+// CompleteReturn retries the refund of a received return whose refund
+// failed.
+func CompleteReturn(ctx context.Context, returns ReturnStore, orders OrderStore, ledger LedgerStore, provider payments.Provider, returnID primitive.ObjectID, by primitive.ObjectID) (models.Return, error) {
+	ret, err := returns.FindReturnByID(ctx, returnID)
+	if err != nil {
+		return models.Return{}, err
+	}
+	if ret.Status != models.ReturnReceived {
+		return ret, ErrIllegalReturnTransition
+	}
+	return completeReturn(ctx, returns, orders, ledger, provider, ret, by)
+}
+
+// completeReturn refunds a received return's lines and completes it. Each line
+// is refunded only as far as it has not been already, and a return with
+// nothing left to refund is completed without one. When the refund fails the
+// return stays received with the error noted. Once every line of the order has
+// been refunded the order moves to returned.
+func completeReturn(ctx context.Context, returns ReturnStore, orders OrderStore, ledger LedgerStore, provider payments.Provider, ret models.Return, by primitive.ObjectID) (models.Return, error) {
+	order, err := orders.FindOrderByID(ctx, ret.OrderID)
+	if err != nil {
+		return ret, err
+	}
+
+	// Units of a line may have been refunded since the return was approved,
+	// by an admin or a cancellation, so only what is still refundable is asked
+	// for; otherwise the refund would fail on every retry.
+	left := map[lineKey]uint64{}
+	refunded := refundedQuantities(order)
+	for _, item := range normalizeCart(order.OrderCart) {
+		key := lineKey{item.ProductID, item.VariantSKU}
+		left[key] = lineQuantity(item) - min(refunded[key], lineQuantity(item))
+	}
+	lines := make([]models.RefundLine, 0, len(ret.Lines))
+	for _, line := range ret.Lines {
+		if take := min(line.Quantity, left[lineKey{line.ProductID, line.VariantSKU}]); take > 0 {
+			lines = append(lines, models.RefundLine{ProductID: line.ProductID, VariantSKU: line.VariantSKU, Quantity: take})
+		}
+	}
+	if len(lines) == 0 {
+		ret.Error = ""
+		return moveReturn(ctx, returns, ret, models.ReturnCompleted, "already refunded", by)
+	}
+
+	refund, err := RefundOrder(ctx, orders, ledger, provider, ret.OrderID, lines, "return "+ret.ID.Hex(), by)
+	if err != nil {
+		ret.Error = err.Error()
+		if err := returns.UpdateReturn(ctx, models.ReturnReceived, ret); err != nil {
+			log.Println(err)
+		}
+		return ret, err
+	}
+
+	ret.RefundID, ret.Error = &refund.ID, ""
+	ret, err = moveReturn(ctx, returns, ret, models.ReturnCompleted, "refunded "+refund.Amount.String(), by)
+	if err != nil {
+		return ret, err
+	}
+
+	order, err = orders.FindOrderByID(ctx, ret.OrderID)
+	if err != nil {
+		log.Println(err)
+		return ret, nil
+	}
+	refunded = refundedQuantities(order)
+	for _, item := range normalizeCart(order.OrderCart) {
+		if refunded[lineKey{item.ProductID, item.VariantSKU}] < lineQuantity(item) {
+			return ret, nil
+		}
+	}
+	if _, err := UpdateOrderStatus(ctx, orders, ret.OrderID, models.OrderReturned, "all items returned"); err != nil && !errors.Is(err, ErrIllegalTransition) {
+		log.Println(err)
+	}
+	return ret, nil
+}
+
+// moveReturn moves ret to next if its lifecycle allows it and appends the
+// change to its history.
+func moveReturn(ctx context.Context, returns ReturnStore, ret models.Return, next models.ReturnStatus, note string, by primitive.ObjectID) (models.Return, error) {
+	if !ret.Status.CanTransitionTo(next) {
+		return ret, ErrIllegalReturnTransition
+	}
+	moved := ret
+	moved.Status = next
+	moved.History = append(ret.History, models.ReturnChange{From: ret.Status, To: next, At: time.Now(), By: by, Note: note})
+	if err := returns.UpdateReturn(ctx, ret.Status, moved); err != nil {
+		if !errors.Is(err, ErrReturnChanged) {
+			log.Println(err)
+		}
+		return ret, err
+	}
+	return moved, nil
+}
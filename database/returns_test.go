@@ -0,0 +1,208 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// returnOf asks to send back quantity of product from order.
+func (s *shop) returnOf(order models.Order, productID primitive.ObjectID, quantity uint64) (models.Return, error) {
+	lines := []models.ReturnLine{{ProductID: productID, Quantity: quantity, Reason: "damaged"}}
+	return RequestReturn(s.ctx, s.store, s.store, s.userID, order.OrderID, lines, "", nil)
+}
+
+// receive approves ret and receives it at the warehouse.
+func (s *shop) receive(ret models.Return) (models.Return, error) {
+	s.t.Helper()
+	if _, err := ReviewReturn(s.ctx, s.store, ret.ID, true, "", s.userID); err != nil {
+		s.t.Fatal(err)
+	}
+	return ReceiveReturn(s.ctx, s.store, s.store, s.store, s.store, s.store, nil, ret.ID, "", s.userID)
+}
+
+func TestReturnRestocksAndRefunds(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 1000, 5)
+	order := s.order(kettle, 3, models.PaymentMethodCOD)
+	s.deliver(order)
+
+	first, err := s.returnOf(order, kettle, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := s.receive(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Status != models.ReturnCompleted || received.RefundID == nil {
+		t.Fatalf("return is %s with refund %v, want it completed with a refund", received.Status, received.RefundID)
+	}
+	if stock := s.stock(kettle); stock != 4 {
+		t.Errorf("stock = %d after 2 kettles came back, want 4", stock)
+	}
+	stored := s.reload(order)
+	if len(stored.Refunds) != 1 || stored.Refunds[0].Amount.Amount != 2000 {
+		t.Errorf("order refunds = %+v, want 2000 for the returned kettles", stored.Refunds)
+	}
+	if stored.Status != models.OrderDelivered {
+		t.Errorf("order is %s with a kettle still kept, want %s", stored.Status, models.OrderDelivered)
+	}
+
+	last, err := s.returnOf(order, kettle, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.receive(last); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.reload(order).Status; status != models.OrderReturned {
+		t.Errorf("order is %s after every kettle came back, want %s", status, models.OrderReturned)
+	}
+}
+
+func TestRequestReturnRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(s *shop, order models.Order)
+		lines   func(kettle primitive.ObjectID) []models.ReturnLine
+		err     error
+	}{
+		{"not delivered", nil, nil, ErrNotReturnable},
+		{"window closed", func(s *shop, order models.Order) {
+			s.deliver(order)
+			ReturnWindow = -time.Second
+		}, nil, ErrReturnWindowClosed},
+		{"more than was bought", func(s *shop, order models.Order) { s.deliver(order) }, func(kettle primitive.ObjectID) []models.ReturnLine {
+			return []models.ReturnLine{{ProductID: kettle, Quantity: 3, Reason: "damaged"}}
+		}, ErrOverReturn},
+		{"more than other returns leave", func(s *shop, order models.Order) {
+			s.deliver(order)
+			if _, err := s.returnOf(order, order.OrderCart[0].ProductID, 1); err != nil {
+				s.t.Fatal(err)
+			}
+		}, nil, ErrOverReturn},
+		{"more than refunds leave", func(s *shop, order models.Order) {
+			s.deliver(order)
+			lines := []models.RefundLine{{ProductID: order.OrderCart[0].ProductID, Quantity: 1}}
+			if _, err := RefundOrder(s.ctx, s.store, s.store, nil, order.OrderID, lines, "damaged", s.userID); err != nil {
+				s.t.Fatal(err)
+			}
+		}, nil, ErrOverReturn},
+		{"line listed twice", func(s *shop, order models.Order) { s.deliver(order) }, func(kettle primitive.ObjectID) []models.ReturnLine {
+			line := models.ReturnLine{ProductID: kettle, Quantity: 1, Reason: "damaged"}
+			return []models.ReturnLine{line, line}
+		}, ErrInvalidReturn},
+		{"product not on the order", func(s *shop, order models.Order) { s.deliver(order) }, func(primitive.ObjectID) []models.ReturnLine {
+			return []models.ReturnLine{{ProductID: primitive.NewObjectID(), Quantity: 1, Reason: "damaged"}}
+		}, ErrInvalidReturn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(window time.Duration) { ReturnWindow = window }(ReturnWindow)
+			s := newShop(t)
+			kettle := s.product("Kettle", 1000, 5)
+			order := s.order(kettle, 2, models.PaymentMethodCOD)
+			if tt.prepare != nil {
+				tt.prepare(s, order)
+			}
+			lines := []models.ReturnLine{{ProductID: kettle, Quantity: 2, Reason: "damaged"}}
+			if tt.lines != nil {
+				lines = tt.lines(kettle)
+			}
+
+			if _, err := RequestReturn(s.ctx, s.store, s.store, s.userID, order.OrderID, lines, "", nil); !errors.Is(err, tt.err) {
+				t.Errorf("RequestReturn() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRequestReturnOfSomeoneElsesOrder(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 1000, 5)
+	order := s.order(kettle, 1, models.PaymentMethodCOD)
+	s.deliver(order)
+
+	lines := []models.ReturnLine{{ProductID: kettle, Quantity: 1, Reason: "damaged"}}
+	if _, err := RequestReturn(s.ctx, s.store, s.store, primitive.NewObjectID(), order.OrderID, lines, "", nil); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("RequestReturn() error = %v, want %v", err, ErrOrderNotFound)
+	}
+}
+
+func TestReceiveReturnCapsRefund(t *testing.T) {
+	tests := []struct {
+		name     string
+		refunded uint64
+		amount   int64
+		note     string
+	}{
+		{"part refunded since approval", 1, 1000, "refunded 10.00 INR"},
+		{"all refunded since approval", 2, 0, "already refunded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(t)
+			kettle := s.product("Kettle", 1000, 5)
+			order := s.order(kettle, 2, models.PaymentMethodCOD)
+			s.deliver(order)
+			ret, err := s.returnOf(order, kettle, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReviewReturn(s.ctx, s.store, ret.ID, true, "", s.userID); err != nil {
+				t.Fatal(err)
+			}
+			lines := []models.RefundLine{{ProductID: kettle, Quantity: tt.refunded}}
+			if _, err := RefundOrder(s.ctx, s.store, s.store, nil, order.OrderID, lines, "goodwill", s.userID); err != nil {
+				t.Fatal(err)
+			}
+
+			received, err := ReceiveReturn(s.ctx, s.store, s.store, s.store, s.store, s.store, nil, ret.ID, "", s.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if received.Status != models.ReturnCompleted {
+				t.Errorf("return is %s, want %s", received.Status, models.ReturnCompleted)
+			}
+			if note := received.History[len(received.History)-1].Note; note != tt.note {
+				t.Errorf("return completed with %q, want %q", note, tt.note)
+			}
+			if stock := s.stock(kettle); stock != 5 {
+				t.Errorf("stock = %d after both kettles came back, want 5", stock)
+			}
+
+			var total int64
+			for _, refund := range s.reload(order).Refunds {
+				total += refund.Amount.Amount
+			}
+			if total != 2000 {
+				t.Errorf("refunds add up to %d, want what was paid, 2000", total)
+			}
+		})
+	}
+}
+
+func TestReceiveRejectedReturn(t *testing.T) {
+	s := newShop(t)
+	kettle := s.product("Kettle", 1000, 5)
+	order := s.order(kettle, 1, models.PaymentMethodCOD)
+	s.deliver(order)
+	ret, err := s.returnOf(order, kettle, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReviewReturn(s.ctx, s.store, ret.ID, false, "used", s.userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReceiveReturn(s.ctx, s.store, s.store, s.store, s.store, s.store, nil, ret.ID, "", s.userID); !errors.Is(err, ErrIllegalReturnTransition) {
+		t.Errorf("receiving a rejected return: err = %v, want %v", err, ErrIllegalReturnTransition)
+	}
+	if stock := s.stock(kettle); stock != 4 {
+		t.Errorf("stock = %d after a rejected return, want 4", stock)
+	}
+}
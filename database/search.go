@@ -0,0 +1,337 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+var ErrInvalidSynonym = errors.New("synonyms must be at least two distinct single words")
+
+// MaxSearchLength caps the search and autocomplete input so a single request
+// cannot hand the database an arbitrarily large query.
+const MaxSearchLength = 100
+
+// Relevance weights of the product text index. A hit in the name counts more
+// than one in the tags, which counts more than one in the description.
+var searchWeights = map[string]int32{
+	"product_name": 10,
+	"tags":         5,
+	"description":  1,
+}
+
+// searchTerms splits text into the lowercase words a text search looks for.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// textScore approximates the Mongo text score for the memory store: every
+// search term found in a field adds that field's weight. Unlike Mongo it does
+// no stemming, so "mugs" does not find "mug".
+func textScore(product models.Product, terms []string) int32 {
+	fields := map[string][]string{
+		"product_name": searchTerms(product.ProductName),
+		"tags":         searchTerms(strings.Join(product.Tags, " ")),
+		"description":  searchTerms(product.Description),
+	}
+
+	var score int32
+	for _, term := range terms {
+		for field, words := range fields {
+			for _, word := range words {
+				if word == term {
+					score += searchWeights[field]
+					break
+				}
+			}
+		}
+	}
+	return score
+}
+
+// suggestionPattern matches names that contain a word starting with prefix.
+// The prefix is escaped, so it is always matched literally.
+func suggestionPattern(prefix string) string {
+	return `(^|\s)` + regexp.QuoteMeta(prefix)
+}
+
+// suggestions returns the distinct names of products, in order, up to limit.
+func suggestions(products []models.Product, limit int64) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, product := range products {
+		if seen[strings.ToLower(product.ProductName)] {
+			continue
+		}
+		seen[strings.ToLower(product.ProductName)] = true
+		names = append(names, product.ProductName)
+		if limit > 0 && int64(len(names)) == limit {
+			break
+		}
+	}
+	return names
+}
+
+// NormalizeTags lowercases and trims tags and drops empty and repeated ones.
+func NormalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// NormalizeSynonymTerms lowercases the terms of a synonym group and drops
+// repeated ones. Every term must be a single word, since search matches
+// word by word.
+func NormalizeSynonymTerms(terms []string) ([]string, error) {
+	normalized := NormalizeTags(terms)
+	for _, term := range normalized {
+		if words := searchTerms(term); len(words) != 1 || words[0] != term {
+			return nil, ErrInvalidSynonym
+		}
+	}
+	if len(normalized) < 2 {
+		return nil, ErrInvalidSynonym
+	}
+	return normalized, nil
+}
+
+// VocabularyTTL is how long a Searcher trusts its cached catalog words and
+// synonyms before loading them again.
+var VocabularyTTL = 5 * time.Minute
+
+// SearchResult is one page of a product search.
+type SearchResult struct {
+	Products []models.Product
+	Total    int64
+	Facets   Facets
+	// DidYouMean is the corrected query the results were found for when the
+	// query as typed found nothing.
+	DidYouMean string
+}
+
+// Searcher adds synonyms and typo tolerance on top of the text search of a
+// ProductStore. The words used in product names and tags, together with the
+// synonym groups, are cached in process for VocabularyTTL; call Invalidate
+// after changing synonyms to pick them up at once.
+type Searcher struct {
+	products ProductStore
+	synonyms SynonymStore
+
+	mu       sync.Mutex
+	loadedAt time.Time
+	// generation counts invalidations, so that a load that raced with one
+	// does not cache what it read.
+	generation  int
+	vocabulary  []string
+	known       map[string]bool
+	equivalents map[string][]string
+}
+
+func NewSearcher(products ProductStore, synonyms SynonymStore) *Searcher {
+	return &Searcher{products: products, synonyms: synonyms}
+}
+
+// Invalidate drops the cached vocabulary and synonyms.
+func (s *Searcher) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+	s.generation++
+}
+
+// Search runs query.Text with every term widened to its synonyms. When that
+// finds nothing, misspelt terms are replaced by the closest catalog word and
+// the search is run again; the corrected text is returned as DidYouMean.
+func (s *Searcher) Search(ctx context.Context, query ProductQuery) (SearchResult, error) {
+	terms := searchTerms(query.Text)
+	if len(terms) == 0 {
+		return SearchResult{Products: []models.Product{}, Facets: countFacets(nil)}, nil
+	}
+
+	known, equivalents, vocabulary, err := s.load(ctx)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	query.Text = strings.Join(expandSynonyms(terms, equivalents), " ")
+	result, err := s.find(ctx, query)
+	if err != nil || result.Total > 0 {
+		return result, err
+	}
+
+	corrected := correctTerms(terms, known, vocabulary)
+	if corrected == nil {
+		return result, nil
+	}
+	query.Text = strings.Join(expandSynonyms(corrected, equivalents), " ")
+	result, err = s.find(ctx, query)
+	result.DidYouMean = strings.Join(corrected, " ")
+	return result, err
+}
+
+func (s *Searcher) find(ctx context.Context, query ProductQuery) (SearchResult, error) {
+	products, total, err := s.products.FindProducts(ctx, query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	facets, err := s.products.ProductFacets(ctx, query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return SearchResult{Products: products, Total: total, Facets: facets}, nil
+}
+
+// load returns the cached vocabulary and synonyms, reading them again once
+// they are older than VocabularyTTL. The reading happens outside the lock,
+// so searches are not held up by it.
+func (s *Searcher) load(ctx context.Context) (map[string]bool, map[string][]string, []string, error) {
+	s.mu.Lock()
+	if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < VocabularyTTL {
+		defer s.mu.Unlock()
+		return s.known, s.equivalents, s.vocabulary, nil
+	}
+	generation := s.generation
+	s.mu.Unlock()
+
+	catalog, err := s.products.CatalogText(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, nil, err
+	}
+	groups, err := s.synonyms.ListSynonymGroups(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil, nil, nil, err
+	}
+
+	known := map[string]bool{}
+	for _, text := range catalog {
+		for _, word := range searchTerms(text) {
+			known[word] = true
+		}
+	}
+	equivalents := map[string][]string{}
+	for _, group := range groups {
+		for _, term := range group.Terms {
+			known[term] = true
+			for _, other := range group.Terms {
+				if other != term {
+					equivalents[term] = append(equivalents[term], other)
+				}
+			}
+		}
+	}
+	vocabulary := make([]string, 0, len(known))
+	for word := range known {
+		vocabulary = append(vocabulary, word)
+	}
+	sort.Strings(vocabulary)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation == generation {
+		s.known, s.equivalents, s.vocabulary = known, equivalents, vocabulary
+		s.loadedAt = time.Now()
+	}
+	return known, equivalents, vocabulary, nil
+}
+
+func expandSynonyms(terms []string, equivalents map[string][]string) []string {
+	seen := map[string]bool{}
+	expanded := []string{}
+	for _, term := range terms {
+		for _, word := range append([]string{term}, equivalents[term]...) {
+			if !seen[word] {
+				seen[word] = true
+				expanded = append(expanded, word)
+			}
+		}
+	}
+	return expanded
+}
+
+// correctTerms replaces every term that is not a known word with the closest
+// known word within typoTolerance. It returns nil when nothing was replaced.
+func correctTerms(terms []string, known map[string]bool, vocabulary []string) []string {
+	corrected := make([]string, len(terms))
+	changed := false
+	for i, term := range terms {
+		corrected[i] = term
+		if known[term] {
+			continue
+		}
+		best, bestDistance := "", typoTolerance(term)+1
+		for _, word := range vocabulary {
+			if distance := editDistance(term, word); distance < bestDistance {
+				best, bestDistance = word, distance
+			}
+		}
+		if best != "" {
+			corrected[i] = best
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return corrected
+}
+
+// typoTolerance is how many edits a term of this length may be away from a
+// catalog word and still count as a misspelling of it.
+func typoTolerance(term string) int {
+	switch length := len([]rune(term)); {
+	case length < 3:
+		return 0
+	case length <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// adjacent letters that turn a into b, so "iphnoe" is one edit from "iphone".
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// shop is a memory store with one customer, for tests that drive the
+// database functions the way the handlers do.
+type shop struct {
+	t      *testing.T
+	ctx    context.Context
+	store  *MemoryStore
+	userID primitive.ObjectID
+}
+
+func newShop(t *testing.T) *shop {
+	t.Helper()
+	s := &shop{t: t, ctx: context.Background(), store: NewMemoryStore(), userID: primitive.NewObjectID()}
+	user := models.User{ID: s.userID, FirstName: "Asha", LastName: "Rao", Email: "asha@example.com", Phone: "9999999999"}
+	if err := s.store.InsertUser(s.ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// product adds a product at price paise with stock units in stock. edit, if
+// given, can set anything else about it first.
+func (s *shop) product(name string, price int64, stock uint64, edit ...func(*models.Product)) primitive.ObjectID {
+	s.t.Helper()
+	product := models.Product{
+		ProductID:   primitive.NewObjectID(),
+		ProductName: name,
+		Price:       models.NewMoney(price, models.BaseCurrency),
+		Stock:       stock,
+	}
+	for _, edit := range edit {
+		edit(&product)
+	}
+	id, err := s.store.InsertProduct(s.ctx, product)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return id
+}
+
+// stock returns how many units of product are left.
+func (s *shop) stock(productID primitive.ObjectID) uint64 {
+	s.t.Helper()
+	product, err := s.store.FindProductByID(s.ctx, productID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return product.Stock
+}
+
+// addToCart puts quantity of product in the customer's cart.
+func (s *shop) addToCart(productID primitive.ObjectID, quantity uint64) {
+	s.t.Helper()
+	if err := AddProductToCart(s.ctx, s.store, s.store, productID, "", s.userID.Hex(), quantity); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// cartQuantity returns how many units of product are in the customer's
+// cart.
+func (s *shop) cartQuantity(productID primitive.ObjectID) uint64 {
+	s.t.Helper()
+	user, err := s.store.FindUserByID(s.ctx, s.userID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for _, item := range normalizeCart(user.UserCart) {
+		if item.ProductID == productID {
+			return item.Quantity
+		}
+	}
+	return 0
+}
+
+// checkout places an order for the customer's cart, paid by paymentMethod.
+func (s *shop) checkout(paymentMethod string) (models.Order, error) {
+	return BuyItemFromCart(s.ctx, s.store, s.store, s.store, s.store, s.store, s.store, s.store, s.store, baseRate(), paymentMethod, s.userID.Hex(), "")
+}
+
+// order places an order for quantity of product paid by paymentMethod,
+// failing the test if it cannot be placed.
+func (s *shop) order(productID primitive.ObjectID, quantity uint64, paymentMethod string) models.Order {
+	s.t.Helper()
+	s.addToCart(productID, quantity)
+	order, err := s.checkout(paymentMethod)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return order
+}
+
+// reload reads order back from the store.
+func (s *shop) reload(order models.Order) models.Order {
+	s.t.Helper()
+	stored, err := s.store.FindOrderByID(s.ctx, order.OrderID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return stored
+}
+
+// deliver moves order through packing and shipping to delivered.
+func (s *shop) deliver(order models.Order) {
+	s.t.Helper()
+	for _, next := range []models.OrderStatus{models.OrderPacked, models.OrderShipped, models.OrderDelivered} {
+		if _, err := UpdateOrderStatus(s.ctx, s.store, order.OrderID, next, ""); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+}
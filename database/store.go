@@ -0,0 +1,349 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrProductExists       = errors.New("product already exists")
+	ErrCartItemNotFound    = errors.New("this product is not in the cart")
+	ErrInsufficientStock   = errors.New("not enough stock")
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderStatusChanged  = errors.New("order status was changed by someone else")
+	ErrAddressNotFound     = errors.New("address not found")
+	ErrProductUnavailable  = errors.New("a product in the cart is no longer available")
+	ErrSynonymNotFound     = errors.New("synonym group not found")
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryExists      = errors.New("a category with this slug already exists")
+	ErrVariantNotFound     = errors.New("variant not found")
+	ErrSKUExists           = errors.New("a variant with this sku already exists")
+	ErrCouponNotFound      = errors.New("coupon not found")
+	ErrCouponExists        = errors.New("a coupon with this code already exists")
+	ErrCouponExhausted     = errors.New("coupon has reached its usage limit")
+	ErrCouponUsedUp        = errors.New("you have already used this coupon as often as allowed")
+	ErrPromotionNotFound   = errors.New("promotion not found")
+	ErrRateNotFound        = errors.New("no exchange rate for this currency")
+	ErrTaxRuleNotFound     = errors.New("tax rule not found")
+	ErrPaymentChanged      = errors.New("order payment was changed by someone else")
+	ErrEventExists         = errors.New("this payment event was already received")
+	ErrEventNotFound       = errors.New("payment event not found")
+	ErrRefundConflict      = errors.New("the order was refunded by someone else at the same time, please try again")
+	ErrReturnNotFound      = errors.New("return not found")
+	ErrReturnChanged       = errors.New("the return was changed by someone else")
+)
+
+// UserStore persists users together with their embedded cart and addresses.
+type UserStore interface {
+	FindUserByID(ctx context.Context, userID primitive.ObjectID) (models.User, error)
+	FindUserByEmail(ctx context.Context, email string) (models.User, error)
+	CountUsersByEmail(ctx context.Context, email string) (int64, error)
+	CountUsersByPhone(ctx context.Context, phone string) (int64, error)
+	InsertUser(ctx context.Context, user models.User) error
+	UpdateRefreshToken(ctx context.Context, userID primitive.ObjectID, refreshToken string, family string) error
+	// RotateRefreshToken replaces oldToken with newToken and reports false
+	// when oldToken is no longer the user's current refresh token.
+	RotateRefreshToken(ctx context.Context, userID primitive.ObjectID, oldToken string, newToken string) (bool, error)
+	RevokeRefreshTokens(ctx context.Context, userID primitive.ObjectID) error
+	UpdateUserRole(ctx context.Context, userID primitive.ObjectID, role string) error
+
+	// AddCartItem adds item.Quantity to the existing cart line for the
+	// product and variant, or appends item as a new line when there is none.
+	// Cart lines are always keyed by product ID and variant SKU together; the
+	// SKU is empty for products without variants.
+	AddCartItem(ctx context.Context, userID primitive.ObjectID, item models.ProductUser) error
+	// SetCartItemQuantity overwrites the quantity of an existing cart line.
+	SetCartItemQuantity(ctx context.Context, userID primitive.ObjectID, productID primitive.ObjectID, sku string, quantity uint64) error
+	PullCartItem(ctx context.Context, userID primitive.ObjectID, productID primitive.ObjectID, sku string) error
+	// SetCartCoupon applies a coupon code to the cart; an empty code removes it.
+	SetCartCoupon(ctx context.Context, userID primitive.ObjectID, code string) error
+	// ClearCart empties the cart and drops its coupon.
+	ClearCart(ctx context.Context, userID primitive.ObjectID) error
+
+	AddAddress(ctx context.Context, userID primitive.ObjectID, address models.Address) error
+	UpdateAddress(ctx context.Context, userID primitive.ObjectID, index int, address models.Address) error
+	ClearAddresses(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// ProductStore persists the product catalog.
+type ProductStore interface {
+	FindProductByID(ctx context.Context, productID primitive.ObjectID) (models.Product, error)
+	InsertProduct(ctx context.Context, product models.Product) (primitive.ObjectID, error)
+	// FindProducts returns one page of the products matching query together
+	// with the number of matching products overall.
+	FindProducts(ctx context.Context, query ProductQuery) ([]models.Product, int64, error)
+	// ProductFacets counts every product matching query, ignoring Skip,
+	// Limit and Sort, by category, brand, price bucket and rating.
+	ProductFacets(ctx context.Context, query ProductQuery) (Facets, error)
+	// SuggestProductNames returns up to limit names of live products with a
+	// word starting with prefix, best rated first.
+	SuggestProductNames(ctx context.Context, prefix string, limit int64) ([]string, error)
+	// CatalogText returns the distinct names and tags of live products, the
+	// words search corrects typos against.
+	CatalogText(ctx context.Context) ([]string, error)
+	UpdateProduct(ctx context.Context, productID primitive.ObjectID, update models.ProductUpdate) (models.Product, error)
+	SoftDeleteProduct(ctx context.Context, productID primitive.ObjectID) error
+	// SaveVariant adds variant to the product, or updates the options, price
+	// and image of the variant with the same SKU. The stock of an existing
+	// variant is left alone; it only changes through stock adjustments.
+	SaveVariant(ctx context.Context, productID primitive.ObjectID, variant models.Variant) (models.Product, error)
+	RemoveVariant(ctx context.Context, productID primitive.ObjectID, sku string) error
+
+	// DecrementStock removes quantity units from the stock of the product, or
+	// of its variant when sku is set, in a single atomic step, failing with
+	// ErrInsufficientStock rather than going below zero.
+	DecrementStock(ctx context.Context, productID primitive.ObjectID, sku string, quantity uint64) error
+	IncrementStock(ctx context.Context, productID primitive.ObjectID, sku string, quantity uint64) error
+}
+
+// InventoryStore persists checkout reservations and the stock adjustment log.
+type InventoryStore interface {
+	InsertReservation(ctx context.Context, reservation models.Reservation) error
+	FindReservationByUser(ctx context.Context, userID primitive.ObjectID) (models.Reservation, error)
+	FindExpiredReservations(ctx context.Context, now time.Time) ([]models.Reservation, error)
+	// DeleteReservation reports whether this call removed the reservation, so
+	// only one caller ever hands its stock back.
+	DeleteReservation(ctx context.Context, reservationID primitive.ObjectID) (bool, error)
+
+	InsertStockAdjustment(ctx context.Context, adjustment models.StockAdjustment) error
+}
+
+// OrderStore persists placed orders in their own collection.
+type OrderStore interface {
+	InsertOrder(ctx context.Context, order models.Order) error
+	FindOrderByID(ctx context.Context, orderID primitive.ObjectID) (models.Order, error)
+	// ListOrdersByUser returns one page of the user's orders, newest first,
+	// together with the number of orders matching the filter overall.
+	ListOrdersByUser(ctx context.Context, userID primitive.ObjectID, filter OrderFilter) ([]models.Order, int64, error)
+	// FindUnpaidOrders returns the pending card orders placed before
+	// placedBefore whose payment has not been attempted or did not go
+	// through.
+	FindUnpaidOrders(ctx context.Context, placedBefore time.Time) ([]models.Order, error)
+	// UpdateOrderStatus records change only while the order is still in
+	// change.From, failing with ErrOrderStatusChanged otherwise.
+	UpdateOrderStatus(ctx context.Context, orderID primitive.ObjectID, change models.StatusChange) error
+	// UpdateOrderPayment records payment only while the order's payment is
+	// still in status from, failing with ErrPaymentChanged otherwise.
+	UpdateOrderPayment(ctx context.Context, orderID primitive.ObjectID, from models.PaymentStatus, payment models.Payment) error
+	// AddOrderRefund appends refund to the order's refunds only while it
+	// still has known of them, failing with ErrRefundConflict otherwise.
+	AddOrderRefund(ctx context.Context, orderID primitive.ObjectID, known int, refund models.Refund) error
+	// UpdateOrderRefund replaces the order's refund with the same ID.
+	UpdateOrderRefund(ctx context.Context, orderID primitive.ObjectID, refund models.Refund) error
+}
+
+// AuditStore persists the audit trail of actions taken on behalf of users.
+type AuditStore interface {
+	InsertAuditEntry(ctx context.Context, entry models.AuditEntry) error
+}
+
+const (
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortRating    = "rating"
+	SortNewest    = "newest"
+	// SortRelevance orders a text search by how well products match; it is
+	// the default whenever ProductQuery.Text is set.
+	SortRelevance = "relevance"
+)
+
+func ValidProductSort(sort string) bool {
+	switch sort {
+	case "", SortPriceAsc, SortPriceDesc, SortRating, SortNewest, SortRelevance:
+		return true
+	}
+	return false
+}
+
+// ProductQuery narrows and orders a product listing. Zero values mean "no
+// restriction"; deleted products are left out unless IncludeDeleted is set.
+// Text is a full-text search over name, tags and description. A product
+// matches Categories when it is in any of them, and Attributes when it has
+// every one of the listed values. MinPrice and MaxPrice are in minor units of
+// models.BaseCurrency.
+type ProductQuery struct {
+	Text           string
+	Categories     []string
+	Brand          string
+	Attributes     map[string]string
+	MinPrice       uint64
+	MaxPrice       uint64
+	MinRating      uint8
+	Sort           string
+	IncludeDeleted bool
+	Skip           int64
+	Limit          int64
+}
+
+func (q ProductQuery) matches(product models.Product) bool {
+	if product.Deleted && !q.IncludeDeleted {
+		return false
+	}
+	if price := uint64(product.Price.Amount); price < q.MinPrice || (q.MaxPrice > 0 && price > q.MaxPrice) {
+		return false
+	}
+	if q.Brand != "" && product.Brand != q.Brand {
+		return false
+	}
+	if len(q.Categories) > 0 && !inAny(product.Categories, q.Categories) {
+		return false
+	}
+	for name, value := range q.Attributes {
+		if product.Attributes[name] != value {
+			return false
+		}
+	}
+	return product.Rating >= q.MinRating
+}
+
+func inAny(values []string, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OrderFilter narrows an order listing. Zero values mean "no restriction";
+// To is exclusive.
+type OrderFilter struct {
+	Status models.OrderStatus
+	From   time.Time
+	To     time.Time
+	Skip   int64
+	Limit  int64
+}
+
+func (f OrderFilter) matches(order models.Order) bool {
+	if f.Status != "" && order.Status != f.Status {
+		return false
+	}
+	if !f.From.IsZero() && order.OrderedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !order.OrderedAt.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// SynonymStore persists the admin-managed synonym groups used by search.
+type SynonymStore interface {
+	ListSynonymGroups(ctx context.Context) ([]models.SynonymGroup, error)
+	// SaveSynonymGroup inserts group, or replaces the group with the same ID.
+	SaveSynonymGroup(ctx context.Context, group models.SynonymGroup) error
+	DeleteSynonymGroup(ctx context.Context, groupID primitive.ObjectID) error
+}
+
+// CategoryStore persists the category tree. The tree rules themselves, such
+// as parents having to exist, live in categories.go.
+type CategoryStore interface {
+	ListCategories(ctx context.Context) ([]models.Category, error)
+	// InsertCategory fails with ErrCategoryExists when the slug is taken.
+	InsertCategory(ctx context.Context, category models.Category) error
+	UpdateCategory(ctx context.Context, slug string, update models.CategoryUpdate) (models.Category, error)
+	DeleteCategory(ctx context.Context, slug string) error
+}
+
+// CouponStore persists coupons and how often each user has redeemed them.
+type CouponStore interface {
+	InsertCoupon(ctx context.Context, coupon models.Coupon) error
+	FindCouponByCode(ctx context.Context, code string) (models.Coupon, error)
+	ListCoupons(ctx context.Context) ([]models.Coupon, error)
+	DeleteCoupon(ctx context.Context, code string) error
+	CountCouponUses(ctx context.Context, code string, userID primitive.ObjectID) (uint64, error)
+	// RedeemCoupon counts one use of coupon by userID in a single atomic
+	// step, failing with ErrCouponExhausted or ErrCouponUsedUp rather than
+	// going over the global or the per-user limit.
+	RedeemCoupon(ctx context.Context, coupon models.Coupon, userID primitive.ObjectID) error
+	// ReleaseCoupon gives back one use taken by RedeemCoupon.
+	ReleaseCoupon(ctx context.Context, code string, userID primitive.ObjectID) error
+}
+
+// PromotionStore persists the automatic promotions evaluated against carts.
+type PromotionStore interface {
+	InsertPromotion(ctx context.Context, promotion models.Promotion) error
+	// ListPromotions returns every promotion, oldest first, which is also the
+	// order they are applied in.
+	ListPromotions(ctx context.Context) ([]models.Promotion, error)
+	DeletePromotion(ctx context.Context, promotionID primitive.ObjectID) error
+}
+
+// ExchangeRateStore persists the admin-maintained exchange rates, one per
+// currency.
+type ExchangeRateStore interface {
+	FindExchangeRate(ctx context.Context, currency string) (models.ExchangeRate, error)
+	ListExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	// SaveExchangeRate inserts rate, or replaces the rate for the same currency.
+	SaveExchangeRate(ctx context.Context, rate models.ExchangeRate) error
+	DeleteExchangeRate(ctx context.Context, currency string) error
+}
+
+// TaxStore persists the tax rules orders are taxed by.
+type TaxStore interface {
+	InsertTaxRule(ctx context.Context, rule models.TaxRule) error
+	// ListTaxRules returns every rule, oldest first.
+	ListTaxRules(ctx context.Context) ([]models.TaxRule, error)
+	DeleteTaxRule(ctx context.Context, ruleID primitive.ObjectID) error
+}
+
+// PaymentEventStore persists the notifications received from payment
+// providers.
+type PaymentEventStore interface {
+	// InsertPaymentEvent fails with ErrEventExists when an event with the same
+	// ID was inserted before.
+	InsertPaymentEvent(ctx context.Context, event models.PaymentEvent) error
+	FindPaymentEvent(ctx context.Context, id string) (models.PaymentEvent, error)
+	// ListPaymentEvents returns up to limit events, newest first.
+	ListPaymentEvents(ctx context.Context, limit int64) ([]models.PaymentEvent, error)
+	// UpdatePaymentEvent records how the event was handled.
+	UpdatePaymentEvent(ctx context.Context, event models.PaymentEvent) error
+}
+
+// LedgerStore persists the refunds owed on cash on delivery orders.
+type LedgerStore interface {
+	InsertLedgerEntry(ctx context.Context, entry models.LedgerEntry) error
+	// ListLedgerEntries returns up to limit entries, newest first.
+	ListLedgerEntries(ctx context.Context, limit int64) ([]models.LedgerEntry, error)
+}
+
+// ReturnStore persists customers' return requests.
+type ReturnStore interface {
+	InsertReturn(ctx context.Context, ret models.Return) error
+	FindReturnByID(ctx context.Context, returnID primitive.ObjectID) (models.Return, error)
+	// ListReturnsByOrder returns the order's returns, oldest first.
+	ListReturnsByOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Return, error)
+	// ListReturns returns up to limit returns in status, or in any status
+	// when it is empty, newest first.
+	ListReturns(ctx context.Context, status models.ReturnStatus, limit int64) ([]models.Return, error)
+	// UpdateReturn replaces the return only while it is still in status from,
+	// failing with ErrReturnChanged otherwise.
+	UpdateReturn(ctx context.Context, from models.ReturnStatus, ret models.Return) error
+}
+
+// Store is the full storage backend the application runs against.
+type Store interface {
+	UserStore
+	ProductStore
+	OrderStore
+	InventoryStore
+	AuditStore
+	SynonymStore
+	CategoryStore
+	CouponStore
+	PromotionStore
+	ExchangeRateStore
+	TaxStore
+	PaymentEventStore
+	LedgerStore
+	ReturnStore
+}
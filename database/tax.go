@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PricesIncludeTax says catalog prices already include tax, which is then
+// only broken out on the order. Otherwise tax is added on top at checkout.
+var PricesIncludeTax bool
+
+func CreateTaxRule(ctx context.Context, taxes TaxStore, rule models.TaxRule) (models.TaxRule, error) {
+	rule.TaxClass = strings.TrimSpace(rule.TaxClass)
+	for i := range rule.States {
+		rule.States[i] = strings.TrimSpace(rule.States[i])
+	}
+
+	rule.ID = primitive.NewObjectID()
+	rule.CreatedAt = time.Now()
+	if err := taxes.InsertTaxRule(ctx, rule); err != nil {
+		log.Println(err)
+		return models.TaxRule{}, err
+	}
+	return rule, nil
+}
+
+// taxRuleFor picks the rule for a line of taxClass shipped to address. The
+// most specific match wins: the longest matching pin code prefix, then a
+// matching state, then a rule that applies everywhere. Earlier rules win
+// ties.
+func taxRuleFor(rules []models.TaxRule, taxClass string, address models.Address) (models.TaxRule, bool) {
+	var found models.TaxRule
+	best := -1
+	for _, rule := range rules {
+		if !strings.EqualFold(rule.TaxClass, taxClass) {
+			continue
+		}
+
+		score := -1
+		if len(rule.States) == 0 && len(rule.PincodePrefixes) == 0 {
+			score = 0
+		}
+		for _, state := range rule.States {
+			if address.State != "" && strings.EqualFold(state, strings.TrimSpace(address.State)) {
+				score = max(score, 1)
+			}
+		}
+		for _, prefix := range rule.PincodePrefixes {
+			if strings.HasPrefix(strings.TrimSpace(address.Pincode), prefix) {
+				score = max(score, 1+len(prefix))
+			}
+		}
+
+		if score > best {
+			found, best = rule, score
+		}
+	}
+	return found, best >= 0
+}
+
+// taxCart works out the tax on cart shipped to address, one line per cart
+// line. discount is shared out over the lines in proportion to their value
+// and is not taxed. In tax-inclusive mode the tax is the part of each line's
+// price that is tax; otherwise it comes on top of it.
+func taxCart(ctx context.Context, taxes TaxStore, address models.Address, cart []models.ProductUser, discount models.Money) ([]models.TaxLine, models.Money, error) {
+	rules, err := taxes.ListTaxRules(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil, models.Money{}, ErrCantGetItem
+	}
+
+	subtotal, err := CartTotal(cart)
+	if err != nil {
+		return nil, models.Money{}, err
+	}
+	if _, err := subtotal.Sub(discount); err != nil {
+		return nil, models.Money{}, err
+	}
+
+	// Each line's share of the discount is the difference between the shares
+	// of the running totals up to and including it, so the shares add up to
+	// the discount exactly.
+	share := func(running int64) int64 {
+		return proportion(discount.Amount, running, subtotal.Amount)
+	}
+
+	lines := []models.TaxLine{}
+	tax := models.NewMoney(0, subtotal.Currency)
+	var running int64
+	for _, item := range cart {
+		price, err := item.Price.Times(lineQuantity(item))
+		if err != nil {
+			return nil, models.Money{}, err
+		}
+		value := price.Amount
+		taxable := value - (share(running+value) - share(running))
+		running += value
+
+		line := models.TaxLine{
+			ProductID:  item.ProductID,
+			VariantSKU: item.VariantSKU,
+			TaxClass:   item.TaxClass,
+			Taxable:    models.NewMoney(taxable, subtotal.Currency),
+			Tax:        models.NewMoney(0, subtotal.Currency),
+		}
+		if rule, ok := taxRuleFor(rules, item.TaxClass, address); ok {
+			line.Rule = rule.Name
+			line.Rate = rule.Rate
+			line.Tax.Amount = lineTax(taxable, rule.Rate)
+		}
+		lines = append(lines, line)
+		tax.Amount += line.Tax.Amount
+	}
+	return lines, tax, nil
+}
+
+// proportion returns amount × part / whole, rounded down, without
+// overflowing on large amounts.
+func proportion(amount, part, whole int64) int64 {
+	if whole == 0 {
+		return 0
+	}
+	value := new(big.Int).Mul(big.NewInt(amount), big.NewInt(part))
+	return value.Quo(value, big.NewInt(whole)).Int64()
+}
+
+// lineTax is the tax at rate basis points on amount, rounded to the nearest
+// minor unit.
+func lineTax(amount int64, rate uint64) int64 {
+	basis := int64(rate)
+	if PricesIncludeTax {
+		return amount - (amount*10000+(10000+basis)/2)/(10000+basis)
+	}
+	return (amount*basis + 5000) / 10000
+}
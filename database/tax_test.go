@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTaxRuleFor(t *testing.T) {
+	rules := []models.TaxRule{
+		{Name: "everywhere", Rate: 1800},
+		{Name: "everywhere again", Rate: 500},
+		{Name: "maharashtra", States: []string{"Maharashtra"}, Rate: 1200},
+		{Name: "mumbai", PincodePrefixes: []string{"400"}, Rate: 1000},
+		{Name: "south mumbai", PincodePrefixes: []string{"4000"}, Rate: 800},
+		{Name: "books", TaxClass: "books", Rate: 0},
+		{Name: "books in maharashtra", TaxClass: "Books", States: []string{"maharashtra"}, Rate: 300},
+	}
+	tests := []struct {
+		name     string
+		taxClass string
+		address  models.Address
+		want     string
+	}{
+		{"no address", "", models.Address{}, "everywhere"},
+		{"other state", "", models.Address{State: "Kerala", Pincode: "682001"}, "everywhere"},
+		{"state", "", models.Address{State: " maharashtra ", Pincode: "411001"}, "maharashtra"},
+		{"pin code beats state", "", models.Address{State: "Maharashtra", Pincode: "400701"}, "mumbai"},
+		{"longer pin code prefix", "", models.Address{State: "Maharashtra", Pincode: "400001"}, "south mumbai"},
+		{"tax class", "books", models.Address{State: "Kerala"}, "books"},
+		{"tax class in a state", "books", models.Address{State: "Maharashtra", Pincode: "400001"}, "books in maharashtra"},
+		{"tax class without a rule", "toys", models.Address{State: "Maharashtra"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := taxRuleFor(rules, tt.taxClass, tt.address)
+			if ok != (tt.want != "") || rule.Name != tt.want {
+				t.Errorf("taxRuleFor() = %q, %v, want %q", rule.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineTax(t *testing.T) {
+	defer func(inclusive bool) { PricesIncludeTax = inclusive }(PricesIncludeTax)
+
+	tests := []struct {
+		name      string
+		inclusive bool
+		amount    int64
+		rate      uint64
+		want      int64
+	}{
+		{"on top", false, 10000, 1800, 1800},
+		{"on top rounds to nearest", false, 999, 1800, 180},
+		{"on top rounds half up", false, 25, 1800, 5},
+		{"included", true, 11800, 1800, 1800},
+		{"included rounds to nearest", true, 10000, 1800, 1525},
+		{"zero rate", true, 10000, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			PricesIncludeTax = tt.inclusive
+			if got := lineTax(tt.amount, tt.rate); got != tt.want {
+				t.Errorf("lineTax(%d, %d) = %d, want %d", tt.amount, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaxCartSharesDiscount(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, rule := range []models.TaxRule{{Name: "gst", Rate: 1800}, {Name: "books", TaxClass: "books", Rate: 500}} {
+		if _, err := CreateTaxRule(ctx, store, rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	book := pricedLine(primitive.NewObjectID(), 10000, 1)
+	book.TaxClass = "books"
+	cart := []models.ProductUser{book, pricedLine(primitive.NewObjectID(), 10000, 3), pricedLine(primitive.NewObjectID(), 1, 1)}
+
+	lines, tax, err := taxCart(ctx, store, models.Address{}, cart, models.NewMoney(4001, models.BaseCurrency))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		rule    string
+		taxable int64
+		tax     int64
+	}{
+		{"books", 9000, 450},
+		{"gst", 27000, 4860},
+		{"gst", 0, 0},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("%d tax lines, want %d", len(lines), len(want))
+	}
+	var discounted int64
+	for i, line := range lines {
+		discounted += line.Taxable.Amount
+		if line.Rule != want[i].rule || line.Taxable.Amount != want[i].taxable || line.Tax.Amount != want[i].tax {
+			t.Errorf("line %d = %s on %d taxed %d, want %s on %d taxed %d", i, line.Rule, line.Taxable.Amount, line.Tax.Amount, want[i].rule, want[i].taxable, want[i].tax)
+		}
+	}
+	if discounted != 40001-4001 {
+		t.Errorf("taxable lines add up to %d, want the cart less its discount, %d", discounted, 40001-4001)
+	}
+	if tax.Amount != 5310 {
+		t.Errorf("tax = %d, want 5310", tax.Amount)
+	}
+}
+
+func TestCheckoutTaxModes(t *testing.T) {
+	defer func(inclusive bool) { PricesIncludeTax = inclusive }(PricesIncludeTax)
+
+	tests := []struct {
+		name      string
+		inclusive bool
+		tax       int64
+		total     int64
+	}{
+		{"exclusive", false, 3600, 23600},
+		{"inclusive", true, 3051, 20000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			PricesIncludeTax = tt.inclusive
+			s := newShop(t)
+			if _, err := CreateTaxRule(s.ctx, s.store, models.TaxRule{Name: "gst", Rate: 1800}); err != nil {
+				t.Fatal(err)
+			}
+
+			order := s.order(s.product("Kettle", 10000, 5), 2, models.PaymentMethodCOD)
+			if order.TaxInclusive != tt.inclusive || order.Tax.Amount != tt.tax {
+				t.Errorf("order tax = %d (inclusive %v), want %d (inclusive %v)", order.Tax.Amount, order.TaxInclusive, tt.tax, tt.inclusive)
+			}
+			if total, err := order.Total(); err != nil || total.Amount != tt.total {
+				t.Errorf("order total = %d, %v, want %d", total.Amount, err, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrEventMismatch = errors.New("the event is not about a payment of ours")
+
+// ReceivePaymentEvent records event, which provider sent as payload, and
+// applies it to the order it is about. A redelivered event is only applied
+// again when handling it failed the first time, or never finished, as when
+// the server stopped between recording the event and its outcome; otherwise
+// ErrEventExists is returned with the record of the earlier delivery.
+func ReceivePaymentEvent(ctx context.Context, events PaymentEventStore, orders OrderStore, provider payments.Provider, event payments.Event, payload []byte) (models.PaymentEvent, error) {
+	record := models.PaymentEvent{
+		ID:         provider.Name() + ":" + event.ID,
+		Provider:   provider.Name(),
+		EventID:    event.ID,
+		Type:       event.Type,
+		PaymentID:  event.PaymentID,
+		Reference:  event.Reference,
+		Amount:     event.Amount,
+		Reason:     event.Reason,
+		Payload:    string(payload),
+		ReceivedAt: time.Now(),
+	}
+	err := events.InsertPaymentEvent(ctx, record)
+	if errors.Is(err, ErrEventExists) {
+		existing, findErr := events.FindPaymentEvent(ctx, record.ID)
+		if findErr != nil {
+			log.Println(findErr)
+			return record, findErr
+		}
+		if existing.Outcome != "" && existing.Outcome != models.EventFailed {
+			return existing, err
+		}
+		record = existing
+	} else if err != nil {
+		log.Println(err)
+		return record, err
+	}
+
+	return handlePaymentEvent(ctx, events, orders, provider, record)
+}
+
+// ReplayPaymentEvent handles a recorded event again, for example once the
+// problem that made it fail has been fixed. Applying an event twice is
+// harmless: one about a payment that is already settled is ignored.
+func ReplayPaymentEvent(ctx context.Context, events PaymentEventStore, orders OrderStore, provider payments.Provider, id string) (models.PaymentEvent, error) {
+	record, err := events.FindPaymentEvent(ctx, id)
+	if err != nil {
+		return models.PaymentEvent{}, err
+	}
+	if record.Provider != provider.Name() {
+		return record, ErrEventMismatch
+	}
+	record.Replays++
+	return handlePaymentEvent(ctx, events, orders, provider, record)
+}
+
+func handlePaymentEvent(ctx context.Context, events PaymentEventStore, orders OrderStore, provider payments.Provider, record models.PaymentEvent) (models.PaymentEvent, error) {
+	event := payments.Event{
+		ID:        record.EventID,
+		Type:      record.Type,
+		PaymentID: record.PaymentID,
+		Reference: record.Reference,
+		Amount:    record.Amount,
+		Reason:    record.Reason,
+	}
+
+	var err error
+	record.OrderID, record.Outcome, err = applyPaymentEvent(ctx, orders, provider, event)
+	record.Error = ""
+	if err != nil {
+		record.Error = err.Error()
+	}
+	record.ProcessedAt = time.Now()
+
+	if err := events.UpdatePaymentEvent(ctx, record); err != nil {
+		log.Println(err)
+		return record, err
+	}
+	return record, nil
+}
+
+// applyPaymentEvent settles the pending payment of the order event is about.
+// An authorization is captured straight away, as at checkout, unless the
+// order has been cancelled meanwhile, in which case it is voided. Events
+// about payments that are already settled change nothing.
+func applyPaymentEvent(ctx context.Context, orders OrderStore, provider payments.Provider, event payments.Event) (primitive.ObjectID, string, error) {
+	orderID, err := primitive.ObjectIDFromHex(event.Reference)
+	if err != nil {
+		return primitive.NilObjectID, models.EventIgnored, ErrEventMismatch
+	}
+	order, err := orders.FindOrderByID(ctx, orderID)
+	if errors.Is(err, ErrOrderNotFound) {
+		return orderID, models.EventIgnored, ErrEventMismatch
+	}
+	if err != nil {
+		log.Println(err)
+		return orderID, models.EventFailed, err
+	}
+
+	payment := order.PaymentMethod
+	if !payment.Digital || payment.Provider != provider.Name() || (payment.TransactionID != "" && payment.TransactionID != event.PaymentID) {
+		return orderID, models.EventIgnored, ErrEventMismatch
+	}
+
+	if payment.Status == models.PaymentPending {
+		payment.TransactionID = event.PaymentID
+		switch {
+		case event.Type == payments.EventAuthorized && order.Status != models.OrderPending:
+			if err := provider.Void(ctx, event.PaymentID); err != nil {
+				log.Println(err)
+				return orderID, models.EventFailed, err
+			}
+			payment.Status, payment.Error = models.PaymentVoided, "the order was no longer waiting for payment"
+		case event.Type == payments.EventAuthorized:
+			if err := provider.Capture(ctx, event.PaymentID, payment.Amount); err != nil {
+				log.Println(err)
+				if err := provider.Void(ctx, event.PaymentID); err != nil {
+					log.Println(err)
+				}
+				payment.Status, payment.Error = models.PaymentVoided, err.Error()
+			} else {
+				payment.Status = models.PaymentCaptured
+			}
+		case event.Type == payments.EventCaptured:
+			payment.Status = models.PaymentCaptured
+		case event.Type == payments.EventFailed:
+			payment.Status, payment.Error = models.PaymentFailed, event.Reason
+		default:
+			return orderID, models.EventIgnored, nil
+		}
+
+		err := orders.UpdateOrderPayment(ctx, orderID, models.PaymentPending, payment)
+		if errors.Is(err, ErrPaymentChanged) {
+			return orderID, models.EventIgnored, nil
+		}
+		if err != nil {
+			log.Println(err)
+			return orderID, models.EventFailed, err
+		}
+	} else if payment.Status != models.PaymentCaptured || order.Status != models.OrderPending {
+		return orderID, models.EventIgnored, nil
+	}
+
+	// A captured payment moves the order on. This also finishes the job for
+	// an earlier delivery that recorded the payment but failed here.
+	if payment.Status == models.PaymentCaptured && order.Status == models.OrderPending {
+		_, err := UpdateOrderStatus(ctx, orders, orderID, models.OrderPaid, "payment confirmed by "+provider.Name())
+		if err != nil && !errors.Is(err, ErrOrderStatusChanged) {
+			log.Println(err)
+			return orderID, models.EventFailed, err
+		}
+	}
+	return orderID, models.EventApplied, nil
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/patil-prathamesh/e-commerce-golang/controllers"
 	"github.com/patil-prathamesh/e-commerce-golang/database"
-	"github.com/patil-prathamesh/e-commerce-golang/middleware"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"github.com/patil-prathamesh/e-commerce-golang/payments"
 	"github.com/patil-prathamesh/e-commerce-golang/routes"
 	"github.com/patil-prathamesh/e-commerce-golang/tokens"
 )
@@ -20,19 +25,80 @@ func main() {
 	if port == "" {
 		port = "8000"
 	}
+	if currency := os.Getenv("BASE_CURRENCY"); currency != "" {
+		models.BaseCurrency = strings.ToUpper(currency)
+	}
+	database.PricesIncludeTax = os.Getenv("PRICES_INCLUDE_TAX") == "true"
+	if days := os.Getenv("RETURN_WINDOW_DAYS"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid RETURN_WINDOW_DAYS %q", days)
+		}
+		database.ReturnWindow = time.Duration(n) * 24 * time.Hour
+	}
+	if minutes := os.Getenv("PAYMENT_DEADLINE_MINUTES"); minutes != "" {
+		n, err := strconv.Atoi(minutes)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid PAYMENT_DEADLINE_MINUTES %q", minutes)
+		}
+		database.PaymentDeadline = time.Duration(n) * time.Minute
+	}
+
+	var store database.Store
+	if os.Getenv("STORAGE") == "memory" {
+		store = database.NewMemoryStore()
+	} else {
+		mongoStore := database.NewMongoStore(database.Client)
+		if err := mongoStore.EnsureIndexes(context.Background()); err != nil {
+			log.Println("creating indexes failed:", err)
+		}
+		if err := mongoStore.MigrateMoneyAmounts(context.Background()); err != nil {
+			log.Println("money amounts migration failed:", err)
+		}
+		if _, err := mongoStore.MigrateEmbeddedOrders(context.Background()); err != nil {
+			log.Println("embedded orders migration failed:", err)
+		}
+		// Products from before stock was tracked start with this many units.
+		var legacyStock uint64
+		if stock := os.Getenv("LEGACY_PRODUCT_STOCK"); stock != "" {
+			n, err := strconv.ParseUint(stock, 10, 64)
+			if err != nil {
+				log.Fatalf("invalid LEGACY_PRODUCT_STOCK %q", stock)
+			}
+			legacyStock = n
+		}
+		if _, err := mongoStore.MigrateLegacyStock(context.Background(), legacyStock); err != nil {
+			log.Println("legacy stock migration failed:", err)
+		}
+		store = mongoStore
+	}
 
-	app := controllers.NewApplication(database.ProductData(database.Client, "products"), database.UserData(database.Client, "users"))
+	app := controllers.NewApplication(store)
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "":
+		// Only cash on delivery is offered.
+	case "fake":
+		webhookURL := os.Getenv("PAYMENT_WEBHOOK_URL")
+		if webhookURL == "" {
+			webhookURL = "http://localhost:" + port + "/payments/webhook"
+		}
+		app.Payments = payments.NewFakeProvider(os.Getenv("PAYMENT_WEBHOOK_SECRET"), webhookURL)
+	default:
+		log.Fatalf("unknown payment provider %q", provider)
+	}
+	go database.SweepExpiredReservations(context.Background(), store, store, time.Minute)
+	if app.Payments != nil {
+		go database.SweepUnpaidOrders(context.Background(), store, store, store, store, app.Payments, time.Minute)
+	}
 
 	router := gin.New()
 	router.Use(gin.Logger())
 
-	routes.UserRoutes(router)
-	router.Use(middleware.Authentication)
-
-	router.PUT("/addtocart", app.AddToCart)
-	router.PUT("/removeitem", app.RemoveItem)
-	router.POST("/cartcheckout", app.BuyFromCart)
-	router.POST("/instantbuy", app.InstantBuy)
+	routes.UserRoutes(router, app)
+	routes.PaymentRoutes(router, app)
+	routes.AdminRoutes(router, app)
+	routes.SupportRoutes(router, app)
+	routes.CustomerRoutes(router, app)
 
 	log.Fatal(router.Run(":" + port))
 }
@@ -4,6 +4,7 @@ import (
     "net/http"
 
     "github.com/gin-gonic/gin"
+    "github.com/patil-prathamesh/e-commerce-golang/models"
     "github.com/patil-prathamesh/e-commerce-golang/tokens"
 )
 
@@ -27,9 +28,39 @@ func Authentication(c *gin.Context) {
         return
     }
 
+    if claims.TokenType == tokens.RefreshToken {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh tokens cannot be used to call the API"})
+        c.Abort()
+        return
+    }
+
+    role := claims.Role
+    if role == "" {
+        role = models.RoleCustomer
+    }
+
+    c.Set("uid", claims.UserID)
     c.Set("email", claims.Email)
     c.Set("first_name", claims.FirstName)
     c.Set("last_name", claims.LastName)
+    c.Set("role", role)
     
     c.Next()
+}
+
+// Authorize only lets requests through whose token carries one of roles. It
+// must run after Authentication.
+func Authorize(roles ...string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        role := c.GetString("role")
+        for _, allowed := range roles {
+            if role == allowed {
+                c.Next()
+                return
+            }
+        }
+
+        c.JSON(http.StatusForbidden, gin.H{"error": "you are not allowed to access this resource"})
+        c.Abort()
+    }
 }
\ No newline at end of file
@@ -12,34 +12,122 @@ type UserLogin struct {
 }
 
 type User struct {
-	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	FirstName      string             `json:"first_name" validate:"required,min=2,max=30"`
-	LastName       string             `json:"last_name" validate:"required,min=2,max=30"`
-	Password       string             `json:"password" validate:"required,min=6"`
-	Email          string             `json:"email" validate:"required,email"`
-	Phone          string             `json:"phone"  validate:"required"`
-	RefreshToken   string             `json:"refresh_token" bson:"refresh_token"`
-	CreatedAt      time.Time          `json:"created_at"`
-	UpdatedAt      time.Time          `json:"updated_at"`
-	UserCart       []ProductUser      `json:"user_cart" bson:"user_cart"`
-	AddressDetails []Address          `json:"address_details" bson:"address_details"`
-	Order          []Order            `json:"orders" bson:"orders"`
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	FirstName    string             `json:"first_name" validate:"required,min=2,max=30"`
+	LastName     string             `json:"last_name" validate:"required,min=2,max=30"`
+	Password     string             `json:"password" validate:"required,min=6"`
+	Email        string             `json:"email" validate:"required,email"`
+	Phone        string             `json:"phone"  validate:"required"`
+	RefreshToken string             `json:"refresh_token" bson:"refresh_token"`
+	TokenFamily  string             `json:"-" bson:"token_family"`
+	Role         string             `json:"role" bson:"role"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	UserCart     []ProductUser      `json:"user_cart" bson:"user_cart"`
+	// CouponCode is the coupon applied to the cart, redeemed at checkout.
+	CouponCode     string    `json:"coupon_code,omitempty" bson:"coupon_code,omitempty"`
+	AddressDetails []Address `json:"address_details" bson:"address_details"`
+	// Order is only read by the migration that moves legacy embedded orders
+	// into the orders collection; new orders are never written here.
+	Order []Order `json:"orders,omitempty" bson:"orders,omitempty"`
+}
+
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+	RoleSupport  = "support"
+	RoleSeller   = "seller"
+)
+
+func ValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleAdmin, RoleSupport, RoleSeller:
+		return true
+	}
+	return false
+}
+
+// UserRole returns the user's role, treating accounts created before roles
+// existed as customers.
+func (u User) UserRole() string {
+	if u.Role == "" {
+		return RoleCustomer
+	}
+	return u.Role
 }
 
 type Product struct {
 	ProductID   primitive.ObjectID `bson:"_id,omitempty"`
-	ProductName string             `json:"product_name" bson:"product_name"`
-	Price       uint64             `json:"price"`
-	Rating      uint8              `json:"rating"`
-	Image       string             `json:"image"`
+	ProductName string             `json:"product_name" bson:"product_name" validate:"required,min=2,max=100"`
+	Price       Money              `json:"price"`
+	Rating      uint8              `json:"rating" validate:"lte=5"`
+	Image       string             `json:"image" validate:"omitempty,url"`
+	Description string             `json:"description" bson:"description" validate:"max=2000"`
+	Tags        []string           `json:"tags" bson:"tags" validate:"max=20,dive,min=1,max=30"`
+	Categories  []string           `json:"categories" bson:"categories" validate:"max=10,dive,min=1,max=50"`
+	Brand       string             `json:"brand" bson:"brand" validate:"max=50"`
+	// Attributes are free-form filterable properties such as color or size.
+	Attributes map[string]string `json:"attributes,omitempty" bson:"attributes,omitempty" validate:"max=30,dive,keys,min=1,max=30,excludesall=.$,endkeys,max=100"`
+	Stock      uint64            `json:"stock" bson:"stock"`
+	// TaxClass picks the tax rules that apply; empty is the standard class.
+	TaxClass  string    `json:"tax_class,omitempty" bson:"tax_class,omitempty" validate:"max=30"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// Variants, when present, are what customers actually buy; the product's
+	// own Stock is then unused.
+	Variants []Variant `json:"variants,omitempty" bson:"variants,omitempty" validate:"max=100,dive"`
+	// Deleted products are hidden from the storefront but kept so that old
+	// orders still point at something.
+	Deleted   bool       `json:"deleted" bson:"deleted"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// Variant is one purchasable version of a product, such as a t-shirt in one
+// size and color. A zero Price or empty Image falls back to the product's.
+type Variant struct {
+	SKU     string            `json:"sku" bson:"sku" validate:"required,max=64"`
+	Options map[string]string `json:"options" bson:"options" validate:"required,min=1,max=10,dive,keys,min=1,max=30,endkeys,min=1,max=50"`
+	Price   Money             `json:"price,omitzero" bson:"price,omitempty"`
+	Image   string            `json:"image,omitempty" bson:"image,omitempty" validate:"omitempty,url"`
+	Stock   uint64            `json:"stock" bson:"stock"`
+}
+
+// Variant looks up one of the product's variants by SKU.
+func (p Product) Variant(sku string) (Variant, bool) {
+	for _, variant := range p.Variants {
+		if variant.SKU == sku {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// ProductUpdate is a partial product update; nil fields are left unchanged.
+type ProductUpdate struct {
+	ProductName *string   `json:"product_name" validate:"omitempty,min=2,max=100"`
+	Price       *Money    `json:"price"`
+	Rating      *uint8    `json:"rating" validate:"omitempty,lte=5"`
+	Image       *string   `json:"image" validate:"omitempty,url"`
+	Description *string   `json:"description" validate:"omitempty,max=2000"`
+	Tags        *[]string `json:"tags" validate:"omitempty,max=20,dive,min=1,max=30"`
+	Categories  *[]string `json:"categories" validate:"omitempty,max=10,dive,min=1,max=50"`
+	Brand       *string   `json:"brand" validate:"omitempty,max=50"`
+	// Attributes replaces the whole attribute set when given.
+	Attributes *map[string]string `json:"attributes" validate:"omitempty,max=30,dive,keys,min=1,max=30,excludesall=.$,endkeys,max=100"`
+	TaxClass   *string            `json:"tax_class" validate:"omitempty,max=30"`
 }
 
 type ProductUser struct {
 	ProductID   primitive.ObjectID `bson:"_id,omitempty"`
 	ProductName string             `json:"product_name" bson:"product_name"`
-	Price       uint64             `json:"price" bson:"price"`
+	Price       Money              `json:"price" bson:"price"`
 	Rating      uint8              `json:"rating" bson:"rating"`
 	Image       string             `json:"image" bson:"image"`
+	Quantity    uint64             `json:"quantity" bson:"quantity"`
+	// VariantSKU names the variant bought, for products that have variants.
+	VariantSKU string            `json:"variant_sku,omitempty" bson:"variant_sku,omitempty"`
+	Options    map[string]string `json:"options,omitempty" bson:"options,omitempty"`
+	TaxClass   string            `json:"tax_class,omitempty" bson:"tax_class,omitempty"`
 }
 
 type Address struct {
@@ -48,18 +136,450 @@ type Address struct {
 	Street    string             `json:"street" bson:"street"`
 	City      string             `json:"city" bson:"city"`
 	Pincode   string             `json:"pin_code" bson:"pin_code"`
+	State     string             `json:"state" bson:"state"`
 }
 
 type Order struct {
-	OrderID       primitive.ObjectID `bson:"_id"`
-	OrderCart     []ProductUser      `json:"order_list" bson:"order_list"`
-	OrderedAt     time.Time          `json:"order_at" bson:"order_at"`
-	Price         uint64             `json:"price" bson:"price"`
-	Discount      uint8              `json:"discount" bson:"discount"`
-	PaymentMethod Payment            `json:"payment_method" bson:"payment_method"`
+	OrderID   primitive.ObjectID `bson:"_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	OrderCart []ProductUser      `json:"order_list" bson:"order_list"`
+	OrderedAt time.Time          `json:"order_at" bson:"order_at"`
+	Price     Money              `json:"price" bson:"price"`
+	// Discount is the amount taken off Price by Promotions and CouponCode
+	// together.
+	Discount   Money             `json:"discount" bson:"discount"`
+	Promotions []PromotionSaving `json:"promotions,omitempty" bson:"promotions,omitempty"`
+	CouponCode string            `json:"coupon_code,omitempty" bson:"coupon_code,omitempty"`
+	// Tax is charged on top of Price less Discount, unless TaxInclusive says
+	// it is already part of the prices. TaxLines break it down per line.
+	Tax          Money     `json:"tax" bson:"tax"`
+	TaxInclusive bool      `json:"tax_inclusive" bson:"tax_inclusive"`
+	TaxLines     []TaxLine `json:"tax_lines,omitempty" bson:"tax_lines,omitempty"`
+	// The order is charged in SettlementCurrency, the currency of Price. The
+	// customer saw prices in DisplayCurrency, converted at ExchangeRate, and
+	// DisplayTotal is what they were shown as the total.
+	SettlementCurrency string         `json:"settlement_currency" bson:"settlement_currency"`
+	DisplayCurrency    string         `json:"display_currency" bson:"display_currency"`
+	ExchangeRate       string         `json:"exchange_rate" bson:"exchange_rate"`
+	DisplayTotal       Money          `json:"display_total" bson:"display_total"`
+	PaymentMethod      Payment        `json:"payment_method" bson:"payment_method"`
+	ShippingAddress    Address        `json:"shipping_address" bson:"shipping_address"`
+	Status             OrderStatus    `json:"status" bson:"status"`
+	StatusHistory      []StatusChange `json:"status_history" bson:"status_history"`
+	Refunds            []Refund       `json:"refunds,omitempty" bson:"refunds,omitempty"`
+}
+
+type OrderStatus string
+
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderPaid      OrderStatus = "paid"
+	OrderPacked    OrderStatus = "packed"
+	OrderShipped   OrderStatus = "shipped"
+	OrderDelivered OrderStatus = "delivered"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderReturned  OrderStatus = "returned"
+)
+
+// orderTransitions lists the statuses each status may move to. Pending orders
+// can go straight to packed because cash on delivery is paid at the door.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderPaid, OrderPacked, OrderCancelled},
+	OrderPaid:      {OrderPacked, OrderCancelled},
+	OrderPacked:    {OrderShipped, OrderCancelled},
+	OrderShipped:   {OrderDelivered},
+	OrderDelivered: {OrderReturned},
+}
+
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderPending, OrderPaid, OrderPacked, OrderShipped, OrderDelivered, OrderCancelled, OrderReturned:
+		return true
+	}
+	return false
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange is one entry in an order's status history.
+type StatusChange struct {
+	From OrderStatus `json:"from,omitempty" bson:"from,omitempty"`
+	To   OrderStatus `json:"to" bson:"to"`
+	At   time.Time   `json:"at" bson:"at"`
+	Note string      `json:"note,omitempty" bson:"note,omitempty"`
 }
 
+const (
+	PaymentMethodCOD  = "cod"
+	PaymentMethodCard = "card"
+)
+
+type PaymentStatus string
+
+const (
+	// PaymentPending is a digital payment whose outcome is not known yet.
+	PaymentPending  PaymentStatus = "pending"
+	PaymentCaptured PaymentStatus = "captured"
+	PaymentFailed   PaymentStatus = "failed"
+	PaymentVoided   PaymentStatus = "voided"
+)
+
+// Payment says how an order is paid for: cash on delivery, or digitally
+// through Provider, which knows the payment as TransactionID. Status stays
+// empty until a digital payment is first attempted.
 type Payment struct {
-	Digital bool
-	COD     bool
+	Digital       bool
+	COD           bool
+	Provider      string        `json:"provider,omitempty" bson:"provider,omitempty"`
+	TransactionID string        `json:"transaction_id,omitempty" bson:"transaction_id,omitempty"`
+	Status        PaymentStatus `json:"status,omitempty" bson:"status"`
+	Amount        Money         `json:"amount,omitzero" bson:"amount,omitempty"`
+	// Error says why the last attempt to pay failed.
+	Error string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// Paid reports whether nothing is left to collect before the order ships.
+// Cash on delivery is collected at the door.
+func (p Payment) Paid() bool {
+	return !p.Digital || p.Status == PaymentCaptured
+}
+
+// StockAdjustment records a manual change to a product's stock level.
+type StockAdjustment struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id"`
+	ProductID  primitive.ObjectID `json:"product_id" bson:"product_id"`
+	VariantSKU string             `json:"variant_sku,omitempty" bson:"variant_sku,omitempty"`
+	Delta      int64              `json:"delta" bson:"delta"`
+	Reason     string             `json:"reason" bson:"reason"`
+	Note       string             `json:"note" bson:"note"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Reservation holds stock for a user's cart while checkout is in progress.
+type Reservation struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Lines     []ReservationLine  `json:"lines" bson:"lines"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+}
+
+type ReservationLine struct {
+	ProductID  primitive.ObjectID `json:"product_id" bson:"product_id"`
+	VariantSKU string             `json:"variant_sku,omitempty" bson:"variant_sku,omitempty"`
+	Quantity   uint64             `json:"quantity" bson:"quantity"`
+}
+
+// StockShortage describes a single order line that cannot be fulfilled.
+type StockShortage struct {
+	ProductID   primitive.ObjectID `json:"product_id"`
+	ProductName string             `json:"product_name"`
+	VariantSKU  string             `json:"variant_sku,omitempty"`
+	Requested   uint64             `json:"requested"`
+	Available   uint64             `json:"available"`
+}
+
+// AuditEntry records a staff member acting on another user's account.
+type AuditEntry struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	ActorID   primitive.ObjectID `json:"actor_id" bson:"actor_id"`
+	SubjectID primitive.ObjectID `json:"subject_id" bson:"subject_id"`
+	Action    string             `json:"action" bson:"action"`
+	Details   string             `json:"details,omitempty" bson:"details,omitempty"`
+	At        time.Time          `json:"at" bson:"at"`
+}
+
+// SynonymGroup is a set of search terms that are treated as equivalent, such
+// as "sneakers" and "trainers".
+type SynonymGroup struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	Terms     []string           `json:"terms" bson:"terms" validate:"min=2,max=20,dive,min=1,max=30"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// Category is a node of the category tree; root categories have no Parent.
+// Products and child categories refer to a category by its Slug, so the slug
+// never changes once the category exists.
+type Category struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id"`
+	Slug         string             `json:"slug" bson:"slug" validate:"required,max=50"`
+	Name         string             `json:"name" bson:"name" validate:"required,min=2,max=100"`
+	Parent       string             `json:"parent,omitempty" bson:"parent,omitempty" validate:"max=50"`
+	DisplayOrder int                `json:"display_order" bson:"display_order"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// CategoryUpdate is a partial category update; nil fields are left unchanged
+// and an empty Parent moves the category to the root.
+type CategoryUpdate struct {
+	Name         *string `json:"name" validate:"omitempty,min=2,max=100"`
+	Parent       *string `json:"parent" validate:"omitempty,max=50"`
+	DisplayOrder *int    `json:"display_order"`
+}
+
+const (
+	CouponPercent = "percent"
+	CouponFixed   = "fixed"
+)
+
+// Coupon is a discount code taking Percent off for percent coupons and Amount
+// off for fixed ones. Zero limits, zero times and empty scopes mean "no
+// restriction"; a scoped coupon only discounts the matching cart lines.
+type Coupon struct {
+	ID           primitive.ObjectID   `json:"_id" bson:"_id"`
+	Code         string               `json:"code" bson:"code" validate:"required,min=3,max=30,alphanum"`
+	Kind         string               `json:"kind" bson:"kind" validate:"required,oneof=percent fixed"`
+	Percent      uint64               `json:"percent,omitempty" bson:"percent,omitempty"`
+	Amount       Money                `json:"amount,omitzero" bson:"amount,omitempty"`
+	MinCartValue Money                `json:"min_cart_value,omitzero" bson:"min_cart_value,omitempty"`
+	StartsAt     time.Time            `json:"starts_at" bson:"starts_at"`
+	EndsAt       time.Time            `json:"ends_at" bson:"ends_at"`
+	UsageLimit   uint64               `json:"usage_limit" bson:"usage_limit"`
+	PerUserLimit uint64               `json:"per_user_limit" bson:"per_user_limit"`
+	Used         uint64               `json:"used" bson:"used"`
+	ProductIDs   []primitive.ObjectID `json:"product_ids,omitempty" bson:"product_ids,omitempty"`
+	Categories   []string             `json:"categories,omitempty" bson:"categories,omitempty"`
+	CreatedAt    time.Time            `json:"created_at" bson:"created_at"`
+}
+
+const (
+	PromotionBuyXGetY = "buy_x_get_y"
+	PromotionBundle   = "bundle"
+	PromotionTiered   = "tiered"
+)
+
+// Promotion is a discount applied to every cart it matches, without a code.
+// A buy_x_get_y promotion makes FreeQuantity of every BuyQuantity +
+// FreeQuantity units free, cheapest first. A bundle sells one unit of each of
+// ProductIDs together for BundlePrice. A tiered promotion takes the Percent
+// of the highest tier reached by the number of matching units. An empty
+// ProductIDs matches every product, except for bundles, which need at least
+// two.
+type Promotion struct {
+	ID           primitive.ObjectID   `json:"_id" bson:"_id"`
+	Name         string               `json:"name" bson:"name" validate:"required,max=100"`
+	Kind         string               `json:"kind" bson:"kind" validate:"required,oneof=buy_x_get_y bundle tiered"`
+	ProductIDs   []primitive.ObjectID `json:"product_ids,omitempty" bson:"product_ids,omitempty"`
+	BuyQuantity  uint64               `json:"buy_quantity,omitempty" bson:"buy_quantity,omitempty"`
+	FreeQuantity uint64               `json:"free_quantity,omitempty" bson:"free_quantity,omitempty"`
+	BundlePrice  Money                `json:"bundle_price,omitzero" bson:"bundle_price,omitempty"`
+	Tiers        []PriceTier          `json:"tiers,omitempty" bson:"tiers,omitempty" validate:"dive"`
+	StartsAt     time.Time            `json:"starts_at" bson:"starts_at"`
+	EndsAt       time.Time            `json:"ends_at" bson:"ends_at"`
+	CreatedAt    time.Time            `json:"created_at" bson:"created_at"`
+}
+
+type PriceTier struct {
+	MinQuantity uint64 `json:"min_quantity" bson:"min_quantity" validate:"required"`
+	Percent     uint64 `json:"percent" bson:"percent" validate:"required,max=100"`
+}
+
+// PromotionSaving is what one promotion took off a cart or order.
+type PromotionSaving struct {
+	PromotionID primitive.ObjectID `json:"promotion_id" bson:"promotion_id"`
+	Name        string             `json:"name" bson:"name"`
+	Amount      Money              `json:"amount" bson:"amount"`
+}
+
+// TaxRule charges Rate, in basis points, on products of TaxClass shipped to
+// an address in one of States or with a pin code starting with one of
+// PincodePrefixes. A rule listing neither applies everywhere. An empty
+// TaxClass is the standard class.
+type TaxRule struct {
+	ID              primitive.ObjectID `json:"_id" bson:"_id"`
+	Name            string             `json:"name" bson:"name" validate:"required,max=100"`
+	TaxClass        string             `json:"tax_class" bson:"tax_class" validate:"max=30"`
+	States          []string           `json:"states,omitempty" bson:"states,omitempty" validate:"max=50,dive,min=1,max=50"`
+	PincodePrefixes []string           `json:"pincode_prefixes,omitempty" bson:"pincode_prefixes,omitempty" validate:"max=100,dive,numeric,min=1,max=6"`
+	Rate            uint64             `json:"rate" bson:"rate" validate:"lte=10000"`
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// TaxLine is the tax on one order line. Taxable is the line's share of the
+// order after discounts; Rule is empty when no rule applied.
+type TaxLine struct {
+	ProductID  primitive.ObjectID `json:"product_id" bson:"product_id"`
+	VariantSKU string             `json:"variant_sku,omitempty" bson:"variant_sku,omitempty"`
+	TaxClass   string             `json:"tax_class,omitempty" bson:"tax_class,omitempty"`
+	Rule       string             `json:"rule,omitempty" bson:"rule,omitempty"`
+	Rate       uint64             `json:"rate" bson:"rate"`
+	Taxable    Money              `json:"taxable" bson:"taxable"`
+	Tax        Money              `json:"tax" bson:"tax"`
+}
+
+// Total is what the customer pays for the order: Price less Discount, plus
+// Tax unless the prices already include it.
+func (o Order) Total() (Money, error) {
+	total, err := o.Price.Sub(o.Discount)
+	if err != nil || o.TaxInclusive {
+		return total, err
+	}
+	return total.Add(o.Tax)
+}
+
+// Outcomes of handling a payment event.
+const (
+	EventApplied = "applied"
+	EventIgnored = "ignored"
+	EventFailed  = "failed"
+)
+
+// PaymentEvent is a notification received from a payment provider, kept as
+// received so it can be replayed. ID is the provider's name and its event ID,
+// which makes a redelivered event easy to recognise.
+type PaymentEvent struct {
+	ID         string             `json:"_id" bson:"_id"`
+	Provider   string             `json:"provider" bson:"provider"`
+	EventID    string             `json:"event_id" bson:"event_id"`
+	Type       string             `json:"type" bson:"type"`
+	PaymentID  string             `json:"payment_id" bson:"payment_id"`
+	Reference  string             `json:"reference" bson:"reference"`
+	Amount     Money              `json:"amount" bson:"amount"`
+	Reason     string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	OrderID    primitive.ObjectID `json:"order_id,omitzero" bson:"order_id,omitempty"`
+	Payload    string             `json:"payload" bson:"payload"`
+	ReceivedAt time.Time          `json:"received_at" bson:"received_at"`
+	// Outcome, Error and ProcessedAt describe the latest time the event was
+	// handled; Replays counts the times it was handled again on request.
+	Outcome     string    `json:"outcome" bson:"outcome"`
+	Error       string    `json:"error,omitempty" bson:"error,omitempty"`
+	ProcessedAt time.Time `json:"processed_at" bson:"processed_at"`
+	Replays     int       `json:"replays" bson:"replays"`
+}
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundCompleted RefundStatus = "completed"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// Refund gives money back for some or all of an order's lines. A card refund
+// goes back through the payment provider, which knows it as TransactionID;
+// a cash on delivery refund is paid out by hand from the refund ledger.
+type Refund struct {
+	ID            primitive.ObjectID `json:"_id" bson:"_id"`
+	Amount        Money              `json:"amount" bson:"amount"`
+	Lines         []RefundLine       `json:"lines" bson:"lines"`
+	Reason        string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Method        string             `json:"method" bson:"method"`
+	Status        RefundStatus       `json:"status" bson:"status"`
+	TransactionID string             `json:"transaction_id,omitempty" bson:"transaction_id,omitempty"`
+	Error         string             `json:"error,omitempty" bson:"error,omitempty"`
+	IssuedBy      primitive.ObjectID `json:"issued_by" bson:"issued_by"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// RefundLine is the part of a refund for one order line. Amount is what the
+// customer paid for Quantity units of it, discounts and tax included.
+type RefundLine struct {
+	ProductID  primitive.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
+	VariantSKU string             `json:"variant_sku,omitempty" bson:"variant_sku,omitempty"`
+	Quantity   uint64             `json:"quantity" bson:"quantity"`
+	Amount     Money              `json:"amount" bson:"amount"`
+}
+
+// Refunded is what has been, or is being, refunded for the order so far.
+func (o Order) Refunded() Money {
+	refunded := NewMoney(0, o.Price.Currency)
+	for _, refund := range o.Refunds {
+		if refund.Status != RefundFailed {
+			refunded.Amount += refund.Amount.Amount
+		}
+	}
+	return refunded
+}
+
+// LedgerEntry is money owed back to a customer for a cash on delivery order,
+// for finance to pay out by hand.
+type LedgerEntry struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	OrderID   primitive.ObjectID `json:"order_id" bson:"order_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	RefundID  primitive.ObjectID `json:"refund_id" bson:"refund_id"`
+	Amount    Money              `json:"amount" bson:"amount"`
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received"
+	ReturnCompleted ReturnStatus = "completed"
+)
+
+// returnTransitions lists the statuses each return status may move to.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnCompleted},
+}
+
+func (s ReturnStatus) Valid() bool {
+	switch s {
+	case ReturnRequested, ReturnApproved, ReturnRejected, ReturnReceived, ReturnCompleted:
+		return true
+	}
+	return false
+}
+
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Return is a customer's request to send back delivered lines of an order,
+// known to the warehouse as an RMA. Support approves or rejects it. An
+// approved return is received at the warehouse, which puts its units back in
+// stock, and completed once they are refunded.
+type Return struct {
+	ID      primitive.ObjectID `json:"_id" bson:"_id"`
+	OrderID primitive.ObjectID `json:"order_id" bson:"order_id"`
+	UserID  primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Lines   []ReturnLine       `json:"lines" bson:"lines"`
+	Comment string             `json:"comment,omitempty" bson:"comment,omitempty"`
+	// Photos are links to pictures of the items, such as of the damage.
+	Photos  []string       `json:"photos,omitempty" bson:"photos,omitempty"`
+	Status  ReturnStatus   `json:"status" bson:"status"`
+	History []ReturnChange `json:"history" bson:"history"`
+	// RefundID is the order's refund for the return once it has been issued.
+	RefundID *primitive.ObjectID `json:"refund_id,omitempty" bson:"refund_id,omitempty"`
+	// Error is why the last attempt at the refund failed.
+	Error     string    `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// ReturnLine is Quantity units of one order line sent back for Reason.
+type ReturnLine struct {
+	ProductID  primitive.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
+	VariantSKU string             `json:"variant_sku,omitempty" bson:"variant_sku,omitempty"`
+	Quantity   uint64             `json:"quantity" bson:"quantity" validate:"required"`
+	Reason     string             `json:"reason" bson:"reason" validate:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+}
+
+// ReturnChange is one entry in a return's history, made by the customer or
+// the support agent By.
+type ReturnChange struct {
+	From ReturnStatus       `json:"from,omitempty" bson:"from,omitempty"`
+	To   ReturnStatus       `json:"to" bson:"to"`
+	At   time.Time          `json:"at" bson:"at"`
+	By   primitive.ObjectID `json:"by" bson:"by"`
+	Note string             `json:"note,omitempty" bson:"note,omitempty"`
 }
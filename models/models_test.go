@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderPending, OrderPaid, true},
+		{OrderPending, OrderPacked, true},
+		{OrderPending, OrderCancelled, true},
+		{OrderPending, OrderShipped, false},
+		{OrderPaid, OrderPacked, true},
+		{OrderPaid, OrderPending, false},
+		{OrderPacked, OrderShipped, true},
+		{OrderPacked, OrderCancelled, true},
+		{OrderShipped, OrderDelivered, true},
+		{OrderShipped, OrderCancelled, false},
+		{OrderDelivered, OrderReturned, true},
+		{OrderDelivered, OrderCancelled, false},
+		{OrderCancelled, OrderPending, false},
+		{OrderReturned, OrderDelivered, false},
+		{OrderPending, OrderPending, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s allowed = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
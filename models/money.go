@@ -0,0 +1,175 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// BaseCurrency is the currency the catalog is priced in and orders are
+// settled in.
+var BaseCurrency = "INR"
+
+var (
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrAmountOverflow   = errors.New("the amount is too large")
+)
+
+// Money is an amount in the minor units of Currency, such as paise or cents,
+// so 12.50 USD is {"amount": 1250, "currency": "USD"}. Currency is an ISO 4217
+// code. A Money without a currency is a bare zero or a running total that has
+// not started yet, and combines with any currency.
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency" validate:"omitempty,iso4217"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// UnmarshalJSON accepts currency codes in any case.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	type plain Money
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = NewMoney(decoded.Amount, decoded.Currency)
+	return nil
+}
+
+// IsZero reports whether there is no amount, whatever the currency. It lets
+// optional prices be left out of JSON and BSON.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// currency returns the currency m and o have in common.
+func (m Money) currency(o Money) (string, error) {
+	switch {
+	case m.Currency == o.Currency, o.Currency == "":
+		return m.Currency, nil
+	case m.Currency == "":
+		return o.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	currency, err := m.currency(o)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Amount: sum, Currency: currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	currency, err := m.currency(o)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: currency}, nil
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than o.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := m.currency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Min returns the smaller of m and o.
+func (m Money) Min(o Money) (Money, error) {
+	cmp, err := m.Cmp(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if cmp <= 0 {
+		return m, nil
+	}
+	return o, nil
+}
+
+// Times returns m multiplied by quantity, failing with ErrAmountOverflow when
+// the product does not fit in an amount.
+func (m Money) Times(quantity uint64) (Money, error) {
+	if quantity > math.MaxInt64 {
+		return Money{}, ErrAmountOverflow
+	}
+	amount := m.Amount * int64(quantity)
+	if quantity != 0 && amount/int64(quantity) != m.Amount {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// Percent returns percent % of m, rounded down to a whole minor unit.
+func (m Money) Percent(percent uint64) Money {
+	return Money{Amount: m.Amount * int64(percent) / 100, Currency: m.Currency}
+}
+
+// minorUnits lists the currencies that do not use two decimal places.
+var minorUnits = map[string]int{
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0,
+}
+
+// MinorUnits returns how many decimal places amounts in currency have.
+func MinorUnits(currency string) int {
+	if digits, ok := minorUnits[currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+// String formats m in major units, e.g. "12.50 USD".
+func (m Money) String() string {
+	digits := MinorUnits(m.Currency)
+
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if digits == 0 {
+		return strings.TrimSpace(fmt.Sprintf("%s%d %s", sign, amount, m.Currency))
+	}
+
+	scale := int64(1)
+	for range digits {
+		scale *= 10
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s%d.%0*d %s", sign, amount/scale, digits, amount%scale, m.Currency))
+}
+
+const (
+	RoundNearest = "nearest"
+	RoundUp      = "up"
+	RoundDown    = "down"
+)
+
+// ExchangeRate says how many units of Currency one unit of BaseCurrency buys.
+// Rate is a decimal string so that it is kept exactly. Converted amounts are
+// rounded to a multiple of Increment minor units, so 100 shows whole units
+// only, using Rounding; nearest rounds halves away from zero.
+type ExchangeRate struct {
+	Currency  string    `json:"currency" bson:"_id" validate:"required,iso4217"`
+	Rate      string    `json:"rate" bson:"rate" validate:"required,max=30"`
+	Rounding  string    `json:"rounding" bson:"rounding" validate:"omitempty,oneof=nearest up down"`
+	Increment int64     `json:"increment" bson:"increment" validate:"gte=0"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
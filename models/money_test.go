@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMoneyTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		quantity uint64
+		want     int64
+		err      error
+	}{
+		{"one", 1999, 1, 1999, nil},
+		{"many", 1999, 100, 199900, nil},
+		{"zero quantity", 1999, 0, 0, nil},
+		{"largest that fits", math.MaxInt64 / 4, 4, math.MaxInt64 / 4 * 4, nil},
+		{"overflow", math.MaxInt64/4 + 1, 4, 0, ErrAmountOverflow},
+		{"quantity beyond int64", 1, math.MaxUint64, 0, ErrAmountOverflow},
+		{"wraps to positive", math.MaxInt64, 1 << 62, 0, ErrAmountOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewMoney(tt.amount, "INR").Times(tt.quantity)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Times() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (got.Amount != tt.want || got.Currency != "INR") {
+				t.Errorf("Times() = %v, want %d INR", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyAddOverflow(t *testing.T) {
+	if _, err := NewMoney(math.MaxInt64, "INR").Add(NewMoney(1, "INR")); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("MaxInt64 + 1: err = %v, want %v", err, ErrAmountOverflow)
+	}
+	if _, err := NewMoney(math.MinInt64, "INR").Add(NewMoney(-1, "INR")); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("MinInt64 - 1: err = %v, want %v", err, ErrAmountOverflow)
+	}
+	if sum, err := NewMoney(math.MaxInt64, "INR").Add(NewMoney(-1, "INR")); err != nil || sum.Amount != math.MaxInt64-1 {
+		t.Errorf("MaxInt64 + -1 = %v, %v", sum, err)
+	}
+}
@@ -0,0 +1,49 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+// Kinds of event providers notify us of.
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventFailed     = "payment.failed"
+	EventRefunded   = "payment.refunded"
+)
+
+// Event is a provider's notification about one of its payments. ID is unique
+// per provider, and the same event may be delivered more than once.
+type Event struct {
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	PaymentID string       `json:"payment_id"`
+	Reference string       `json:"reference"`
+	Amount    models.Money `json:"amount"`
+	// Reason says why a payment failed.
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Sign returns the HMAC-SHA256 signature of body under secret, hex encoded
+// and prefixed with "sha256=".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is what Sign gives for body under secret,
+// comparing in constant time. An empty secret verifies nothing.
+func Verify(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,211 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+// Cards the fake provider knows. Any other card number is declined.
+const (
+	CardSuccess = "4242424242424242"
+	CardDecline = "4000000000000002"
+	// CardTimeout leaves the payment undecided until Settle decides it.
+	CardTimeout = "4000000000000119"
+)
+
+// FakeSignatureHeader carries the signature of the fake provider's
+// notifications.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeProvider is a payment provider that keeps its payments in memory and
+// decides outcomes by card number alone, so checkout can be run end to end
+// without a real gateway. It sends its notifications to webhookURL, signed
+// with webhookSecret.
+type FakeProvider struct {
+	mu            sync.Mutex
+	payments      map[string]*fakePayment
+	next          int
+	webhookSecret string
+	webhookURL    string
+}
+
+type fakePayment struct {
+	reference string
+	amount    models.Money
+	captured  models.Money
+	refunded  models.Money
+	voided    bool
+	// undecided payments timed out and wait for Settle.
+	undecided bool
+}
+
+func NewFakeProvider(webhookSecret string, webhookURL string) *FakeProvider {
+	return &FakeProvider{payments: map[string]*fakePayment{}, webhookSecret: webhookSecret, webhookURL: webhookURL}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, request Request) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if request.Amount.Amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+
+	card := strings.ReplaceAll(strings.TrimSpace(request.Card), " ", "")
+	if card != CardSuccess && card != CardTimeout {
+		return "", ErrDeclined
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.next++
+	id := fmt.Sprintf("fake_pay_%d", p.next)
+	p.payments[id] = &fakePayment{
+		reference: request.Reference,
+		amount:    request.Amount,
+		captured:  models.NewMoney(0, request.Amount.Currency),
+		refunded:  models.NewMoney(0, request.Amount.Currency),
+		undecided: card == CardTimeout,
+	}
+	if card == CardTimeout {
+		return id, ErrTimeout
+	}
+	return id, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, paymentID string, amount models.Money) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if payment.voided || payment.undecided || payment.captured.Amount > 0 {
+		return ErrInvalidState
+	}
+	if cmp, err := amount.Cmp(payment.amount); err != nil || cmp > 0 || amount.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	payment.captured = amount
+	return nil
+}
+
+func (p *FakeProvider) Void(ctx context.Context, paymentID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if payment.captured.Amount > 0 {
+		return ErrInvalidState
+	}
+	payment.voided = true
+	return nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, paymentID string, amount models.Money) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return "", ErrPaymentNotFound
+	}
+	if payment.captured.Amount == 0 {
+		return "", ErrInvalidState
+	}
+	refunded, err := payment.refunded.Add(amount)
+	if err != nil || amount.Amount <= 0 || refunded.Amount > payment.captured.Amount {
+		return "", ErrInvalidAmount
+	}
+	payment.refunded = refunded
+
+	p.next++
+	return fmt.Sprintf("fake_refund_%d", p.next), nil
+}
+
+func (p *FakeProvider) ParseEvent(body []byte, header http.Header) (Event, error) {
+	if !Verify(p.webhookSecret, body, header.Get(FakeSignatureHeader)) {
+		return Event{}, ErrInvalidEvent
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return Event{}, ErrInvalidEvent
+	}
+	return event, nil
+}
+
+// Settle decides a payment whose authorization timed out, approving it or
+// not, and returns the event reporting the outcome.
+func (p *FakeProvider) Settle(paymentID string, approve bool) (Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return Event{}, ErrPaymentNotFound
+	}
+	if !payment.undecided {
+		return Event{}, ErrInvalidState
+	}
+	payment.undecided = false
+
+	p.next++
+	event := Event{
+		ID:        fmt.Sprintf("fake_evt_%d", p.next),
+		Type:      EventAuthorized,
+		PaymentID: paymentID,
+		Reference: payment.reference,
+		Amount:    payment.amount,
+		CreatedAt: time.Now(),
+	}
+	if !approve {
+		payment.voided = true
+		event.Type, event.Reason = EventFailed, ErrDeclined.Error()
+	}
+	return event, nil
+}
+
+// Deliver posts event signed to the webhook, the way a real provider calls
+// it, and returns the body and signature it sent so that a delivery can be
+// repeated by hand.
+func (p *FakeProvider) Deliver(ctx context.Context, event Event) ([]byte, string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	signature := Sign(p.webhookSecret, body)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(FakeSignatureHeader, signature)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return body, signature, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return body, signature, fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return body, signature, nil
+}
@@ -0,0 +1,47 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+)
+
+var (
+	ErrDeclined        = errors.New("the payment was declined")
+	ErrTimeout         = errors.New("the payment provider did not answer in time")
+	ErrPaymentNotFound = errors.New("the payment provider does not know this payment")
+	ErrInvalidAmount   = errors.New("amount is more than the payment allows")
+	ErrInvalidState    = errors.New("the payment cannot do that in its current state")
+	ErrInvalidEvent    = errors.New("the notification is not validly signed or cannot be read")
+)
+
+// Request asks a provider to authorize a card payment.
+type Request struct {
+	// Reference is our ID for what is paid for, the order ID, so the
+	// provider's own records and notifications can be traced back to it.
+	Reference string
+	Amount    models.Money
+	Card      string
+}
+
+// Provider is a payment gateway. A payment is authorized first, which holds
+// the amount on the card, and then either captured, which takes the money,
+// or voided, which lets it go. Captured money can be refunded, in parts.
+type Provider interface {
+	// Name identifies the provider on the orders it took payments for.
+	Name() string
+	// Authorize returns the provider's ID for the new payment. On ErrTimeout
+	// the ID, when there is one, is still returned, since the payment may
+	// yet go through and the outcome has to be matched up with it later.
+	Authorize(ctx context.Context, request Request) (string, error)
+	Capture(ctx context.Context, paymentID string, amount models.Money) error
+	Void(ctx context.Context, paymentID string) error
+	// Refund returns the provider's ID for the refund.
+	Refund(ctx context.Context, paymentID string, amount models.Money) (string, error)
+	// ParseEvent checks that a notification the provider sent to the webhook
+	// is really from it, failing with ErrInvalidEvent otherwise, and decodes
+	// it.
+	ParseEvent(body []byte, header http.Header) (Event, error)
+}
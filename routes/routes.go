@@ -3,12 +3,97 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/patil-prathamesh/e-commerce-golang/controllers"
+	"github.com/patil-prathamesh/e-commerce-golang/middleware"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
 )
 
-func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/users/signup", controllers.SignUp)
-	incomingRoutes.POST("/users/login", controllers.Login)
-	incomingRoutes.POST("/admin/addproduct", controllers.ProductViewerAdmin)
-	incomingRoutes.GET("/users/productView", controllers.SearchProduct)
-	incomingRoutes.GET("/users/search", controllers.SearchProductByQuery)
+func UserRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.POST("/users/signup", app.SignUp)
+	incomingRoutes.POST("/users/login", app.Login)
+	incomingRoutes.POST("/users/refresh", app.RefreshToken)
+	incomingRoutes.GET("/users/productView", app.SearchProduct)
+	incomingRoutes.GET("/users/search", app.SearchProductByQuery)
+	incomingRoutes.GET("/users/autocomplete", app.AutocompleteProducts)
+	incomingRoutes.GET("/users/categories", app.CategoryTree)
+	incomingRoutes.GET("/users/category", app.BrowseCategory)
+	incomingRoutes.GET("/users/currencies", app.ListCurrencies)
+}
+
+// CustomerRoutes are the signed-in customer's cart, addresses, orders and
+// returns.
+func CustomerRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	customer := incomingRoutes.Group("", middleware.Authentication)
+	customer.PUT("/addtocart", app.AddToCart)
+	customer.PUT("/removeitem", app.RemoveItem)
+	customer.PUT("/cartquantity", app.UpdateCartQuantity)
+	customer.GET("/listcart", app.GetItemFromCart)
+	customer.POST("/addaddress", app.AddAddress)
+	customer.PUT("/edithomeaddress", app.EditHomeAddress)
+	customer.PUT("/editworkaddress", app.EditWorkAddress)
+	customer.DELETE("/deleteaddresses", app.DeleteAddress)
+	customer.POST("/cartreserve", app.ReserveCart)
+	customer.POST("/cartrelease", app.ReleaseCart)
+	customer.POST("/cartcoupon", app.ApplyCoupon)
+	customer.DELETE("/cartcoupon", app.RemoveCoupon)
+	customer.POST("/cartcheckout", app.BuyFromCart)
+	customer.POST("/instantbuy", app.InstantBuy)
+	customer.POST("/orders/pay", app.PayOrder)
+	customer.POST("/orders/cancel", app.CancelOrder)
+	customer.POST("/returns", app.RequestReturn)
+	customer.GET("/returns", app.ListOrderReturns)
+	customer.GET("/orders", app.ListOrders)
+	customer.GET("/orders/detail", app.GetOrder)
+}
+
+// PaymentRoutes receive the payment provider's notifications. They take no
+// login; the provider's signature on each request is checked instead.
+func PaymentRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.POST("/payments/webhook", app.PaymentWebhook)
+}
+
+func AdminRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	admin := incomingRoutes.Group("/admin", middleware.Authentication, middleware.Authorize(models.RoleAdmin))
+	admin.POST("/addproduct", app.ProductViewerAdmin)
+	admin.GET("/products", app.ListProductsAdmin)
+	admin.GET("/product", app.GetProductAdmin)
+	admin.PATCH("/product", app.UpdateProduct)
+	admin.DELETE("/product", app.DeleteProduct)
+	admin.PUT("/variant", app.SaveVariant)
+	admin.POST("/coupon", app.CreateCoupon)
+	admin.GET("/coupons", app.ListCoupons)
+	admin.DELETE("/coupon", app.DeleteCoupon)
+	admin.POST("/promotion", app.CreatePromotion)
+	admin.GET("/promotions", app.ListPromotions)
+	admin.DELETE("/promotion", app.DeletePromotion)
+	admin.GET("/exchangerates", app.ListExchangeRates)
+	admin.PUT("/exchangerate", app.SaveExchangeRate)
+	admin.DELETE("/exchangerate", app.DeleteExchangeRate)
+	admin.POST("/taxrule", app.CreateTaxRule)
+	admin.GET("/taxrules", app.ListTaxRules)
+	admin.DELETE("/taxrule", app.DeleteTaxRule)
+	admin.GET("/paymentevents", app.ListPaymentEvents)
+	admin.POST("/paymentevent/replay", app.ReplayPaymentEvent)
+	admin.POST("/fakepayment/settle", app.SettleFakePayment)
+	admin.DELETE("/variant", app.RemoveVariant)
+	admin.POST("/adjuststock", app.AdjustStock)
+	admin.PUT("/orderstatus", app.UpdateOrderStatus)
+	admin.PUT("/userrole", app.UpdateUserRole)
+	admin.GET("/synonyms", app.ListSynonyms)
+	admin.POST("/synonyms", app.SaveSynonyms)
+	admin.DELETE("/synonyms", app.DeleteSynonyms)
+	admin.POST("/category", app.CreateCategory)
+	admin.PATCH("/category", app.UpdateCategory)
+	admin.DELETE("/category", app.DeleteCategory)
+	admin.GET("/refundledger", app.ListLedger)
+}
+
+// SupportRoutes are open to support staff as well as admins.
+func SupportRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	support := incomingRoutes.Group("/support", middleware.Authentication, middleware.Authorize(models.RoleAdmin, models.RoleSupport))
+	support.POST("/refund", app.RefundOrder)
+	support.GET("/refunds", app.ListRefunds)
+	support.GET("/returns", app.ListReturns)
+	support.POST("/return/review", app.ReviewReturn)
+	support.POST("/return/receive", app.ReceiveReturn)
+	support.POST("/return/complete", app.CompleteReturn)
 }
\ No newline at end of file
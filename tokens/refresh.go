@@ -0,0 +1,86 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or has been revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; all sessions from this login have been signed out")
+)
+
+// NewFamily starts a new refresh token family. Every login gets its own.
+func NewFamily() string {
+	return randomID()
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// RefreshTokens exchanges a refresh token for a new access/refresh pair.
+//
+// Only the most recently issued refresh token of a family is accepted. If an
+// older token of the current family shows up again it has been used twice,
+// which means it leaked, so the family is revoked and the user has to log in
+// again. Access tokens already handed out stay valid until they expire.
+func RefreshTokens(ctx context.Context, users database.UserStore, signedRefreshToken string) (signedToken string, newRefreshToken string, err error) {
+	claims, msg := ValidateToken(signedRefreshToken)
+	if msg != "" || claims.TokenType != RefreshToken || claims.Family == "" {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	user, err := users.FindUserByID(ctx, userObjectID)
+	if err != nil {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	if user.TokenFamily != claims.Family {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	if user.RefreshToken != signedRefreshToken {
+		revokeFamily(ctx, users, userObjectID)
+		return "", "", ErrRefreshTokenReused
+	}
+
+	signedToken, newRefreshToken, err = TokenGenerator(claims.UserID, user.Email, user.FirstName, user.LastName, user.UserRole(), claims.Family)
+	if err != nil {
+		return "", "", err
+	}
+
+	rotated, err := users.RotateRefreshToken(ctx, userObjectID, signedRefreshToken, newRefreshToken)
+	if err != nil {
+		return "", "", err
+	}
+	if !rotated {
+		// Someone else rotated this exact token between our read and write.
+		revokeFamily(ctx, users, userObjectID)
+		return "", "", ErrRefreshTokenReused
+	}
+
+	return signedToken, newRefreshToken, nil
+}
+
+func revokeFamily(ctx context.Context, users database.UserStore, userID primitive.ObjectID) {
+	log.Printf("refresh token reuse detected for user %s, revoking token family", userID.Hex())
+	if err := users.RevokeRefreshTokens(ctx, userID); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,105 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/patil-prathamesh/e-commerce-golang/database"
+	"github.com/patil-prathamesh/e-commerce-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// login stores a user signed in once, the way signup does, and returns the
+// store, the user's ID and the refresh token they were given.
+func login(t *testing.T) (*database.MemoryStore, primitive.ObjectID, string) {
+	t.Helper()
+	SECRET_KEY = "test-secret"
+	store := database.NewMemoryStore()
+	user := models.User{ID: primitive.NewObjectID(), FirstName: "Asha", LastName: "Rao", Email: "asha@example.com", Role: models.RoleCustomer}
+	user.TokenFamily = NewFamily()
+	_, refreshToken, err := TokenGenerator(user.ID.Hex(), user.Email, user.FirstName, user.LastName, user.Role, user.TokenFamily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.RefreshToken = refreshToken
+	if err := store.InsertUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return store, user.ID, refreshToken
+}
+
+func TestRefreshTokensRotates(t *testing.T) {
+	store, userID, first := login(t)
+	ctx := context.Background()
+
+	access, second, err := RefreshTokens(ctx, store, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("refresh returned the same refresh token")
+	}
+	if claims, msg := ValidateToken(access); msg != "" || claims.TokenType != AccessToken || claims.UserID != userID.Hex() {
+		t.Errorf("access token claims = %+v (%s), want an access token for %s", claims, msg, userID.Hex())
+	}
+	if _, _, err := RefreshTokens(ctx, store, second); err != nil {
+		t.Errorf("refreshing with the rotated token: %v", err)
+	}
+}
+
+func TestRefreshTokensReuseRevokesFamily(t *testing.T) {
+	store, userID, first := login(t)
+	ctx := context.Background()
+
+	_, second, err := RefreshTokens(ctx, store, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RefreshTokens(ctx, store, first); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing a rotated token: err = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	user, err := store.FindUserByID(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.RefreshToken != "" || user.TokenFamily != "" {
+		t.Errorf("user still holds refresh token family %q after reuse", user.TokenFamily)
+	}
+	if _, _, err := RefreshTokens(ctx, store, second); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refreshing with the latest token after revocation: err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRefreshTokensRejects(t *testing.T) {
+	store, userID, refreshToken := login(t)
+	access, _, err := TokenGenerator(userID.Hex(), "asha@example.com", "Asha", "Rao", models.RoleCustomer, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherFamily, err := TokenGenerator(userID.Hex(), "asha@example.com", "Asha", "Rao", models.RoleCustomer, NewFamily())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"garbage", "not a token"},
+		{"access token", access},
+		{"other family", otherFamily},
+		{"tampered", refreshToken + "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := RefreshTokens(context.Background(), store, tt.token); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("RefreshTokens() error = %v, want %v", err, ErrInvalidRefreshToken)
+			}
+		})
+	}
+	if _, _, err := RefreshTokens(context.Background(), store, refreshToken); err != nil {
+		t.Errorf("the login's own token stopped working after rejected attempts: %v", err)
+	}
+}
@@ -2,16 +2,18 @@ package tokens
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/patil-prathamesh/e-commerce-golang/database"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
 )
 
 type SignedDetails struct {
@@ -19,6 +21,13 @@ type SignedDetails struct {
 	Email     string
 	FirstName string
 	LastName  string
+	Role      string
+	// TokenType tells access and refresh tokens apart so a refresh token
+	// cannot be used to call the API.
+	TokenType string
+	// Family is shared by every refresh token descending from one login, so
+	// the whole chain can be revoked when reuse is detected.
+	Family string
 	jwt.RegisteredClaims
 }
 
@@ -28,17 +37,19 @@ func InitSecretKey() {
 	SECRET_KEY = os.Getenv("SECRET_KEY")
 }
 
-var UserData *mongo.Collection = database.UserData(database.Client, "users")
-
-func TokenGenerator(userID string, email string, firstName string, lastName string) (signedToken string, signedRefreshToken string, err error) {
+func TokenGenerator(userID string, email string, firstName string, lastName string, role string, family string) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		UserID:    userID,
 		Email:     email,
 		FirstName: firstName,
 		LastName:  lastName,
+		Role:      role,
+		TokenType: AccessToken,
+		Family:    family,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        randomID(),
 		},
 	}
 
@@ -47,9 +58,13 @@ func TokenGenerator(userID string, email string, firstName string, lastName stri
 		Email:     email,
 		FirstName: firstName,
 		LastName:  lastName,
+		Role:      role,
+		TokenType: RefreshToken,
+		Family:    family,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(168 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        randomID(),
 		},
 	}
 
@@ -93,26 +108,14 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 	return claims, msg
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userID string) {
+func UpdateAllTokens(users database.UserStore, signedToken string, signedRefreshToken string, userID string, family string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
 	userObjectID, _ := primitive.ObjectIDFromHex(userID)
 
-	filter := bson.M{"_id": userObjectID}
-	update := bson.M{
-		"$set": bson.M{
-			"refresh_token": signedRefreshToken,
-			"updated_at":    time.Now(),
-		},
-	}
-
-	result, err := UserData.UpdateOne(ctx, filter, update)
+	err := users.UpdateRefreshToken(ctx, userObjectID, signedRefreshToken, family)
 	if err != nil {
 		log.Panic(err)
 	}
-
-	if result.ModifiedCount > 0 {
-		fmt.Println("Existing document updated")
-	}
 }